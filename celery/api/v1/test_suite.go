@@ -0,0 +1,64 @@
+package v1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ValidationTestKind is the kind of ValidationTest resources.
+const ValidationTestKind = "ValidationTest"
+
+// ValidationTest is a KRM resource describing the expected outcome of rules
+// against fixture resources. Paths are relative to the file the test is in.
+type ValidationTest struct {
+	metav1.TypeMeta   `yaml:",inline"`
+	metav1.ObjectMeta `yaml:"metadata,omitempty"`
+	Spec              ValidationTestSpec `yaml:"spec"`
+	Filename          string
+}
+
+// ValidationTestSpec lists the rules under test, the resources to run them
+// against, and the outcomes expected.
+type ValidationTestSpec struct {
+	// RuleFiles are ValidationRules files, globs and preset:NAME are supported.
+	RuleFiles []string `yaml:"ruleFiles"`
+	// Resources are manifest files or directories to validate.
+	Resources []string `yaml:"resources"`
+	// Params is a YAML or JSON file that rules without a paramsRef see as
+	// params, like --params.
+	Params string        `yaml:"params,omitempty"`
+	Expect []Expectation `yaml:"expect"`
+}
+
+// Expectation is the outcome expected when a rule is evaluated against a resource.
+type Expectation struct {
+	Rule string `yaml:"rule"`
+	// Resource is Kind/name. When empty the expectation applies to every
+	// resource the rule is evaluated against.
+	Resource string  `yaml:"resource,omitempty"`
+	Outcome  Outcome `yaml:"outcome"`
+	// Message, if set, must be contained in the failure message.
+	Message string `yaml:"message,omitempty"`
+}
+
+// Outcome is the result of evaluating a rule against a resource.
+type Outcome string
+
+const (
+	OutcomePass Outcome = "pass"
+	OutcomeFail Outcome = "fail"
+	// OutcomeSkip means the rule's target does not select the resource, or the
+	// resource opts out of the rule with SkipAnnotation.
+	OutcomeSkip Outcome = "skip"
+)
+
+// ParseOutcome converts a string into an Outcome.
+func ParseOutcome(s string) (Outcome, error) {
+	switch Outcome(s) {
+	case OutcomePass, OutcomeFail, OutcomeSkip:
+		return Outcome(s), nil
+	default:
+		return "", fmt.Errorf("unknown outcome %q (must be one of pass, fail, skip)", s)
+	}
+}
@@ -1,15 +1,71 @@
 package v1
 
 import (
+	"fmt"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// GroupVersion is the apiVersion of celery resources.
+	GroupVersion = "celery.rrethy.io/v1"
+	// ValidationRulesKind is the kind of ValidationRules resources.
+	ValidationRulesKind = "ValidationRules"
+
+	// SkipAnnotation is a comma-separated list of rule names a resource is exempt
+	// from, or "*" for every rule.
+	SkipAnnotation = "celery.rrethy.io/skip"
+	// SkipReasonAnnotation justifies SkipAnnotation. Skips without a reason are not honoured.
+	SkipReasonAnnotation = "celery.rrethy.io/skip-reason"
+)
+
 // ValidationRules is a KRM resource that defines CEL validation rules.
 type ValidationRules struct {
 	metav1.TypeMeta   `yaml:",inline"`
 	metav1.ObjectMeta `yaml:"metadata,omitempty"`
 	Spec              ValidationRulesSpec `yaml:"spec"`
 	Filename          string
+	// Params are the contents of the Spec.ParamsRef file, read when the rules
+	// are loaded. They are nil when the rules have no paramsRef.
+	Params any `yaml:"-"`
+}
+
+// MarshalYAML writes ValidationRules as a KRM resource. The embedded Kubernetes
+// types only carry json tags, so their fields are written explicitly and
+// Filename, which is not part of the resource, is left out.
+func (r ValidationRules) MarshalYAML() (any, error) {
+	type metadata struct {
+		Name        string            `yaml:"name,omitempty"`
+		Namespace   string            `yaml:"namespace,omitempty"`
+		Labels      map[string]string `yaml:"labels,omitempty"`
+		Annotations map[string]string `yaml:"annotations,omitempty"`
+	}
+
+	apiVersion := r.APIVersion
+	if apiVersion == "" {
+		apiVersion = GroupVersion
+	}
+	kind := r.Kind
+	if kind == "" {
+		kind = ValidationRulesKind
+	}
+
+	return struct {
+		APIVersion string              `yaml:"apiVersion"`
+		Kind       string              `yaml:"kind"`
+		Metadata   metadata            `yaml:"metadata"`
+		Spec       ValidationRulesSpec `yaml:"spec"`
+	}{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Metadata: metadata{
+			Name:        r.Name,
+			Namespace:   r.Namespace,
+			Labels:      r.Labels,
+			Annotations: r.Annotations,
+		},
+		Spec: r.Spec,
+	}, nil
 }
 
 // ValidationRulesList is a list of ValidationRules resources.
@@ -21,23 +77,174 @@ type ValidationRulesList struct {
 
 // ValidationRulesSpec contains the validation rules.
 type ValidationRulesSpec struct {
-	Rules []ValidationRule `yaml:"rules"`
+	// Include are rule files, relative to this file and optionally globs, whose
+	// rules are loaded along with these ones.
+	Include []string `yaml:"include,omitempty"`
+	// Overrides change or disable rules loaded through Include.
+	Overrides []RuleOverride `yaml:"overrides,omitempty"`
+	// ParamsRef is a YAML or JSON file, relative to this file, whose contents
+	// every rule sees as params.
+	ParamsRef string `yaml:"paramsRef,omitempty"`
+	// Variables are named CEL expressions available to every rule as variables.<name>.
+	Variables []Variable       `yaml:"variables,omitempty"`
+	Rules     []ValidationRule `yaml:"rules"`
+}
+
+// RuleOverride changes every included rule named Rule. Fields that are not
+// set leave the rule as it is.
+type RuleOverride struct {
+	Rule string `yaml:"rule"`
+	// Disabled drops the rule.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Target replaces the target and targets of the rule. Its exclude is kept.
+	Target *TargetSelector `yaml:"target,omitempty"`
+	// Message replaces the message of the rule, and its messageExpression.
+	Message  string   `yaml:"message,omitempty"`
+	Severity Severity `yaml:"severity,omitempty"`
+}
+
+// Variable is a named CEL expression. It may reference variables declared before it.
+type Variable struct {
+	Name       string `yaml:"name"`
+	Expression string `yaml:"expression"`
 }
 
 type ValidationRule struct {
-	Name       string          `yaml:"name"`
-	Expression string          `yaml:"expression"`
-	Message    string          `yaml:"message,omitempty"`
-	Target     *TargetSelector `yaml:"target,omitempty"`
+	Name       string `yaml:"name"`
+	Expression string `yaml:"expression"`
+	Message    string `yaml:"message,omitempty"`
+	// MessageExpression is a CEL expression returning the failure message. It sees the
+	// same variables as Expression and falls back to Message if it fails.
+	MessageExpression string          `yaml:"messageExpression,omitempty"`
+	Severity          Severity        `yaml:"severity,omitempty"`
+	Target            *TargetSelector `yaml:"target,omitempty"`
+	// Targets are further selectors. The rule applies to resources matching
+	// Target or any of Targets.
+	Targets []TargetSelector `yaml:"targets,omitempty"`
+	// Exclude are selectors of resources the rule does not apply to, even
+	// when they match its targets.
+	Exclude []TargetSelector `yaml:"exclude,omitempty"`
+	// Transition marks a rule that compares object with oldObject. It is only
+	// evaluated when a previous version of the resource is known.
+	Transition bool `yaml:"transition,omitempty"`
+	// CostLimit overrides the cost a single evaluation of the rule may use.
+	CostLimit uint64 `yaml:"costLimit,omitempty"`
+	// Scope overrides which resources the rule sees as allObjects.
+	Scope Scope `yaml:"scope,omitempty"`
+	// Fix is how celery fix changes a resource failing the rule so that it passes.
+	Fix *Fix `yaml:"fix,omitempty"`
+}
+
+// Selectors returns Target followed by Targets. A rule with no selectors
+// applies to every resource.
+func (r ValidationRule) Selectors() []TargetSelector {
+	var selectors []TargetSelector
+	if r.Target != nil {
+		selectors = append(selectors, *r.Target)
+	}
+	return append(selectors, r.Targets...)
+}
+
+// Fix changes a resource that fails a rule. Exactly one of Patch and
+// Expression is set.
+type Fix struct {
+	// Patch is a list of JSON patch (RFC 6902) operations applied to the resource.
+	Patch []PatchOperation `yaml:"patch,omitempty"`
+	// Expression is a CEL expression returning an object that is merged into the
+	// resource as a JSON merge patch (RFC 7386): maps are merged, null removes a
+	// field, and anything else replaces the field. It sees the same variables as
+	// the rule's expression.
+	Expression string `yaml:"expression,omitempty"`
+}
+
+// PatchOperation is a single JSON patch operation. Path and From are JSON
+// pointers, e.g. /metadata/labels/app.
+type PatchOperation struct {
+	Op    string `yaml:"op"`
+	Path  string `yaml:"path"`
+	From  string `yaml:"from,omitempty"`
+	Value any    `yaml:"value,omitempty"`
+}
+
+// Scope is the set of resources a rule sees as allObjects.
+type Scope string
+
+const (
+	// ScopeFile limits allObjects to the resources in the same input file.
+	ScopeFile Scope = "file"
+	// ScopeAll makes allObjects every resource across every input file.
+	ScopeAll Scope = "all"
+)
+
+// ParseScope converts a string into a Scope, defaulting empty values to file.
+func ParseScope(s string) (Scope, error) {
+	switch Scope(s) {
+	case "", ScopeFile:
+		return ScopeFile, nil
+	case ScopeAll:
+		return ScopeAll, nil
+	default:
+		return "", fmt.Errorf("unknown scope %q (must be one of file, all)", s)
+	}
+}
+
+// Severity is how seriously a failing rule is treated. An empty severity is an error.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// ParseSeverity converts a string into a Severity, defaulting empty values to error.
+func ParseSeverity(s string) (Severity, error) {
+	switch Severity(s) {
+	case "", SeverityError:
+		return SeverityError, nil
+	case SeverityWarning:
+		return SeverityWarning, nil
+	case SeverityInfo:
+		return SeverityInfo, nil
+	default:
+		return "", fmt.Errorf("unknown severity %q (must be one of error, warning, info)", s)
+	}
+}
+
+// AtLeast reports whether s is at least as severe as threshold.
+func (s Severity) AtLeast(threshold Severity) bool {
+	return s.rank() >= threshold.rank()
+}
+
+func (s Severity) rank() int {
+	switch s {
+	case SeverityInfo:
+		return 0
+	case SeverityWarning:
+		return 1
+	default:
+		return 2
+	}
 }
 
-// TargetSelector matches Kustomize's selector format.
+// TargetSelector matches Kustomize's selector format. A resource matches when
+// it matches every field that is set.
 type TargetSelector struct {
-	Group              string `yaml:"group,omitempty"`
-	Version            string `yaml:"version,omitempty"`
-	Kind               string `yaml:"kind,omitempty"`
-	Name               string `yaml:"name,omitempty"`
+	Group   string `yaml:"group,omitempty"`
+	Version string `yaml:"version,omitempty"`
+	Kind    string `yaml:"kind,omitempty"`
+	Name    string `yaml:"name,omitempty"`
+	// NamePattern is a glob the name must match, as in "web-*".
+	NamePattern string `yaml:"namePattern,omitempty"`
+	// NameRegex is a regular expression the whole name must match.
+	NameRegex          string `yaml:"nameRegex,omitempty"`
 	Namespace          string `yaml:"namespace,omitempty"`
 	LabelSelector      string `yaml:"labelSelector,omitempty"`
 	AnnotationSelector string `yaml:"annotationSelector,omitempty"`
+	// NamespaceSelector is a label selector matched against the labels of the
+	// namespace of the resource, taken from the Namespace objects among the
+	// inputs. When the namespace is not among them, its labels are looked up
+	// in the cluster in cluster and admission mode; otherwise the resource
+	// does not match.
+	NamespaceSelector string `yaml:"namespaceSelector,omitempty"`
 }
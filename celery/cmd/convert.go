@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/RRethy/kube-tools/celery/pkg/cli/convert"
+)
+
+var convertStrict bool
+
+var convertCmd = &cobra.Command{
+	Use:   "convert [files|dirs...]",
+	Short: "Convert between ValidationRules and ValidatingAdmissionPolicies",
+	Long: `Convert celery ValidationRules to Kubernetes ValidatingAdmissionPolicy and
+ValidatingAdmissionPolicyBinding resources, or the reverse.
+
+The direction is chosen per resource:
+  • ValidationRules become policies and bindings
+  • ValidatingAdmissionPolicies and their bindings become ValidationRules
+
+Mapping:
+  • Rule targets map to matchConstraints resourceRules, namespace to a
+    kubernetes.io/metadata.name namespaceSelector, labels to objectSelector
+  • Rule severity maps to binding validationActions: error → Deny,
+    warning → Warn, info → Audit
+  • spec.variables, message and messageExpression are copied as is
+  • matchConditions are folded into each rule's expression
+  • Validations that reference oldObject become transition rules
+
+Anything that cannot be mapped exactly, such as paramKind, request or
+oldObject references, subresources or annotation selectors, is reported on
+stderr. Converted resources are written to stdout as YAML.`,
+	Example: `# Convert ValidationRules into policies and bindings
+celery convert validation-rules.yaml > policies.yaml
+
+# Convert policies and bindings back into ValidationRules
+celery convert policy.yaml binding.yaml > validation-rules.yaml
+
+# Convert a directory of policies from stdin
+cat policies/*.yaml | celery convert
+
+# Fail if anything could not be converted exactly
+celery convert validation-rules.yaml --strict`,
+	RunE: func(_ *cobra.Command, args []string) error {
+		return convert.Convert(context.Background(), convert.Options{
+			Files:  args,
+			Strict: convertStrict,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().BoolVar(&convertStrict, "strict", false, "Exit with an error if anything could not be converted exactly")
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/RRethy/kube-tools/celery/pkg/cli/fix"
+)
+
+var (
+	fixRuleFiles    []string
+	fixPresets      []string
+	fixParams       string
+	fixIncludeGlobs []string
+	fixExcludeGlobs []string
+	fixScope        string
+	fixDryRun       bool
+)
+
+var fixCmd = &cobra.Command{
+	Use:   "fix [files|dirs...]",
+	Short: "Apply the fixes of validation rules to failing resources",
+	Long: `Apply the fixes declared by validation rules to the resources that fail them,
+rewriting the files in place.
+
+Rules declare a fix as either:
+  • patch: JSON patch operations (RFC 6902) applied to the resource
+  • expression: a CEL expression returning an object that is merged into the
+    resource as a JSON merge patch (RFC 7386), where null removes a field
+
+Fixing:
+  • Rules are applied in order and each sees the fixes of the rules before it
+  • A fix is only kept when the rule passes after it
+  • Transition rules are not fixed, as there is no previous version
+  • Resources that skip a rule with the celery.rrethy.io/skip annotation are
+    not fixed by it
+
+Rewriting:
+  • Only documents that changed are rewritten, the rest of a file is kept
+    byte for byte
+  • Comments and the order of keys are kept, new fields are added after the
+    existing ones
+  • JSON files are skipped
+  • Stdin is fixed and written to stdout
+  • --dry-run prints a unified diff instead of writing anything
+
+Every fix and every failure that could not be fixed is listed on stderr. The
+command exits with status 1 if any failure could not be fixed.`,
+	Example: `# Fix every manifest in a directory
+celery fix manifests/ --rule-file "rules/*.yaml"
+
+# Show what would change without writing anything
+celery fix manifests/ --rule-file "rules/*.yaml" --dry-run
+
+# Fix rendered manifests on their way to kubectl
+kustomize build . | celery fix --rule-file rules.yaml | kubectl apply -f -`,
+	RunE: func(_ *cobra.Command, args []string) error {
+		return fix.Fix(context.Background(), fix.Options{
+			Files:     args,
+			Include:   fixIncludeGlobs,
+			Exclude:   fixExcludeGlobs,
+			RuleFiles: fixRuleFiles,
+			Presets:   fixPresets,
+			Params:    fixParams,
+			Scope:     fixScope,
+			DryRun:    fixDryRun,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fixCmd)
+
+	fixCmd.Flags().StringSliceVarP(&fixRuleFiles, "rule-file", "r", []string{}, "YAML files containing validation rules (supports globs when quoted, can be specified multiple times)")
+	fixCmd.Flags().StringSliceVar(&fixPresets, "preset", []string{}, "Embedded rule packs to load, see celery presets list (can be specified multiple times)")
+	fixCmd.Flags().StringVar(&fixParams, "params", "", "YAML or JSON file that rules see as params, unless their rule file sets spec.paramsRef")
+	fixCmd.Flags().StringSliceVar(&fixIncludeGlobs, "include", []string{}, "Only fix files matching these globs when walking directories (can be specified multiple times)")
+	fixCmd.Flags().StringSliceVar(&fixExcludeGlobs, "exclude", []string{}, "Skip files matching these globs when walking directories (can be specified multiple times)")
+	fixCmd.Flags().StringVar(&fixScope, "scope", "file", "Resources rules see as allObjects unless they set scope: file (same input file) or all (every input)")
+	fixCmd.Flags().BoolVar(&fixDryRun, "dry-run", false, "Print a unified diff of the fixes instead of writing them")
+
+	fixCmd.MarkFlagsOneRequired("rule-file", "preset")
+}
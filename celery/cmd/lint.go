@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/RRethy/kube-tools/celery/pkg/cli/lint"
+)
+
+var lintCRDs []string
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [files|dirs...]",
+	Short: "Check rule files for mistakes",
+	Long: `Check ValidationRules files for rules that cannot load, can never fail, or
+are unlikely to do what was meant.
+
+Each problem is printed as file:line:column with the rule it is in:
+  • unknown fields, which are otherwise silently ignored
+  • rules without a name, and names used by more than one rule in any of the files
+  • expressions and messageExpressions that do not compile or return the wrong type
+  • expressions that do not depend on the resource, so they always pass or always fail
+  • target, targets and exclude selectors that do not parse
+  • selectors of a kind that is neither built in nor defined by a --crd, reported as warnings
+
+Directories are searched recursively and documents of other kinds are ignored.
+Files that are not valid YAML, such as Helm templates, are warnings unless they
+declare kind: ValidationRules.
+The current directory is searched when no files are given. Includes are not
+followed, so pass shared rule files as well to check names across them.
+
+The command exits with status 1 when any problem is an error.`,
+	Example: `# Lint every rule file under the current directory
+celery lint
+
+# Lint a rule repository whose rules target custom resources
+celery lint rules/ --crd crds/`,
+	RunE: func(_ *cobra.Command, args []string) error {
+		return lint.Lint(context.Background(), lint.Options{
+			Files: args,
+			CRDs:  lintCRDs,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	lintCmd.Flags().StringSliceVar(&lintCRDs, "crd", []string{}, "CustomResourceDefinition files or directories whose kinds rules may target")
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/RRethy/kube-tools/celery/pkg/cli/presets"
+)
+
+var presetsCmd = &cobra.Command{
+	Use:   "presets",
+	Short: "List and show the rule packs embedded in celery",
+	Long: `Presets are curated ValidationRules packs embedded in the binary, so common
+rules do not have to be rewritten by every team.
+
+Presets load like rule files:
+  • --preset NAME on validate, fix, rules and serve loads a preset alongside
+    any --rule-file
+  • preset:NAME may be used wherever a rule file path is, such as spec.include
+    of a rule file or ruleFiles of a ValidationTest
+  • Rule files that include a preset can disable its rules or replace their
+    target, message or severity with spec.overrides
+
+The presets are:
+  • pod-security-baseline, the Baseline level of the Pod Security Standards
+  • restricted, the Restricted level of the Pod Security Standards, which
+    includes pod-security-baseline
+  • best-practices, warnings for missing resource limits and probes, latest or
+    untagged images, and multi-replica Deployments without a PodDisruptionBudget`,
+	Example: `# List the presets
+celery presets list
+
+# Show the rules of a preset
+celery presets show restricted
+
+# Show a preset with its includes loaded
+celery rules --preset restricted --resolved`,
+}
+
+var presetsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the embedded presets",
+	Args:  cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return presets.List(context.Background())
+	},
+}
+
+var presetsShowCmd = &cobra.Command{
+	Use:   "show NAME",
+	Short: "Print the ValidationRules of an embedded preset",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		return presets.Show(context.Background(), presets.ShowOptions{
+			Name: args[0],
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(presetsCmd)
+	presetsCmd.AddCommand(presetsListCmd)
+	presetsCmd.AddCommand(presetsShowCmd)
+}
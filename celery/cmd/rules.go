@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/RRethy/kube-tools/celery/pkg/cli/rules"
+)
+
+var (
+	rulesRuleFiles []string
+	rulesPresets   []string
+	rulesResolved  bool
+)
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "List the rules loaded from rule files",
+	Long: `List the rules loaded from rule files, after includes and overrides.
+
+Rule files can build on shared rule packs:
+  • spec.include lists rule files, relative to the including file, globs supported
+  • spec.overrides disable an included rule by name, or replace its target,
+    message or severity
+  • Included rules keep their own variables and are reported with their own file
+  • Include cycles and overrides that match no included rule are errors
+  • A file matched by --rule-file that another matched file includes is only
+    loaded through it
+
+--resolved prints the merged rule set as ValidationRules YAML, the rules every
+other command evaluates.`,
+	Example: `# List the rules a team rule file ends up with
+celery rules --rule-file team-rules.yaml
+
+# Print the merged rule set
+celery rules --rule-file "rules/*.yaml" --resolved
+
+# List the rules of an embedded preset
+celery rules --preset restricted`,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return rules.Rules(context.Background(), rules.Options{
+			RuleFiles: rulesRuleFiles,
+			Presets:   rulesPresets,
+			Resolved:  rulesResolved,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rulesCmd)
+
+	rulesCmd.Flags().StringSliceVarP(&rulesRuleFiles, "rule-file", "r", []string{}, "YAML files containing validation rules (supports globs when quoted, can be specified multiple times)")
+	rulesCmd.Flags().StringSliceVar(&rulesPresets, "preset", []string{}, "Embedded rule packs to load, see celery presets list (can be specified multiple times)")
+	rulesCmd.Flags().BoolVar(&rulesResolved, "resolved", false, "Print the merged rule set as YAML instead of a table")
+
+	rulesCmd.MarkFlagsOneRequired("rule-file", "preset")
+}
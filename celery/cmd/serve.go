@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/RRethy/kube-tools/celery/pkg/cli/serve"
+)
+
+var (
+	serveRuleFiles      []string
+	servePresets        []string
+	serveParams         string
+	serveAddr           string
+	serveTLSCertFile    string
+	serveTLSKeyFile     string
+	serveInsecure       bool
+	serveAudit          bool
+	serveReloadInterval time.Duration
+	serveKubeconfig     string
+	serveContext        string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve ValidationRules as a ValidatingAdmissionWebhook",
+	Long: `Run an HTTPS server that answers AdmissionReview v1 requests from the
+Kubernetes API server, so the same rules run in CI and at admission time.
+
+Requests are served on /validate and probes on /healthz:
+  • object is the object being admitted and oldObject its previous version,
+    which is null for creates
+  • Failing error severity rules deny the request with their messages
+  • Failing warning severity rules are returned as admission warnings
+  • Failing info severity rules are only logged
+  • Requests without an object, such as deletes, are allowed
+
+With --audit every request is allowed and failures are only logged, which is
+useful for trying out rules before enforcing them.
+
+Rule files and params files are checked for changes every --reload-interval
+and the rules recompiled. If the new rules do not compile the previous rules
+stay in use.
+
+Admission requests do not include the labels of the object's Namespace, so
+rules with a namespaceSelector look the Namespace up in the cluster, from the
+in-cluster config or --kubeconfig and --context, and cache it for 30s. The
+webhook's ServiceAccount needs get on namespaces for them. When the lookup
+fails those rules fail with its error.
+
+Logs are written to stderr.`,
+	Example: `# Serve rules with a certificate mounted from a Secret
+celery serve --rule-file '/etc/celery/rules/*.yaml' \
+  --tls-cert-file /etc/celery/tls/tls.crt --tls-private-key-file /etc/celery/tls/tls.key
+
+# Log what would be denied without denying anything
+celery serve --rule-file rules.yaml --tls-cert-file tls.crt --tls-private-key-file tls.key --audit
+
+# Serve plain HTTP behind a TLS terminating proxy
+celery serve --rule-file rules.yaml --insecure --addr :8080`,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return serve.Serve(context.Background(), serve.Options{
+			RuleFiles:      serveRuleFiles,
+			Presets:        servePresets,
+			Params:         serveParams,
+			Addr:           serveAddr,
+			TLSCertFile:    serveTLSCertFile,
+			TLSKeyFile:     serveTLSKeyFile,
+			Insecure:       serveInsecure,
+			Audit:          serveAudit,
+			ReloadInterval: serveReloadInterval,
+			Kubeconfig:     serveKubeconfig,
+			Context:        serveContext,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringSliceVarP(&serveRuleFiles, "rule-file", "r", []string{}, "YAML files containing validation rules (supports globs when quoted, can be specified multiple times)")
+	serveCmd.Flags().StringSliceVar(&servePresets, "preset", []string{}, "Embedded rule packs to load, see celery presets list (can be specified multiple times)")
+	serveCmd.Flags().StringVar(&serveParams, "params", "", "YAML or JSON file that rules see as params, unless their rule file sets spec.paramsRef")
+	serveCmd.Flags().StringVar(&serveAddr, "addr", ":8443", "Address to listen on")
+	serveCmd.Flags().StringVar(&serveTLSCertFile, "tls-cert-file", "", "TLS certificate file")
+	serveCmd.Flags().StringVar(&serveTLSKeyFile, "tls-private-key-file", "", "TLS private key file")
+	serveCmd.Flags().BoolVar(&serveInsecure, "insecure", false, "Serve plain HTTP instead of HTTPS")
+	serveCmd.Flags().BoolVar(&serveAudit, "audit", false, "Allow every request and only log failures")
+	serveCmd.Flags().DurationVar(&serveReloadInterval, "reload-interval", 10*time.Second, "How often to check rule files for changes, 0 disables reloading")
+
+	serveCmd.Flags().StringVar(&serveKubeconfig, "kubeconfig", "", "Path to the kubeconfig file used to look up Namespaces, defaults to the in-cluster config")
+	serveCmd.Flags().StringVar(&serveContext, "context", "", "Kubeconfig context used to look up Namespaces")
+
+	serveCmd.MarkFlagsOneRequired("rule-file", "preset")
+}
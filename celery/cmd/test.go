@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/RRethy/kube-tools/celery/pkg/cli/test"
+)
+
+var testVerbose bool
+
+var testCmd = &cobra.Command{
+	Use:   "test [files|dirs...]",
+	Short: "Test validation rules against fixture resources",
+	Long: `Run ValidationTest suites that assert how rules behave on known-good and
+known-bad resources.
+
+A ValidationTest lists rule files, fixture resources, and the expected outcome
+of each rule on each resource:
+  • pass: the rule was evaluated and passed
+  • fail: the rule was evaluated and failed, optionally with a message containing text
+  • skip: the rule's target did not select the resource, or the resource
+    skips it with the celery.rrethy.io/skip annotation
+
+spec.params is a params file for the rules without a paramsRef, like --params.
+
+Paths in a ValidationTest are relative to the file it is in. Directories are
+searched recursively and documents of other kinds are ignored. The current
+directory is searched when no files are given.
+
+Outcomes that differ from what was expected are printed as a diff and the
+command exits with status 1.`,
+	Example: `# Run every test under the current directory
+celery test
+
+# Run the tests in a directory
+celery test fixtures/tests/
+
+# Run a single test file and list passing tests too
+celery test fixtures/tests/deployment-standards.yaml -v`,
+	RunE: func(_ *cobra.Command, args []string) error {
+		return test.Test(context.Background(), test.Options{
+			Files:   args,
+			Verbose: testVerbose,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+
+	testCmd.Flags().BoolVarP(&testVerbose, "verbose", "v", false, "List passing tests as well as failing ones")
+}
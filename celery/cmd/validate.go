@@ -2,10 +2,12 @@ package cmd
 
 import (
 	"context"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/RRethy/kube-tools/celery/pkg/cli/validate"
+	"github.com/RRethy/kube-tools/celery/pkg/validator"
 )
 
 const defaultWorkers = 128
@@ -13,8 +15,35 @@ const defaultWorkers = 128
 var (
 	celExpression string
 	ruleFiles     []string
+	presetNames   []string
+	paramsFile    string
 	verbose       bool
+	explain       bool
 	maxWorkers    int
+	includeGlobs  []string
+	excludeGlobs  []string
+	outputFormat  string
+	failOn        string
+	baselineFile  string
+	writeBaseline string
+
+	clusterMode      bool
+	kubeconfig       string
+	kubeContext      string
+	clusterNamespace string
+	clusterResources []string
+
+	oldManifests []string
+	newManifests []string
+
+	typeCheck bool
+	crdFiles  []string
+
+	costLimit   uint64
+	costBudget  uint64
+	evalTimeout time.Duration
+
+	allObjectsScope string
 
 	targetGroup              string
 	targetVersion            string
@@ -26,23 +55,93 @@ var (
 )
 
 var validateCmd = &cobra.Command{
-	Use:   "validate [files...]",
+	Use:   "validate [files|dirs...]",
 	Short: "Validate Kubernetes KRM resources using CEL expressions",
 	Long: `Evaluate CEL expressions against Kubernetes resources to check if they meet 
 specified conditions.
 
 Input sources:
   • File paths as arguments (supports multiple files)
-  • Stdin when no files specified
-  • Supports multi-document YAML
+  • Directories, walked recursively for .yaml, .yml and .json files
+  • Stdin when no files are specified or "-" is given
+  • Supports multi-document YAML and JSON (objects, arrays of objects)
 
 Validation rules:
   • Inline via --expression flag
   • Policy files via --rule-file flag
+  • Embedded rule packs via --preset flag, see celery presets list
+  • Lookup tables as params, from --params or a rule file's spec.paramsRef
   • Target specific resources using selectors
 
-The command exits with status 1 if any validation fails. Multiple files are 
-processed in parallel for performance.`,
+Output formats:
+  • text (default) human-readable results grouped by file
+  • json and yaml for scripting and trend data
+  • sarif for code scanning and PR annotations
+  • junit for CI test reports
+  • Results point at file:line:column of the field a failing rule selects, or
+    of the resource
+
+Cluster validation:
+  • --cluster lists objects from a live cluster instead of reading files
+  • --kubeconfig and --context select the cluster, --namespace limits the scope
+  • --resources picks resource types, "all" (default) lists every listable type
+  • Results are labelled context/namespace instead of an input file
+  • Namespaces are listed for namespaceSelectors when --resources leaves them out
+
+Transition rules:
+  • --old and --new compare two versions of the same manifests
+  • Resources are paired by group, kind, namespace and name
+  • oldObject is the old version of a paired resource, null for added ones
+  • Rules with transition: true only run against paired resources
+  • Removed resources are listed on stderr, no rules run against them
+
+Type checking:
+  • --typecheck compiles rules against the OpenAPI schema of the kinds they target
+  • Misspelt fields and type errors fail with the rule name and file before
+    anything is validated
+  • Built-in Kubernetes types are known, --crd adds CustomResourceDefinitions
+  • Rules without a target kind are not type checked
+
+Limits:
+  • --cost-limit caps the CEL cost of a single rule evaluation, as the apiserver
+    does, and rules may set their own with costLimit
+  • Rules whose estimated cost is always over their limit fail to load
+  • --cost-budget caps the cost of every evaluation together
+  • --timeout caps how long a single rule evaluation may take
+  • Evaluations stopped by a limit fail as "limit exceeded" at the rule's severity
+
+Cross-file rules:
+  • allObjects is every resource in the same input file by default
+  • --scope all makes it every resource across all inputs, so rules can check
+    that a Service in one file selects a Deployment in another
+  • Rules may set their own scope: file or scope: all
+  • Results still name the file each resource came from
+  • With the all scope every input is read before rules are evaluated
+
+Rule severities:
+  • Rules may set severity: error (default), warning, or info
+  • Only failures at or above --fail-on (default error) fail the run
+  • Lower severity failures are still reported and counted in the summary
+
+Explaining failures:
+  • --explain evaluates failing rules again and shows why they were false
+  • Each failure lists the subexpressions that made it false with their values,
+    e.g. the false operands of && and the arguments of comparisons
+  • all, exists and exists_one list the index or key and value of the elements
+    that made them false, with why each element failed
+  • Explanations are included in json and yaml output
+  • Evaluating again counts against --cost-budget, explaining stops once it is
+    spent
+
+Baselines:
+  • --write-baseline records every current failure to a file
+  • --baseline ignores those known failures on later runs, so only new ones fail
+  • Baseline entries that no longer fail are reported as stale on stderr
+
+The command exits with status 1 if any validation fails, in every output format.
+Files are read and rules evaluated by a pool of --max-workers workers, and
+results are consumed as they are produced, so memory use stays bounded on large
+manifest sets. Text output keeps only the results it prints.`,
 	Example: `# Validate a single file
 celery validate deployment.yaml --expression "spec.replicas >= 3"
 
@@ -58,9 +157,58 @@ celery validate deployment.yaml --rule-file "rules/*.yaml"
 # Validate all YAML files in a directory
 celery validate *.yaml --rule-file validation-rules.yaml
 
+# Validate against embedded rule packs, alongside your own rules
+celery validate manifests/ --preset restricted --preset best-practices --rule-file team-rules.yaml
+
+# Validate with the lookup tables of an environment
+celery validate manifests/ --rule-file registry-rules.yaml --params params/production.yaml
+
 # Validate from stdin
 cat deployment.yaml | celery validate --expression "spec.replicas >= 3"
 
+# Validate stdin alongside other files
+kustomize build . | celery validate - extra.yaml --rule-file validation-rules.yaml
+
+# Validate a directory recursively, skipping test fixtures
+celery validate manifests/ --rule-file validation-rules.yaml --exclude "*_test.yaml" --exclude "testdata/*"
+
+# Validate only JSON manifests in a directory
+celery validate manifests/ --rule-file validation-rules.yaml --include "*.json"
+
+# Produce SARIF for code scanning annotations
+celery validate manifests/ --rule-file validation-rules.yaml --output sarif > celery.sarif
+
+# Produce a JUnit report for CI
+celery validate manifests/ --rule-file validation-rules.yaml -o junit > celery-junit.xml
+
+# Record existing failures, then only fail on new ones
+celery validate manifests/ --rule-file "rules/*.yaml" --write-baseline celery-baseline.yaml
+celery validate manifests/ --rule-file "rules/*.yaml" --baseline celery-baseline.yaml
+
+# Audit Deployments and Services running in a cluster
+celery validate --cluster --context prod --resources deployments,services --rule-file "rules/*.yaml"
+
+# Audit everything in one namespace
+celery validate --cluster -n payments --rule-file "rules/*.yaml"
+
+# Show which container and which clause failed a rule
+celery validate deployment.yaml -e "object.spec.template.spec.containers.all(c, has(c.resources.limits) && c.image.contains('@sha256'))" --explain
+
+# Check that a change to the manifests only makes allowed transitions
+celery validate --old base/ --new feature/ --rule-file transition-rules.yaml
+
+# Catch misspelt fields in rules, including rules for custom resources
+celery validate manifests/ --rule-file "rules/*.yaml" --typecheck --crd crds/
+
+# Stop expensive cross-resource rules on a large bundle
+celery validate bundle.yaml --rule-file "rules/*.yaml" --cost-budget 100000000 --timeout 2s
+
+# Check references between resources spread across many files
+celery validate manifests/ --rule-file cross-resource-rules.yaml --scope all
+
+# Fail on warnings as well as errors
+celery validate manifests/ --rule-file validation-rules.yaml --fail-on warning
+
 # Validate only Deployments
 celery validate resources.yaml -e "object.spec.replicas >= 3" --target-kind Deployment
 
@@ -76,21 +224,42 @@ celery validate resources.yaml -e "has(object.spec.parallelism)" --target-group
 # Combine multiple selectors (all must match)
 celery validate resources.yaml -e "object.spec.replicas >= 3" --target-kind Deployment --target-labels "environment=prod"`,
 	RunE: func(_ *cobra.Command, args []string) error {
-		return validate.Validate(
-			context.Background(),
-			args,
-			celExpression,
-			ruleFiles,
-			verbose,
-			maxWorkers,
-			targetGroup,
-			targetVersion,
-			targetKind,
-			targetName,
-			targetNamespace,
-			targetLabelSelector,
-			targetAnnotationSelector,
-		)
+		return validate.Validate(context.Background(), validate.Options{
+			Files:                    args,
+			Include:                  includeGlobs,
+			Exclude:                  excludeGlobs,
+			Expression:               celExpression,
+			RuleFiles:                ruleFiles,
+			Presets:                  presetNames,
+			Params:                   paramsFile,
+			Verbose:                  verbose,
+			Explain:                  explain,
+			MaxWorkers:               maxWorkers,
+			Output:                   outputFormat,
+			FailOn:                   failOn,
+			Baseline:                 baselineFile,
+			WriteBaseline:            writeBaseline,
+			Cluster:                  clusterMode,
+			Kubeconfig:               kubeconfig,
+			Context:                  kubeContext,
+			Namespace:                clusterNamespace,
+			Resources:                clusterResources,
+			Old:                      oldManifests,
+			New:                      newManifests,
+			TypeCheck:                typeCheck,
+			CRDs:                     crdFiles,
+			CostLimit:                costLimit,
+			CostBudget:               costBudget,
+			Timeout:                  evalTimeout,
+			Scope:                    allObjectsScope,
+			TargetGroup:              targetGroup,
+			TargetVersion:            targetVersion,
+			TargetKind:               targetKind,
+			TargetName:               targetName,
+			TargetNamespace:          targetNamespace,
+			TargetLabelSelector:      targetLabelSelector,
+			TargetAnnotationSelector: targetAnnotationSelector,
+		})
 	},
 }
 
@@ -99,8 +268,30 @@ func init() {
 
 	validateCmd.Flags().StringVarP(&celExpression, "expression", "e", "", "CEL expression to validate resources")
 	validateCmd.Flags().StringSliceVarP(&ruleFiles, "rule-file", "r", []string{}, "YAML files containing validation rules (supports globs when quoted, can be specified multiple times)")
+	validateCmd.Flags().StringSliceVar(&presetNames, "preset", []string{}, "Embedded rule packs to load, see celery presets list (can be specified multiple times)")
+	validateCmd.Flags().StringVar(&paramsFile, "params", "", "YAML or JSON file that rules see as params, unless their rule file sets spec.paramsRef")
 	validateCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Show all validation results including passes")
-	validateCmd.Flags().IntVar(&maxWorkers, "max-workers", defaultWorkers, "Maximum number of parallel workers for multi-file validation")
+	validateCmd.Flags().BoolVar(&explain, "explain", false, "Show the values of the subexpressions that made failing rules false")
+	validateCmd.Flags().StringSliceVar(&includeGlobs, "include", []string{}, "Only validate files matching these globs when walking directories (can be specified multiple times)")
+	validateCmd.Flags().StringSliceVar(&excludeGlobs, "exclude", []string{}, "Skip files matching these globs when walking directories (can be specified multiple times)")
+	validateCmd.Flags().StringVarP(&outputFormat, "output", "o", "text", "Output format: text, json, yaml, sarif, or junit")
+	validateCmd.Flags().StringVar(&failOn, "fail-on", "error", "Lowest rule severity that fails the run: error, warning, or info")
+	validateCmd.Flags().StringVar(&baselineFile, "baseline", "", "Baseline file of known failures that do not fail the run")
+	validateCmd.Flags().StringVar(&writeBaseline, "write-baseline", "", "Record every current failure to this baseline file and exit successfully")
+	validateCmd.Flags().BoolVar(&clusterMode, "cluster", false, "Validate objects in a live cluster instead of files")
+	validateCmd.Flags().StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use with --cluster")
+	validateCmd.Flags().StringVar(&kubeContext, "context", "", "Kubeconfig context to use with --cluster")
+	validateCmd.Flags().StringVarP(&clusterNamespace, "namespace", "n", "", "Only validate cluster objects in this namespace (default every namespace)")
+	validateCmd.Flags().StringSliceVar(&clusterResources, "resources", []string{"all"}, "Resource types to list with --cluster, e.g. deployments,services.v1, or \"all\" for every listable type")
+	validateCmd.Flags().StringSliceVar(&oldManifests, "old", []string{}, "Previous version of the manifests, files or directories, used as oldObject")
+	validateCmd.Flags().StringSliceVar(&newManifests, "new", []string{}, "New version of the manifests, files or directories, validated against --old")
+	validateCmd.Flags().BoolVar(&typeCheck, "typecheck", false, "Type check rules against the OpenAPI schemas of the kinds they target")
+	validateCmd.Flags().StringSliceVar(&crdFiles, "crd", []string{}, "CustomResourceDefinition files or directories whose schemas are used for type checking (implies --typecheck)")
+	validateCmd.Flags().Uint64Var(&costLimit, "cost-limit", validator.DefaultCostLimit, "CEL cost a single rule evaluation may use, unless the rule sets costLimit")
+	validateCmd.Flags().Uint64Var(&costBudget, "cost-budget", 0, "CEL cost every rule evaluation may use together (0 for no budget)")
+	validateCmd.Flags().DurationVar(&evalTimeout, "timeout", 0, "How long a single rule evaluation may take, e.g. 500ms (0 for no timeout)")
+	validateCmd.Flags().StringVar(&allObjectsScope, "scope", "file", "Resources rules see as allObjects unless they set scope: file (same input file) or all (every input)")
+	validateCmd.Flags().IntVar(&maxWorkers, "max-workers", defaultWorkers, "Maximum number of files read and rule evaluations run at once")
 
 	validateCmd.Flags().StringVar(&targetGroup, "target-group", "", "Target resources by API group (e.g., apps, batch)")
 	validateCmd.Flags().StringVar(&targetVersion, "target-version", "", "Target resources by API version (e.g., v1, v1beta1)")
@@ -111,5 +302,9 @@ func init() {
 	validateCmd.Flags().StringVar(&targetAnnotationSelector, "target-annotations", "", "Target resources by annotation selector")
 
 	validateCmd.MarkFlagsMutuallyExclusive("expression", "rule-file")
-	validateCmd.MarkFlagsOneRequired("expression", "rule-file")
+	validateCmd.MarkFlagsMutuallyExclusive("expression", "preset")
+	validateCmd.MarkFlagsOneRequired("expression", "rule-file", "preset")
+	validateCmd.MarkFlagsRequiredTogether("old", "new")
+	validateCmd.MarkFlagsMutuallyExclusive("cluster", "old")
+	validateCmd.MarkFlagsMutuallyExclusive("cluster", "new")
 }
@@ -0,0 +1,168 @@
+package baseline
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/validator"
+	goyaml "gopkg.in/yaml.v3"
+)
+
+// Kind is the kind of a baseline file.
+const Kind = "Baseline"
+
+// Entry is the fingerprint of a known failure: the rule, the rule file it came
+// from, and the resource it failed on.
+type Entry struct {
+	RuleFile   string `yaml:"ruleFile"`
+	Rule       string `yaml:"rule"`
+	APIVersion string `yaml:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty"`
+	Namespace  string `yaml:"namespace,omitempty"`
+	Name       string `yaml:"name,omitempty"`
+}
+
+func (e Entry) String() string {
+	resource := e.Kind + "/" + e.Name
+	if e.Namespace != "" {
+		resource = e.Namespace + "/" + resource
+	}
+	if e.APIVersion != "" {
+		resource = e.APIVersion + " " + resource
+	}
+	return fmt.Sprintf("[%s] %s (%s)", e.Rule, resource, e.RuleFile)
+}
+
+// Baseline is a set of known failures that should not fail a run.
+type Baseline struct {
+	APIVersion string  `yaml:"apiVersion"`
+	Kind       string  `yaml:"kind"`
+	Entries    []Entry `yaml:"entries"`
+}
+
+// EntryFor returns the fingerprint of a result. Rule file paths are cleaned so
+// "./rules.yaml" and "rules.yaml" match.
+func EntryFor(result validator.ValidationResult) Entry {
+	return Entry{
+		RuleFile:   cleanPath(result.RuleFile),
+		Rule:       result.RuleName,
+		APIVersion: result.ResourceAPIVersion,
+		Kind:       result.ResourceKind,
+		Namespace:  result.ResourceNamespace,
+		Name:       result.ResourceName,
+	}
+}
+
+// FromResults records every rule failure in results. Results that are not tied
+// to a rule, such as unreadable input files, are never baselined.
+func FromResults(results []validator.ValidationResult) Baseline {
+	b := Baseline{APIVersion: apiv1.GroupVersion, Kind: Kind, Entries: []Entry{}}
+	seen := make(map[Entry]bool)
+	for _, result := range results {
+		if result.Valid || result.Skipped || result.RuleName == "" {
+			continue
+		}
+		entry := EntryFor(result)
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		b.Entries = append(b.Entries, entry)
+	}
+
+	sort.Slice(b.Entries, func(i, j int) bool {
+		return b.Entries[i].String() < b.Entries[j].String()
+	})
+	return b
+}
+
+// Matcher applies a baseline to results one at a time, as they are streamed.
+type Matcher struct {
+	entries []Entry
+	matched map[Entry]bool
+}
+
+// Matcher returns a Matcher for the entries of b.
+func (b Baseline) Matcher() *Matcher {
+	m := &Matcher{entries: b.Entries, matched: make(map[Entry]bool, len(b.Entries))}
+	for _, entry := range b.Entries {
+		m.matched[entry] = false
+	}
+	return m
+}
+
+// Match marks result as Baselined if it is a failure recorded in the baseline.
+func (m *Matcher) Match(result *validator.ValidationResult) {
+	if result.Valid || result.RuleName == "" {
+		return
+	}
+	entry := EntryFor(*result)
+	if _, ok := m.matched[entry]; ok {
+		m.matched[entry] = true
+		result.Baselined = true
+	}
+}
+
+// Stale returns the entries that have not matched any failure.
+func (m *Matcher) Stale() []Entry {
+	var stale []Entry
+	for _, entry := range m.entries {
+		if !m.matched[entry] {
+			stale = append(stale, entry)
+		}
+	}
+	return stale
+}
+
+// Write serialises the baseline as YAML.
+func (b Baseline) Write(w io.Writer) error {
+	encoder := goyaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(b); err != nil {
+		return fmt.Errorf("encoding baseline: %w", err)
+	}
+	return encoder.Close()
+}
+
+// Save writes the baseline to path.
+func (b Baseline) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating baseline: %w", err)
+	}
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads a baseline file.
+func Load(path string) (Baseline, error) {
+	var b Baseline
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return b, fmt.Errorf("reading baseline: %w", err)
+	}
+	if err := goyaml.Unmarshal(data, &b); err != nil {
+		return b, fmt.Errorf("decoding baseline %s: %w", path, err)
+	}
+	if b.Kind != Kind {
+		return b, fmt.Errorf("decoding baseline %s: kind must be %s", path, Kind)
+	}
+	for i := range b.Entries {
+		b.Entries[i].RuleFile = cleanPath(b.Entries[i].RuleFile)
+	}
+	return b, nil
+}
+
+func cleanPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
@@ -0,0 +1,93 @@
+package baseline
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RRethy/kube-tools/celery/pkg/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func failure(rule, namespace, name string) validator.ValidationResult {
+	return validator.ValidationResult{
+		InputFile:          "manifests.yaml",
+		RuleFile:           "./rules/replicas.yaml",
+		RuleName:           rule,
+		ResourceAPIVersion: "apps/v1",
+		ResourceKind:       "Deployment",
+		ResourceNamespace:  namespace,
+		ResourceName:       name,
+		Err:                errors.New("failed"),
+	}
+}
+
+func TestFromResults(t *testing.T) {
+	results := []validator.ValidationResult{
+		failure("minimum-replicas", "prod", "web"),
+		failure("minimum-replicas", "prod", "web"),
+		failure("minimum-replicas", "", "api"),
+		{RuleName: "passing", Valid: true},
+		{RuleName: "skipped", Valid: true, Skipped: true},
+		{InputFile: "broken.yaml", Err: errors.New("reading resources from file")},
+	}
+
+	b := FromResults(results)
+	assert.Equal(t, Kind, b.Kind)
+	assert.Equal(t, []Entry{
+		{RuleFile: "rules/replicas.yaml", Rule: "minimum-replicas", APIVersion: "apps/v1", Kind: "Deployment", Name: "api"},
+		{RuleFile: "rules/replicas.yaml", Rule: "minimum-replicas", APIVersion: "apps/v1", Kind: "Deployment", Namespace: "prod", Name: "web"},
+	}, b.Entries)
+}
+
+func TestMatcher(t *testing.T) {
+	b := FromResults([]validator.ValidationResult{
+		failure("minimum-replicas", "prod", "web"),
+		failure("minimum-replicas", "prod", "fixed"),
+	})
+
+	results := []validator.ValidationResult{
+		failure("minimum-replicas", "prod", "web"),
+		failure("minimum-replicas", "prod", "new"),
+		failure("minimum-replicas", "staging", "web"),
+	}
+	results[0].RuleFile = "rules/replicas.yaml"
+
+	m := b.Matcher()
+	for i := range results {
+		m.Match(&results[i])
+	}
+	stale := m.Stale()
+	assert.True(t, results[0].Baselined, "known failure should be baselined")
+	assert.False(t, results[1].Baselined, "new resource should not be baselined")
+	assert.False(t, results[2].Baselined, "namespace is part of the fingerprint")
+
+	require.Len(t, stale, 1)
+	assert.Equal(t, "fixed", stale[0].Name)
+	assert.Equal(t, "[minimum-replicas] apps/v1 prod/Deployment/fixed (rules/replicas.yaml)", stale[0].String())
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.yaml")
+	b := FromResults([]validator.ValidationResult{failure("minimum-replicas", "prod", "web")})
+	require.NoError(t, b.Save(path))
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, b, loaded)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, b.Write(out))
+	assert.Contains(t, out.String(), "kind: Baseline")
+	assert.Contains(t, out.String(), "ruleFile: rules/replicas.yaml")
+
+	require.NoError(t, os.WriteFile(path, []byte("kind: ValidationRules\n"), 0o644))
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "kind must be Baseline")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "reading baseline")
+}
@@ -0,0 +1,21 @@
+package convert
+
+import (
+	"context"
+	"os"
+
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func Convert(ctx context.Context, opts Options) error {
+	ioStreams := genericiooptions.IOStreams{
+		In:     os.Stdin,
+		Out:    os.Stdout,
+		ErrOut: os.Stderr,
+	}
+
+	c := &Converter{
+		IOStreams: ioStreams,
+	}
+	return c.Convert(opts)
+}
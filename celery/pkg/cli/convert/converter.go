@@ -0,0 +1,159 @@
+package convert
+
+import (
+	"fmt"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/convert"
+	"github.com/RRethy/kube-tools/celery/pkg/input"
+	goyaml "gopkg.in/yaml.v3"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+const admissionGroup = "admissionregistration.k8s.io"
+
+type Converter struct {
+	IOStreams genericiooptions.IOStreams
+}
+
+// Options configures a conversion run.
+type Options struct {
+	// Files are the files or directories to convert. Stdin is read when empty or "-".
+	Files []string
+	// Strict fails the conversion when anything could not be converted exactly.
+	Strict bool
+}
+
+// Convert reads ValidationRules, ValidatingAdmissionPolicies and their bindings,
+// converting each to the other format. Converted resources are written to Out as
+// multi-document YAML and anything that could not be converted exactly is
+// reported on ErrOut.
+func (c *Converter) Convert(opts Options) error {
+	files, err := input.Expand(opts.Files, nil, nil)
+	if err != nil {
+		return fmt.Errorf("resolving input files: %w", err)
+	}
+
+	var ruless []apiv1.ValidationRules
+	var policies []admissionregistrationv1.ValidatingAdmissionPolicy
+	var bindings []admissionregistrationv1.ValidatingAdmissionPolicyBinding
+	var issues []convert.Issue
+
+	for _, file := range files {
+		resources, err := input.ReadResources(file, c.IOStreams.In)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", input.Name(file), err)
+		}
+
+		for _, resource := range resources {
+			gvk := resource.GroupVersionKind()
+			switch {
+			case gvk.Kind == apiv1.ValidationRulesKind:
+				rules, err := toValidationRules(resource)
+				if err != nil {
+					return fmt.Errorf("decoding ValidationRules %s in %s: %w", resource.GetName(), input.Name(file), err)
+				}
+				rules.Filename = input.Name(file)
+				ruless = append(ruless, rules)
+			case gvk.Group == admissionGroup && gvk.Kind == "ValidatingAdmissionPolicy":
+				var policy admissionregistrationv1.ValidatingAdmissionPolicy
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(resource.Object, &policy); err != nil {
+					return fmt.Errorf("decoding ValidatingAdmissionPolicy %s in %s: %w", resource.GetName(), input.Name(file), err)
+				}
+				policies = append(policies, policy)
+			case gvk.Group == admissionGroup && gvk.Kind == "ValidatingAdmissionPolicyBinding":
+				var binding admissionregistrationv1.ValidatingAdmissionPolicyBinding
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(resource.Object, &binding); err != nil {
+					return fmt.Errorf("decoding ValidatingAdmissionPolicyBinding %s in %s: %w", resource.GetName(), input.Name(file), err)
+				}
+				bindings = append(bindings, binding)
+			default:
+				issues = append(issues, convert.Issue{
+					Source:  fmt.Sprintf("%s/%s", gvk.Kind, resource.GetName()),
+					Message: "not a ValidationRules or ValidatingAdmissionPolicy resource, skipped",
+				})
+			}
+		}
+	}
+
+	if len(ruless) == 0 && len(policies) == 0 {
+		return fmt.Errorf("no ValidationRules or ValidatingAdmissionPolicy resources found")
+	}
+
+	var docs []any
+	if len(policies) > 0 || len(bindings) > 0 {
+		converted, policyIssues := convert.FromPolicies(policies, bindings)
+		issues = append(issues, policyIssues...)
+		for _, rules := range converted {
+			docs = append(docs, rules)
+		}
+	}
+	if len(ruless) > 0 {
+		converted, convertedBindings, rulesIssues := convert.ToPolicies(ruless)
+		issues = append(issues, rulesIssues...)
+		for i := range converted {
+			doc, err := toDocument(&converted[i])
+			if err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+		}
+		for i := range convertedBindings {
+			doc, err := toDocument(&convertedBindings[i])
+			if err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+		}
+	}
+
+	encoder := goyaml.NewEncoder(c.IOStreams.Out)
+	encoder.SetIndent(2)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return fmt.Errorf("writing YAML: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("writing YAML: %w", err)
+	}
+
+	for _, issue := range issues {
+		fmt.Fprintf(c.IOStreams.ErrOut, "⚠️  %s\n", issue)
+	}
+	if opts.Strict && len(issues) > 0 {
+		return fmt.Errorf("conversion reported %d issue(s)", len(issues))
+	}
+
+	return nil
+}
+
+// toValidationRules decodes an unstructured resource through YAML so the
+// ValidationRules yaml tags are honoured.
+func toValidationRules(resource *unstructured.Unstructured) (apiv1.ValidationRules, error) {
+	var rules apiv1.ValidationRules
+	data, err := goyaml.Marshal(resource.Object)
+	if err != nil {
+		return rules, err
+	}
+	if err := goyaml.Unmarshal(data, &rules); err != nil {
+		return rules, err
+	}
+	rules.APIVersion = resource.GetAPIVersion()
+	return rules, nil
+}
+
+// toDocument converts a typed Kubernetes object to a map for YAML output,
+// dropping the empty creationTimestamp and status every typed object carries.
+func toDocument(obj runtime.Object) (map[string]any, error) {
+	doc, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("converting %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, err)
+	}
+	unstructured.RemoveNestedField(doc, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(doc, "status")
+	return doc, nil
+}
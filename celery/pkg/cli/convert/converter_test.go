@@ -0,0 +1,94 @@
+package convert
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func newTestConverter(stdin string) (*Converter, *bytes.Buffer, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	return &Converter{
+		IOStreams: genericiooptions.IOStreams{
+			In:     strings.NewReader(stdin),
+			Out:    out,
+			ErrOut: errOut,
+		},
+	}, out, errOut
+}
+
+func TestConverterFromPolicies(t *testing.T) {
+	c, out, errOut := newTestConverter("")
+	err := c.Convert(Options{
+		Files: []string{filepath.Join("..", "..", "..", "fixtures", "admission", "replica-policy.yaml")},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, errOut.String())
+
+	ruless, err := yaml.ParseYAMLToValidationRules(out.Bytes(), "converted.yaml")
+	require.NoError(t, err)
+	require.Len(t, ruless, 1)
+	assert.Equal(t, "replica-limits", ruless[0].Name)
+	require.Len(t, ruless[0].Spec.Rules, 2)
+	assert.Equal(t, "production", ruless[0].Spec.Rules[0].Target.Namespace)
+	assert.Equal(t, "warning", string(ruless[0].Spec.Rules[0].Severity))
+	assert.Contains(t, out.String(), "apiVersion: celery.rrethy.io/v1")
+	assert.NotContains(t, out.String(), "filename")
+}
+
+func TestConverterToPolicies(t *testing.T) {
+	c, out, _ := newTestConverter("")
+	err := c.Convert(Options{
+		Files: []string{filepath.Join("..", "..", "..", "fixtures", "rules", "namespace-policies.yaml")},
+	})
+	require.NoError(t, err)
+
+	got := out.String()
+	assert.Contains(t, got, "kind: ValidatingAdmissionPolicy\n")
+	assert.Contains(t, got, "kind: ValidatingAdmissionPolicyBinding\n")
+	assert.Contains(t, got, "kubernetes.io/metadata.name: production")
+	assert.NotContains(t, got, "creationTimestamp")
+	assert.NotContains(t, got, "status:")
+}
+
+func TestConverterStdin(t *testing.T) {
+	stdin := `apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: stdin-rules
+spec:
+  rules:
+    - name: unique-names
+      expression: allObjects.all(o, o == object || o.metadata.name != object.metadata.name)
+      target:
+        annotationSelector: owner=platform
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+`
+	c, out, errOut := newTestConverter(stdin)
+	require.NoError(t, c.Convert(Options{}))
+	assert.Contains(t, out.String(), "name: stdin-rules-binding")
+	assert.Contains(t, errOut.String(), "ConfigMap/ignored")
+	assert.Contains(t, errOut.String(), "allObjects")
+	assert.Contains(t, errOut.String(), "annotationSelector")
+
+	c, _, _ = newTestConverter(stdin)
+	err := c.Convert(Options{Strict: true})
+	assert.ErrorContains(t, err, "conversion reported 3 issue(s)")
+}
+
+func TestConverterNothingToConvert(t *testing.T) {
+	c, _, _ := newTestConverter("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n")
+	err := c.Convert(Options{})
+	assert.ErrorContains(t, err, "no ValidationRules or ValidatingAdmissionPolicy resources found")
+}
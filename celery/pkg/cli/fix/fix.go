@@ -0,0 +1,21 @@
+package fix
+
+import (
+	"context"
+	"os"
+
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func Fix(ctx context.Context, opts Options) error {
+	ioStreams := genericiooptions.IOStreams{
+		In:     os.Stdin,
+		Out:    os.Stdout,
+		ErrOut: os.Stderr,
+	}
+
+	f := &Fixer{
+		IOStreams: ioStreams,
+	}
+	return f.Fix(ctx, opts)
+}
@@ -0,0 +1,199 @@
+package fix
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/input"
+	"github.com/RRethy/kube-tools/celery/pkg/patch"
+	"github.com/RRethy/kube-tools/celery/pkg/presets"
+	"github.com/RRethy/kube-tools/celery/pkg/rules"
+	"github.com/RRethy/kube-tools/celery/pkg/validator"
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+type Fixer struct {
+	IOStreams genericiooptions.IOStreams
+}
+
+// Options configures a fix run.
+type Options struct {
+	// Files are the manifest files or directories to fix. Stdin is read when
+	// empty or "-", and the fixed manifests are written to Out.
+	Files []string
+	// Include and Exclude are globs that filter files found while walking directories.
+	Include []string
+	Exclude []string
+
+	RuleFiles []string
+	// Presets are the names of embedded rule packs, loaded after RuleFiles.
+	Presets []string
+	// Params is a YAML or JSON file that rules without a paramsRef see as params.
+	Params string
+	// Scope is what rules that do not set a scope see as allObjects: file
+	// (default) for the resources of the same input, or all for every input.
+	Scope string
+	// DryRun prints a unified diff of the fixes instead of writing them.
+	DryRun bool
+}
+
+// source is an input file being fixed.
+type source struct {
+	path string
+	data []byte
+	file *yaml.File
+}
+
+// Fix applies the fixes of the rules in opts.RuleFiles and opts.Presets to the resources
+// failing them, rewriting each file that changed. Every fix and every failure
+// that could not be fixed is listed on ErrOut.
+func (f *Fixer) Fix(ctx context.Context, opts Options) error {
+	scope, err := apiv1.ParseScope(opts.Scope)
+	if err != nil {
+		return fmt.Errorf("invalid --scope: %w", err)
+	}
+
+	ruless, err := rules.Load(append(slices.Clone(opts.RuleFiles), presets.Paths(opts.Presets)...))
+	if err != nil {
+		return err
+	}
+	if len(ruless) == 0 {
+		return fmt.Errorf("no validation rules provided")
+	}
+
+	val := &validator.Validator{Scope: scope}
+	if opts.Params != "" {
+		val.Params, err = rules.LoadParams(opts.Params)
+		if err != nil {
+			return fmt.Errorf("invalid --params: %w", err)
+		}
+	}
+	parsedRules, err := val.CompileRules(ruless)
+	if err != nil {
+		return fmt.Errorf("fix failed: %w", err)
+	}
+
+	files, err := input.Expand(opts.Files, opts.Include, opts.Exclude)
+	if err != nil {
+		return fmt.Errorf("resolving input files: %w", err)
+	}
+
+	// Every file is parsed before anything is fixed, so that rules with the
+	// all scope see the resources of every file.
+	var sources []source
+	var global []map[string]any
+	for _, path := range files {
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			fmt.Fprintf(f.IOStreams.ErrOut, "warning: skipping %s: JSON files cannot be fixed\n", path)
+			continue
+		}
+		data, err := input.Read(path, f.IOStreams.In)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", input.Name(path), err)
+		}
+		file, err := yaml.ParseFile(data)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", input.Name(path), err)
+		}
+		for _, doc := range file.Documents() {
+			global = append(global, doc.Resource.Object)
+		}
+		sources = append(sources, source{path: path, data: data, file: file})
+	}
+
+	fixed, unfixed := 0, 0
+	for _, src := range sources {
+		name := input.Name(src.path)
+		docs := src.file.Documents()
+		objects, results := val.FixFile(ctx, name, docs, global, parsedRules)
+
+		changed := false
+		for _, result := range results {
+			if result.Fixed {
+				fixed++
+				changed = true
+				// Err is the failure that was fixed, which is empty for rules without a message.
+				fixedMessage := "fixed"
+				if msg := result.Err.Error(); msg != "" {
+					fixedMessage += ": " + msg
+				}
+				fmt.Fprintf(f.IOStreams.ErrOut, "🔧 [%s] %s/%s: %s%s\n", result.RuleName, result.ResourceKind, result.ResourceName, fixedMessage, result.LocationSuffix())
+			} else {
+				unfixed++
+				fmt.Fprintf(f.IOStreams.ErrOut, "❌ [%s] %s/%s: %v%s\n", result.RuleName, result.ResourceKind, result.ResourceName, result.Err, result.LocationSuffix())
+			}
+		}
+
+		for i, obj := range objects {
+			if changed && !patch.Equal(obj, docs[i].Resource.Object) {
+				if err := src.file.Set(i, obj); err != nil {
+					return fmt.Errorf("fixing %s: %w", name, err)
+				}
+			}
+		}
+		if err := f.write(src, name, changed, opts.DryRun); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(f.IOStreams.ErrOut, "fixed %d failures, %d could not be fixed\n", fixed, unfixed)
+	if unfixed > 0 {
+		return fmt.Errorf("%d failures could not be fixed", unfixed)
+	}
+	return nil
+}
+
+// write writes a fixed source back to where it was read from, or prints a
+// diff of it when dryRun is set. Stdin is always written to Out.
+func (f *Fixer) write(src source, name string, changed bool, dryRun bool) error {
+	if !changed && src.path != input.Stdin {
+		return nil
+	}
+
+	data := src.data
+	if changed {
+		var err error
+		if data, err = src.file.Bytes(); err != nil {
+			return fmt.Errorf("encoding %s: %w", name, err)
+		}
+	}
+
+	if dryRun {
+		if !changed {
+			return nil
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(src.data)),
+			B:        difflib.SplitLines(string(data)),
+			FromFile: name + ".orig",
+			ToFile:   name,
+			Context:  3,
+		})
+		if err != nil {
+			return fmt.Errorf("diffing %s: %w", name, err)
+		}
+		fmt.Fprint(f.IOStreams.Out, diff)
+		return nil
+	}
+
+	if src.path == input.Stdin {
+		_, err := f.IOStreams.Out.Write(data)
+		return err
+	}
+
+	info, err := os.Stat(src.path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(src.path, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package fix
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+var fixRules = filepath.Join("..", "..", "..", "fixtures", "rules", "fix-rules.yaml")
+
+func newTestFixer(stdin string) (*Fixer, *bytes.Buffer, *bytes.Buffer) {
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	return &Fixer{
+		IOStreams: genericiooptions.IOStreams{
+			In:     strings.NewReader(stdin),
+			Out:    out,
+			ErrOut: errOut,
+		},
+	}, out, errOut
+}
+
+// copyFixture copies the deployments to fix into a temporary directory.
+func copyFixture(t *testing.T) (string, []byte) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "..", "fixtures", "fixes", "deployments.yaml"))
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "deployments.yaml")
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+	return file, data
+}
+
+func TestFixerFix(t *testing.T) {
+	file, original := copyFixture(t)
+
+	fixer, out, errOut := newTestFixer("")
+	err := fixer.Fix(context.Background(), Options{Files: []string{file}, RuleFiles: []string{fixRules}})
+	assert.EqualError(t, err, "1 failures could not be fixed")
+	assert.Empty(t, out.String())
+
+	log := errOut.String()
+	assert.Contains(t, log, "🔧 [app-label] Deployment/web: fixed: Deployments must have an app label ("+file+":4:1)")
+	assert.Contains(t, log, "🔧 [minimum-replicas] Deployment/web")
+	assert.Contains(t, log, "🔧 [run-as-non-root] Deployment/web")
+	assert.Contains(t, log, "❌ [no-latest-tag] Deployment/worker: Containers must not use the latest tag")
+	assert.Contains(t, log, "fixed 3 failures, 1 could not be fixed")
+
+	fixed, err := os.ReadFile(file)
+	require.NoError(t, err)
+	// The Deployments that were not fixed are kept byte for byte.
+	_, untouched, _ := strings.Cut(string(original), "# Already follows the standards.")
+	assert.True(t, strings.HasSuffix(string(fixed), untouched))
+	assert.Contains(t, string(fixed), `# Frontend of the shop.
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+  labels:
+    app: web
+spec:
+  replicas: 2 # scaled up for the sale
+`)
+	assert.Contains(t, string(fixed), `            - containerPort: 8080
+      securityContext:
+        runAsNonRoot: true
+---
+`)
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "the file mode should be kept")
+
+	// Fixing again only leaves the failure without a fix.
+	fixer, _, errOut = newTestFixer("")
+	err = fixer.Fix(context.Background(), Options{Files: []string{file}, RuleFiles: []string{fixRules}})
+	assert.EqualError(t, err, "1 failures could not be fixed")
+	assert.NotContains(t, errOut.String(), "🔧")
+	again, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, string(fixed), string(again))
+}
+
+func TestFixerDryRun(t *testing.T) {
+	file, original := copyFixture(t)
+
+	fixer, out, _ := newTestFixer("")
+	err := fixer.Fix(context.Background(), Options{Files: []string{file}, RuleFiles: []string{fixRules}, DryRun: true})
+	assert.Error(t, err)
+
+	assert.Contains(t, out.String(), "--- "+file+".orig\n+++ "+file+"\n")
+	assert.Contains(t, out.String(), `@@ -4,8 +4,10 @@
+ metadata:
+   name: web
+   namespace: shop
++  labels:
++    app: web
+ spec:
+-  replicas: 1 # scaled up for the sale
++  replicas: 2 # scaled up for the sale
+`)
+
+	after, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, string(original), string(after), "dry runs should not write anything")
+}
+
+func TestFixerStdin(t *testing.T) {
+	stdin := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      securityContext:
+        runAsNonRoot: true
+      containers:
+        - name: web
+          image: web:v1
+`
+	fixer, out, _ := newTestFixer(stdin)
+	err := fixer.Fix(context.Background(), Options{RuleFiles: []string{fixRules}})
+	require.NoError(t, err)
+	assert.Equal(t, stdin, out.String(), "stdin should be written out even when nothing is fixed")
+
+	fixer, out, _ = newTestFixer(strings.Replace(stdin, "replicas: 3", "replicas: 1", 1))
+	err = fixer.Fix(context.Background(), Options{RuleFiles: []string{fixRules}})
+	require.NoError(t, err)
+	assert.Equal(t, stdin, strings.Replace(out.String(), "replicas: 2", "replicas: 3", 1))
+	assert.Contains(t, out.String(), "replicas: 2")
+}
+
+func TestFixerErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalidRules := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, os.WriteFile(invalidRules, []byte(`apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: invalid
+spec:
+  rules:
+    - name: bad
+      expression: "true"
+      fix:
+        patch:
+          - op: merge
+            path: /spec
+`), 0o644))
+	jsonFile := filepath.Join(dir, "deployment.json")
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "c"}}`), 0o644))
+
+	fixer, _, _ := newTestFixer("")
+	err := fixer.Fix(context.Background(), Options{Files: []string{jsonFile}, RuleFiles: []string{invalidRules}})
+	assert.ErrorContains(t, err, `invalid fix in rule 'bad'`)
+
+	fixer, _, errOut := newTestFixer("")
+	err = fixer.Fix(context.Background(), Options{Files: []string{jsonFile}, RuleFiles: []string{fixRules}})
+	require.NoError(t, err)
+	assert.Contains(t, errOut.String(), "warning: skipping "+jsonFile+": JSON files cannot be fixed")
+
+	fixer, _, _ = newTestFixer("")
+	err = fixer.Fix(context.Background(), Options{Files: []string{jsonFile}, RuleFiles: []string{fixRules}, Scope: "cluster"})
+	assert.ErrorContains(t, err, "invalid --scope")
+}
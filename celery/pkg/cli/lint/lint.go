@@ -0,0 +1,21 @@
+package lint
+
+import (
+	"context"
+	"os"
+
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func Lint(ctx context.Context, opts Options) error {
+	ioStreams := genericiooptions.IOStreams{
+		In:     os.Stdin,
+		Out:    os.Stdout,
+		ErrOut: os.Stderr,
+	}
+
+	l := &Linter{
+		IOStreams: ioStreams,
+	}
+	return l.Lint(ctx, opts)
+}
@@ -0,0 +1,81 @@
+package lint
+
+import (
+	"context"
+	"fmt"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/input"
+	"github.com/RRethy/kube-tools/celery/pkg/rulelint"
+	"github.com/RRethy/kube-tools/celery/pkg/schemas"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+type Linter struct {
+	IOStreams genericiooptions.IOStreams
+}
+
+// Options configures a lint run.
+type Options struct {
+	// Files are rule files or directories to search for them. The current
+	// directory is searched when empty.
+	Files []string
+	// CRDs are CustomResourceDefinition files or directories whose kinds
+	// selectors may target, besides the built-in kinds.
+	CRDs []string
+}
+
+// Lint checks every ValidationRules found in opts.Files and prints a
+// diagnostic for each problem. It fails when any problem is an error.
+func (l *Linter) Lint(_ context.Context, opts Options) error {
+	paths := opts.Files
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := input.Expand(paths, nil, nil)
+	if err != nil {
+		return fmt.Errorf("resolving rule files: %w", err)
+	}
+
+	var sources []rulelint.Source
+	for _, file := range files {
+		data, err := input.Read(file, l.IOStreams.In)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", input.Name(file), err)
+		}
+		sources = append(sources, rulelint.Source{Filename: input.Name(file), Data: data})
+	}
+
+	resolver, err := schemas.NewResolver()
+	if err != nil {
+		return err
+	}
+	if err := resolver.LoadCRDs(opts.CRDs); err != nil {
+		return err
+	}
+
+	linter := &rulelint.Linter{Schemas: resolver}
+	diagnostics, err := linter.Lint(sources)
+	if err != nil {
+		return err
+	}
+
+	errs, warnings := 0, 0
+	for _, d := range diagnostics {
+		if d.Severity == apiv1.SeverityError {
+			errs++
+		} else {
+			warnings++
+		}
+		fmt.Fprintln(l.IOStreams.Out, d)
+	}
+
+	if errs > 0 {
+		return fmt.Errorf("lint failed: %d errors, %d warnings", errs, warnings)
+	}
+	if warnings > 0 {
+		fmt.Fprintf(l.IOStreams.ErrOut, "%d warnings\n", warnings)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package lint
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func newTestLinter() (*Linter, *bytes.Buffer, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	return &Linter{
+		IOStreams: genericiooptions.IOStreams{
+			In:     strings.NewReader(""),
+			Out:    out,
+			ErrOut: errOut,
+		},
+	}, out, errOut
+}
+
+func TestLinterLint(t *testing.T) {
+	linter, out, _ := newTestLinter()
+	dir := filepath.Join("..", "..", "..", "fixtures", "lint")
+	err := linter.Lint(context.Background(), Options{Files: []string{dir}})
+	assert.EqualError(t, err, "lint failed: 9 errors, 2 warnings")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 11)
+	assert.Equal(t, filepath.Join(dir, "problems.yaml")+":15:7: error: [replicas] duplicate rule name, first defined at "+filepath.Join(dir, "problems.yaml")+":10:7", lines[0])
+	assert.Equal(t, filepath.Join(dir, "problems.yaml")+":32:11: warning: [bad-selector] selector matches no known kind: Deploymnet", lines[5])
+}
+
+func TestLinterCRDs(t *testing.T) {
+	rules := `apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: widgets
+spec:
+  rules:
+    - name: widget-size
+      expression: "object.spec.size <= 10"
+      target:
+        group: example.com
+        kind: Widget
+`
+	crds := filepath.Join("..", "..", "..", "fixtures", "crds")
+
+	linter, out, errOut := newTestLinter()
+	linter.IOStreams.In = strings.NewReader(rules)
+	require.NoError(t, linter.Lint(context.Background(), Options{Files: []string{"-"}}))
+	assert.Contains(t, out.String(), "selector matches no known kind: example.com/Widget")
+	assert.Equal(t, "1 warnings\n", errOut.String())
+
+	linter, out, _ = newTestLinter()
+	linter.IOStreams.In = strings.NewReader(rules)
+	require.NoError(t, linter.Lint(context.Background(), Options{Files: []string{"-"}, CRDs: []string{crds}}))
+	assert.Empty(t, out.String())
+}
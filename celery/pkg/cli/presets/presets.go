@@ -0,0 +1,28 @@
+package presets
+
+import (
+	"context"
+	"os"
+
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func List(ctx context.Context) error {
+	return newPrinter().List(ctx)
+}
+
+func Show(ctx context.Context, opts ShowOptions) error {
+	return newPrinter().Show(ctx, opts)
+}
+
+func newPrinter() *Printer {
+	ioStreams := genericiooptions.IOStreams{
+		In:     os.Stdin,
+		Out:    os.Stdout,
+		ErrOut: os.Stderr,
+	}
+
+	return &Printer{
+		IOStreams: ioStreams,
+	}
+}
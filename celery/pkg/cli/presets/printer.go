@@ -0,0 +1,54 @@
+package presets
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	presetpacks "github.com/RRethy/kube-tools/celery/pkg/presets"
+	"github.com/RRethy/kube-tools/celery/pkg/rules"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+type Printer struct {
+	IOStreams genericiooptions.IOStreams
+}
+
+// ShowOptions configures which preset is shown.
+type ShowOptions struct {
+	Name string
+}
+
+// List prints a table of the embedded presets with the number of rules each
+// loads, including the rules of the presets it includes.
+func (p *Printer) List(_ context.Context) error {
+	presets, err := presetpacks.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(p.IOStreams.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tRULES\tDESCRIPTION")
+	for _, preset := range presets {
+		ruless, err := rules.Load([]string{presetpacks.Path(preset.Name)})
+		if err != nil {
+			return err
+		}
+		count := 0
+		for _, rules := range ruless {
+			count += len(rules.Spec.Rules)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", preset.Name, count, preset.Description)
+	}
+	return w.Flush()
+}
+
+// Show prints the ValidationRules YAML of the preset opts.Name as embedded.
+func (p *Printer) Show(_ context.Context, opts ShowOptions) error {
+	data, err := presetpacks.Read(opts.Name)
+	if err != nil {
+		return err
+	}
+	_, err = p.IOStreams.Out.Write(data)
+	return err
+}
@@ -0,0 +1,46 @@
+package presets
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func newTestPrinter() (*Printer, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &Printer{
+		IOStreams: genericiooptions.IOStreams{
+			In:     strings.NewReader(""),
+			Out:    out,
+			ErrOut: &bytes.Buffer{},
+		},
+	}, out
+}
+
+func TestPrinterList(t *testing.T) {
+	printer, out := newTestPrinter()
+	require.NoError(t, printer.List(context.Background()))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, []string{"NAME", "RULES", "DESCRIPTION"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"best-practices", "4"}, strings.Fields(lines[1])[:2])
+	assert.Equal(t, []string{"pod-security-baseline", "5"}, strings.Fields(lines[2])[:2])
+	assert.Equal(t, []string{"restricted", "10"}, strings.Fields(lines[3])[:2], "included rules should be counted")
+}
+
+func TestPrinterShow(t *testing.T) {
+	printer, out := newTestPrinter()
+	require.NoError(t, printer.Show(context.Background(), ShowOptions{Name: "restricted"}))
+	assert.Contains(t, out.String(), "kind: ValidationRules")
+	assert.Contains(t, out.String(), "- preset:pod-security-baseline")
+
+	printer, _ = newTestPrinter()
+	err := printer.Show(context.Background(), ShowOptions{Name: "strict"})
+	assert.ErrorContains(t, err, `unknown preset "strict"`)
+}
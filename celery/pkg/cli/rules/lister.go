@@ -0,0 +1,109 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/presets"
+	ruleloader "github.com/RRethy/kube-tools/celery/pkg/rules"
+	goyaml "gopkg.in/yaml.v3"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+type Lister struct {
+	IOStreams genericiooptions.IOStreams
+}
+
+// Options configures how rules are listed.
+type Options struct {
+	RuleFiles []string
+	// Presets are the names of embedded rule packs, loaded after RuleFiles.
+	Presets []string
+	// Resolved prints the merged rule set as ValidationRules YAML, with
+	// includes loaded and overrides applied, instead of a table of rules.
+	Resolved bool
+}
+
+// List prints the rules loaded from opts.RuleFiles and opts.Presets.
+func (l *Lister) List(_ context.Context, opts Options) error {
+	ruless, err := ruleloader.Load(append(slices.Clone(opts.RuleFiles), presets.Paths(opts.Presets)...))
+	if err != nil {
+		return err
+	}
+	if len(ruless) == 0 {
+		return fmt.Errorf("no validation rules provided")
+	}
+
+	if opts.Resolved {
+		encoder := goyaml.NewEncoder(l.IOStreams.Out)
+		encoder.SetIndent(2)
+		for _, rules := range ruless {
+			if err := encoder.Encode(rules); err != nil {
+				return fmt.Errorf("writing YAML: %w", err)
+			}
+		}
+		if err := encoder.Close(); err != nil {
+			return fmt.Errorf("writing YAML: %w", err)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(l.IOStreams.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tSEVERITY\tTARGET\tFILE")
+	for _, rules := range ruless {
+		for _, rule := range rules.Spec.Rules {
+			severity := rule.Severity
+			if severity == "" {
+				severity = apiv1.SeverityError
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", rule.Name, severity, targetsString(rule), rules.Filename)
+		}
+	}
+	return w.Flush()
+}
+
+// targetsString summarises the selectors of a rule, separated by ';', with
+// each exclude prefixed by '!', e.g. kind=Deployment;kind=StatefulSet;!name=*-canary.
+func targetsString(rule apiv1.ValidationRule) string {
+	var parts []string
+	for _, target := range rule.Selectors() {
+		parts = append(parts, targetString(target))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "*")
+	}
+	for _, exclude := range rule.Exclude {
+		parts = append(parts, "!"+targetString(exclude))
+	}
+	return strings.Join(parts, ";")
+}
+
+// targetString summarises a target selector as its fields that are set, e.g.
+// kind=Deployment,namespace=prod, or * when every resource is selected.
+func targetString(target apiv1.TargetSelector) string {
+	var parts []string
+	for _, field := range []struct{ name, value string }{
+		{"group", target.Group},
+		{"version", target.Version},
+		{"kind", target.Kind},
+		{"name", target.Name},
+		{"namePattern", target.NamePattern},
+		{"nameRegex", target.NameRegex},
+		{"namespace", target.Namespace},
+		{"namespaceSelector", target.NamespaceSelector},
+		{"labelSelector", target.LabelSelector},
+		{"annotationSelector", target.AnnotationSelector},
+	} {
+		if field.value != "" {
+			parts = append(parts, field.name+"="+field.value)
+		}
+	}
+	if len(parts) == 0 {
+		return "*"
+	}
+	return strings.Join(parts, ",")
+}
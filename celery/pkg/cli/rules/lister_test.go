@@ -0,0 +1,83 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+var teamRules = filepath.Join("..", "..", "..", "fixtures", "rules", "team-deployment-standards.yaml")
+
+func newTestLister() (*Lister, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &Lister{
+		IOStreams: genericiooptions.IOStreams{
+			In:     strings.NewReader(""),
+			Out:    out,
+			ErrOut: &bytes.Buffer{},
+		},
+	}, out
+}
+
+func TestListerList(t *testing.T) {
+	lister, out := newTestLister()
+	require.NoError(t, lister.List(context.Background(), Options{RuleFiles: []string{teamRules}}))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 5)
+	assert.Equal(t, []string{"RULE", "SEVERITY", "TARGET", "FILE"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{
+		"minimum-replicas-production",
+		"error",
+		"kind=Deployment,namespace=production,labelSelector=environment=production",
+		filepath.Join("..", "..", "..", "fixtures", "rules", "deployment-standards.yaml"),
+	}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"team-label", "warning", "kind=Deployment", teamRules}, strings.Fields(lines[4]))
+	assert.NotContains(t, out.String(), "probe-required", "disabled rules should not be listed")
+}
+
+func TestListerTargets(t *testing.T) {
+	lister, out := newTestLister()
+	namespaceRules := filepath.Join("..", "..", "..", "fixtures", "rules", "namespace-targets.yaml")
+	require.NoError(t, lister.List(context.Background(), Options{RuleFiles: []string{namespaceRules}}))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{
+		"production-replicas",
+		"error",
+		"kind=Deployment,namespaceSelector=environment=production;kind=StatefulSet,namespaceSelector=environment=production;!nameRegex=.*-canary",
+		namespaceRules,
+	}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"pinned-payments-images", "error", "kind=Deployment,namePattern=payments-*", namespaceRules}, strings.Fields(lines[2]))
+}
+
+func TestListerResolved(t *testing.T) {
+	lister, out := newTestLister()
+	require.NoError(t, lister.List(context.Background(), Options{RuleFiles: []string{teamRules}, Resolved: true}))
+	assert.NotContains(t, out.String(), "include")
+	assert.NotContains(t, out.String(), "overrides")
+
+	// The resolved rules load back as the same rule set.
+	ruless, err := yaml.ParseYAMLToValidationRules(out.Bytes(), "resolved.yaml")
+	require.NoError(t, err)
+	require.Len(t, ruless, 2)
+	assert.Equal(t, "deployment-standards", ruless[0].Name)
+	assert.Len(t, ruless[0].Spec.Rules, 3)
+	assert.Equal(t, "production", ruless[0].Spec.Rules[0].Target.Namespace)
+	assert.Equal(t, "team-deployment-standards", ruless[1].Name)
+	assert.Equal(t, "team-label", ruless[1].Spec.Rules[0].Name)
+}
+
+func TestListerErrors(t *testing.T) {
+	lister, _ := newTestLister()
+	err := lister.List(context.Background(), Options{RuleFiles: []string{"missing.yaml"}})
+	assert.ErrorContains(t, err, "loading validation rules from missing.yaml")
+}
@@ -0,0 +1,21 @@
+package rules
+
+import (
+	"context"
+	"os"
+
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func Rules(ctx context.Context, opts Options) error {
+	ioStreams := genericiooptions.IOStreams{
+		In:     os.Stdin,
+		Out:    os.Stdout,
+		ErrOut: os.Stderr,
+	}
+
+	l := &Lister{
+		IOStreams: ioStreams,
+	}
+	return l.List(ctx, opts)
+}
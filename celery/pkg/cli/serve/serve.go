@@ -0,0 +1,33 @@
+package serve
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/RRethy/kube-tools/celery/pkg/cluster"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func Serve(ctx context.Context, opts Options) error {
+	ioStreams := genericiooptions.IOStreams{
+		In:     os.Stdin,
+		Out:    os.Stdout,
+		ErrOut: os.Stderr,
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	configFlags := genericclioptions.NewConfigFlags(true)
+	configFlags.KubeConfig = &opts.Kubeconfig
+	configFlags.Context = &opts.Context
+
+	s := &Server{
+		IOStreams:       ioStreams,
+		NamespaceLabels: cluster.NewNamespaceCache(configFlags).Labels,
+	}
+	return s.Serve(ctx, opts)
+}
@@ -0,0 +1,109 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/RRethy/kube-tools/celery/pkg/presets"
+	"github.com/RRethy/kube-tools/celery/pkg/webhook"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+type Server struct {
+	IOStreams genericiooptions.IOStreams
+	// NamespaceLabels looks up the labels of the Namespace of an admission
+	// request for rules with a namespaceSelector.
+	NamespaceLabels func(ctx context.Context, name string) (map[string]string, error)
+}
+
+type Options struct {
+	RuleFiles      []string
+	Presets        []string
+	Params         string
+	Addr           string
+	TLSCertFile    string
+	TLSKeyFile     string
+	Insecure       bool
+	Audit          bool
+	ReloadInterval time.Duration
+	Kubeconfig     string
+	Context        string
+}
+
+// Serve runs the admission webhook until ctx is done. Logs are written to
+// ErrOut.
+func (s *Server) Serve(ctx context.Context, opts Options) error {
+	if len(opts.RuleFiles) == 0 && len(opts.Presets) == 0 {
+		return errors.New("at least one --rule-file or --preset is required")
+	}
+	if !opts.Insecure && (opts.TLSCertFile == "" || opts.TLSKeyFile == "") {
+		return errors.New("--tls-cert-file and --tls-private-key-file are required unless --insecure is set")
+	}
+
+	logger := slog.New(slog.NewTextHandler(s.IOStreams.ErrOut, nil))
+	handler := webhook.NewHandler(nil, opts.Audit, logger)
+	handler.Validator.NamespaceLabels = s.NamespaceLabels
+	reloader := &webhook.Reloader{
+		Patterns: append(slices.Clone(opts.RuleFiles), presets.Paths(opts.Presets)...),
+		Params:   opts.Params,
+		Handler:  handler,
+		Interval: opts.ReloadInterval,
+	}
+	if _, err := reloader.Reload(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", opts.Addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if opts.ReloadInterval > 0 {
+		go reloader.Run(ctx)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if opts.Insecure {
+			serveErr <- srv.Serve(listener)
+		} else {
+			serveErr <- srv.ServeTLS(listener, opts.TLSCertFile, opts.TLSKeyFile)
+		}
+	}()
+	logger.Info("serving admission webhook",
+		"addr", listener.Addr().String(),
+		"tls", !opts.Insecure,
+		"audit", opts.Audit,
+		"rules", len(handler.Rules()),
+	)
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("serving admission webhook: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer shutdownCancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutting down admission webhook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func newTestServer() (*Server, *bytes.Buffer) {
+	errOut := &bytes.Buffer{}
+	return &Server{
+		IOStreams: genericiooptions.IOStreams{
+			In:     strings.NewReader(""),
+			Out:    &bytes.Buffer{},
+			ErrOut: errOut,
+		},
+	}, errOut
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestServeOptions(t *testing.T) {
+	ruleFile := filepath.Join("..", "..", "..", "fixtures", "rules", "basic-validation.yaml")
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr string
+	}{
+		{
+			name:    "no rule files",
+			opts:    Options{Insecure: true},
+			wantErr: "at least one --rule-file or --preset is required",
+		},
+		{
+			name:    "missing TLS files",
+			opts:    Options{RuleFiles: []string{ruleFile}},
+			wantErr: "--tls-cert-file and --tls-private-key-file are required",
+		},
+		{
+			name:    "invalid rule file",
+			opts:    Options{RuleFiles: []string{"does-not-exist.yaml"}, Insecure: true},
+			wantErr: "loading validation rules from does-not-exist.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer()
+			err := s.Serve(context.Background(), tt.opts)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestServeInsecure(t *testing.T) {
+	addr := freeAddr(t)
+	s, errOut := newTestServer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(ctx, Options{
+			RuleFiles: []string{filepath.Join("..", "..", "..", "fixtures", "rules", "*.yaml")},
+			Addr:      addr,
+			Insecure:  true,
+		})
+	}()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + addr + "/healthz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+	assert.Contains(t, errOut.String(), "serving admission webhook")
+	assert.Contains(t, errOut.String(), "shutting down")
+}
@@ -0,0 +1,21 @@
+package test
+
+import (
+	"context"
+	"os"
+
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func Test(ctx context.Context, opts Options) error {
+	ioStreams := genericiooptions.IOStreams{
+		In:     os.Stdin,
+		Out:    os.Stdout,
+		ErrOut: os.Stderr,
+	}
+
+	t := &Tester{
+		IOStreams: ioStreams,
+	}
+	return t.Test(ctx, opts)
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"context"
+	"fmt"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/input"
+	"github.com/RRethy/kube-tools/celery/pkg/ruletest"
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+type Tester struct {
+	IOStreams genericiooptions.IOStreams
+}
+
+// Options configures a test run.
+type Options struct {
+	// Files are ValidationTest files or directories to search for them. The
+	// current directory is searched when empty.
+	Files []string
+	// Verbose lists every test, not only the failing ones.
+	Verbose bool
+}
+
+// Test runs every ValidationTest found in opts.Files and prints the outcomes
+// that differed from what was expected.
+func (t *Tester) Test(ctx context.Context, opts Options) error {
+	paths := opts.Files
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := input.Expand(paths, nil, nil)
+	if err != nil {
+		return fmt.Errorf("resolving test files: %w", err)
+	}
+
+	var tests []apiv1.ValidationTest
+	for _, file := range files {
+		data, err := input.Read(file, t.IOStreams.In)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", input.Name(file), err)
+		}
+		fileTests, err := yaml.ParseYAMLToValidationTests(data, input.Name(file))
+		if err != nil {
+			return fmt.Errorf("loading tests from %s: %w", input.Name(file), err)
+		}
+		tests = append(tests, fileTests...)
+	}
+
+	if len(tests) == 0 {
+		return fmt.Errorf("no ValidationTest resources found")
+	}
+
+	failed := 0
+	checked := 0
+	for _, test := range tests {
+		result, err := ruletest.Run(ctx, test)
+		if err != nil {
+			failed++
+			fmt.Fprintf(t.IOStreams.Out, "--- ERROR: %s (%s)\n    %v\n", test.Name, test.Filename, err)
+			continue
+		}
+
+		checked += result.Checked
+		if result.Passed() {
+			if opts.Verbose {
+				fmt.Fprintf(t.IOStreams.Out, "--- PASS: %s (%s) %d checks\n", result.Name, result.Filename, result.Checked)
+			}
+			continue
+		}
+
+		failed++
+		fmt.Fprintf(t.IOStreams.Out, "--- FAIL: %s (%s)\n", result.Name, result.Filename)
+		for _, m := range result.Mismatches {
+			t.printMismatch(m)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("\ntests failed: %d/%d tests failed", failed, len(tests))
+	}
+
+	fmt.Fprintf(t.IOStreams.Out, "ok: %d tests passed (%d checks)\n", len(tests), checked)
+	return nil
+}
+
+// printMismatch prints a mismatch as a small diff of the expected and actual outcome.
+func (t *Tester) printMismatch(m ruletest.Mismatch) {
+	resource := m.Resource
+	if resource == "" {
+		resource = "<all resources>"
+	}
+	fmt.Fprintf(t.IOStreams.Out, "    [%s] %s\n", m.Rule, resource)
+
+	if m.Actual == "" {
+		fmt.Fprintf(t.IOStreams.Out, "      %s\n", m.Message)
+		return
+	}
+
+	expected := string(m.Expected)
+	if m.ExpectedMessage != "" {
+		expected += fmt.Sprintf(": message containing %q", m.ExpectedMessage)
+	}
+	actual := string(m.Actual)
+	if m.Message != "" {
+		actual += ": " + m.Message
+	}
+	fmt.Fprintf(t.IOStreams.Out, "      - %s\n", expected)
+	fmt.Fprintf(t.IOStreams.Out, "      + %s\n", actual)
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func newTestTester(stdin string) (*Tester, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &Tester{
+		IOStreams: genericiooptions.IOStreams{
+			In:     strings.NewReader(stdin),
+			Out:    out,
+			ErrOut: &bytes.Buffer{},
+		},
+	}, out
+}
+
+func TestTesterFixtures(t *testing.T) {
+	tester, out := newTestTester("")
+	err := tester.Test(context.Background(), Options{
+		Files:   []string{filepath.Join("..", "..", "..", "fixtures", "tests")},
+		Verbose: true,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "--- PASS: deployment-standards")
+	assert.Contains(t, out.String(), "--- PASS: params-rules")
+	assert.Contains(t, out.String(), "ok: 4 tests passed (20 checks)")
+}
+
+func TestTesterFailures(t *testing.T) {
+	fixtures, err := filepath.Abs(filepath.Join("..", "..", "..", "fixtures"))
+	require.NoError(t, err)
+
+	suite := `apiVersion: celery.rrethy.io/v1
+kind: ValidationTest
+metadata:
+  name: broken
+spec:
+  ruleFiles: [` + filepath.Join(fixtures, "rules", "deployment-standards.yaml") + `]
+  resources: [` + filepath.Join(fixtures, "resources", "invalid-deployments.yaml") + `]
+  expect:
+    - rule: resource-limits-required
+      resource: Deployment/app-no-limits
+      outcome: pass
+    - rule: no-latest-tags
+      resource: Deployment/app-no-probes
+      outcome: fail
+      message: latest
+    - rule: unknown-rule
+      outcome: pass
+---
+apiVersion: celery.rrethy.io/v1
+kind: ValidationTest
+metadata:
+  name: missing-rules
+spec:
+  ruleFiles: [missing.yaml]
+  resources: [resources.yaml]
+`
+	path := filepath.Join(t.TempDir(), "suite.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(suite), 0o644))
+
+	tester, out := newTestTester("")
+	err = tester.Test(context.Background(), Options{Files: []string{path}})
+	assert.ErrorContains(t, err, "tests failed: 2/2 tests failed")
+
+	got := out.String()
+	assert.Contains(t, got, "--- FAIL: broken")
+	assert.Contains(t, got, "[resource-limits-required] Deployment/app-no-limits\n      - pass\n      + fail: All containers must have CPU and memory limits")
+	assert.Contains(t, got, "      - fail: message containing \"latest\"\n      + pass")
+	assert.Contains(t, got, "[unknown-rule] <all resources>\n      rule not found in ruleFiles")
+	assert.Contains(t, got, "--- ERROR: missing-rules")
+}
+
+func TestTesterStdin(t *testing.T) {
+	suite := `apiVersion: celery.rrethy.io/v1
+kind: ValidationTest
+metadata:
+  name: stdin
+spec:
+  ruleFiles: [` + filepath.Join("..", "..", "..", "fixtures", "rules", "deployment-standards.yaml") + `]
+  resources: [` + filepath.Join("..", "..", "..", "fixtures", "resources", "valid-deployment.yaml") + `]
+  expect:
+    - rule: no-latest-tags
+      outcome: pass
+`
+	tester, out := newTestTester(suite)
+	require.NoError(t, tester.Test(context.Background(), Options{Files: []string{"-"}}))
+	assert.Contains(t, out.String(), "ok: 1 tests passed (1 checks)")
+}
+
+func TestTesterNoTests(t *testing.T) {
+	tester, _ := newTestTester("")
+	err := tester.Test(context.Background(), Options{
+		Files: []string{filepath.Join("..", "..", "..", "fixtures", "rules")},
+	})
+	assert.ErrorContains(t, err, "no ValidationTest resources found")
+}
@@ -4,24 +4,12 @@ import (
 	"context"
 	"os"
 
+	"github.com/RRethy/kube-tools/celery/pkg/cluster"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/genericiooptions"
 )
 
-func Validate(
-	ctx context.Context,
-	files []string,
-	celExpression string,
-	ruleFiles []string,
-	verbose bool,
-	maxWorkers int,
-	targetGroup string,
-	targetVersion string,
-	targetKind string,
-	targetName string,
-	targetNamespace string,
-	targetLabelSelector string,
-	targetAnnotationSelector string,
-) error {
+func Validate(ctx context.Context, opts Options) error {
 	ioStreams := genericiooptions.IOStreams{
 		In:     os.Stdin,
 		Out:    os.Stdout,
@@ -31,18 +19,23 @@ func Validate(
 	v := &Validater{
 		IOStreams: ioStreams,
 	}
-	return v.Validate(
-		files,
-		celExpression,
-		ruleFiles,
-		verbose,
-		maxWorkers,
-		targetGroup,
-		targetVersion,
-		targetKind,
-		targetName,
-		targetNamespace,
-		targetLabelSelector,
-		targetAnnotationSelector,
-	)
+
+	if opts.Cluster {
+		configFlags := genericclioptions.NewConfigFlags(true)
+		configFlags.KubeConfig = &opts.Kubeconfig
+		configFlags.Context = &opts.Context
+
+		lister, err := cluster.NewLister(configFlags)
+		if err != nil {
+			return err
+		}
+		contextName, err := cluster.ContextName(configFlags)
+		if err != nil {
+			return err
+		}
+		v.Cluster = lister
+		v.ClusterContext = contextName
+	}
+
+	return v.Validate(opts)
 }
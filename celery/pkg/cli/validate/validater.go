@@ -3,71 +3,352 @@ package validate
 import (
 	"context"
 	"fmt"
-	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/baseline"
+	"github.com/RRethy/kube-tools/celery/pkg/cluster"
+	"github.com/RRethy/kube-tools/celery/pkg/input"
+	"github.com/RRethy/kube-tools/celery/pkg/presets"
+	"github.com/RRethy/kube-tools/celery/pkg/report"
+	"github.com/RRethy/kube-tools/celery/pkg/rules"
+	"github.com/RRethy/kube-tools/celery/pkg/schemas"
 	"github.com/RRethy/kube-tools/celery/pkg/validator"
-	"github.com/RRethy/kube-tools/celery/pkg/yaml"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/cli-runtime/pkg/genericiooptions"
 )
 
 type Validater struct {
 	IOStreams genericiooptions.IOStreams
+	// Cluster lists live objects when Options.Cluster is set.
+	Cluster cluster.Interface
+	// ClusterContext is the kubeconfig context Cluster talks to, used to label results.
+	ClusterContext string
 }
 
-func (v *Validater) Validate(
-	files []string,
-	celExpression string,
-	ruleFiles []string,
-	verbose bool,
-	maxWorkers int,
-	targetGroup string,
-	targetVersion string,
-	targetKind string,
-	targetName string,
-	targetNamespace string,
-	targetLabelSelector string,
-	targetAnnotationSelector string,
-) error {
+// Options configures a validation run.
+type Options struct {
+	// Files are the manifest files or directories to validate. Stdin is read when empty or "-".
+	Files []string
+	// Include and Exclude are globs that filter files found while walking directories.
+	Include []string
+	Exclude []string
+
+	Expression string
+	RuleFiles  []string
+	// Presets are the names of embedded rule packs, loaded after RuleFiles.
+	Presets []string
+	// Params is a YAML or JSON file that rules without a paramsRef see as params.
+	Params  string
+	Verbose bool
+	// Explain shows why failing rules were false.
+	Explain    bool
+	MaxWorkers int
+	// Output is the result format: text (default), json, yaml, sarif or junit.
+	Output string
+	// FailOn is the lowest severity that fails the run: error (default), warning or info.
+	FailOn string
+	// Baseline is a baseline file whose known failures do not fail the run.
+	Baseline string
+	// WriteBaseline records every current failure to this file instead of reporting them.
+	WriteBaseline string
+
+	// Cluster validates live objects instead of files.
+	Cluster    bool
+	Kubeconfig string
+	Context    string
+	// Namespace limits the cluster objects validated. Every namespace is used when empty.
+	Namespace string
+	// Resources are the resource types listed from the cluster, or "all" for every listable type.
+	Resources []string
+
+	// TypeCheck type checks rules against the schemas of the kinds they target
+	// before validating anything. CRDs are CustomResourceDefinition files whose
+	// schemas are used alongside the built-in ones, and imply TypeCheck.
+	TypeCheck bool
+	CRDs      []string
+
+	// CostLimit is the CEL cost a single rule evaluation may use unless the
+	// rule sets costLimit; zero uses validator.DefaultCostLimit. CostBudget is
+	// the cost every evaluation may use together, and Timeout limits how long a
+	// single evaluation may take. Zero means no budget or timeout.
+	CostLimit  uint64
+	CostBudget uint64
+	Timeout    time.Duration
+
+	// Scope is what rules that do not set a scope see as allObjects: file
+	// (default) for the resources of the same input, or all for every input.
+	Scope string
+
+	// Old and New are two versions of the same manifests. Resources in New are
+	// validated with oldObject bound to their version in Old.
+	Old []string
+	New []string
+
+	TargetGroup              string
+	TargetVersion            string
+	TargetKind               string
+	TargetName               string
+	TargetNamespace          string
+	TargetLabelSelector      string
+	TargetAnnotationSelector string
+}
+
+func (v *Validater) Validate(opts Options) error {
 	ctx := context.Background()
 
+	format, err := report.ParseFormat(opts.Output)
+	if err != nil {
+		return err
+	}
+
+	failOn, err := apiv1.ParseSeverity(opts.FailOn)
+	if err != nil {
+		return fmt.Errorf("invalid --fail-on: %w", err)
+	}
+
+	scope, err := apiv1.ParseScope(opts.Scope)
+	if err != nil {
+		return fmt.Errorf("invalid --scope: %w", err)
+	}
+
 	var ruless []apiv1.ValidationRules
-	if celExpression != "" {
-		ruless = append(ruless, createInlineValidationRule(celExpression, targetGroup, targetVersion, targetKind, targetName, targetNamespace, targetLabelSelector, targetAnnotationSelector))
+	if opts.Expression != "" {
+		ruless = append(ruless, createInlineValidationRule(opts.Expression, opts.TargetGroup, opts.TargetVersion, opts.TargetKind, opts.TargetName, opts.TargetNamespace, opts.TargetLabelSelector, opts.TargetAnnotationSelector))
+	}
+
+	loadedRules, err := rules.Load(append(slices.Clone(opts.RuleFiles), presets.Paths(opts.Presets)...))
+	if err != nil {
+		return err
+	}
+	ruless = append(ruless, loadedRules...)
+
+	if len(ruless) == 0 {
+		return fmt.Errorf("no validation rules provided")
+	}
+
+	val := &validator.Validator{
+		Stdin:      v.IOStreams.In,
+		CostLimit:  opts.CostLimit,
+		CostBudget: opts.CostBudget,
+		Timeout:    opts.Timeout,
+		MaxWorkers: opts.MaxWorkers,
+		Scope:      scope,
+		Explain:    opts.Explain,
+	}
+	if opts.Params != "" {
+		val.Params, err = rules.LoadParams(opts.Params)
+		if err != nil {
+			return fmt.Errorf("invalid --params: %w", err)
+		}
+	}
+	if opts.TypeCheck || len(opts.CRDs) > 0 {
+		val.Schemas, err = schemas.NewResolver()
+		if err != nil {
+			return err
+		}
+		if err := val.Schemas.LoadCRDs(opts.CRDs); err != nil {
+			return err
+		}
+	}
+
+	var matcher *baseline.Matcher
+	if opts.Baseline != "" && opts.WriteBaseline == "" {
+		b, err := baseline.Load(opts.Baseline)
+		if err != nil {
+			return err
+		}
+		matcher = b.Matcher()
 	}
 
-	for _, ruleFilePattern := range ruleFiles {
-		matches, err := filepath.Glob(ruleFilePattern)
+	var results <-chan validator.ValidationResult
+	if opts.Cluster {
+		collected, err := v.validateCluster(ctx, val, opts, ruless)
+		if err != nil {
+			return err
+		}
+		results = streamOf(collected)
+	} else if len(opts.Old) > 0 || len(opts.New) > 0 {
+		collected, err := v.validateChanges(ctx, val, opts, ruless)
+		if err != nil {
+			return err
+		}
+		results = streamOf(collected)
+	} else {
+		files, err := input.Expand(opts.Files, opts.Include, opts.Exclude)
 		if err != nil {
-			return fmt.Errorf("expanding glob pattern %s: %w", ruleFilePattern, err)
+			return fmt.Errorf("resolving input files: %w", err)
 		}
 
-		if len(matches) == 0 {
-			matches = []string{ruleFilePattern}
+		parsedRules, err := val.CompileRules(ruless)
+		if err != nil {
+			return fmt.Errorf("validation failed: %w", err)
 		}
+		results = val.Stream(ctx, files, parsedRules)
+	}
 
-		for _, ruleFile := range matches {
-			loadedRules, err := yaml.ParseYAMLFileToValidationRules(ruleFile)
-			if err != nil {
-				return fmt.Errorf("loading validation rules from %s: %w", ruleFile, err)
+	if opts.WriteBaseline != "" {
+		var failures []validator.ValidationResult
+		for result := range results {
+			if !result.Valid {
+				failures = append(failures, result)
 			}
-			ruless = append(ruless, loadedRules...)
 		}
+		b := baseline.FromResults(failures)
+		if err := b.Save(opts.WriteBaseline); err != nil {
+			return err
+		}
+		fmt.Fprintf(v.IOStreams.ErrOut, "wrote %d baseline entries to %s\n", len(b.Entries), opts.WriteBaseline)
+		return nil
 	}
 
-	if len(ruless) == 0 {
-		return fmt.Errorf("no validation rules provided")
+	// Machine-readable reports are sorted and summarised up front, so they
+	// need every result. Text output only keeps the results it prints.
+	var collected []validator.ValidationResult
+	text := newTextReport(opts.Verbose)
+	for result := range results {
+		if matcher != nil {
+			matcher.Match(&result)
+		}
+		if format != report.FormatText {
+			collected = append(collected, result)
+		} else {
+			text.add(result)
+		}
+	}
+
+	if matcher != nil {
+		for _, entry := range matcher.Stale() {
+			fmt.Fprintf(v.IOStreams.ErrOut, "stale baseline entry, no longer fails: %s\n", entry)
+		}
+	}
+
+	if format != report.FormatText {
+		if err := report.Write(v.IOStreams.Out, format, collected); err != nil {
+			return fmt.Errorf("writing results: %w", err)
+		}
+		return summaryError(collected, failOn)
+	}
+
+	return v.writeText(text, failOn)
+}
+
+// streamOf sends results that were validated all at once on a closed channel.
+func streamOf(results []validator.ValidationResult) <-chan validator.ValidationResult {
+	ch := make(chan validator.ValidationResult, len(results))
+	for _, result := range results {
+		ch <- result
+	}
+	close(ch)
+	return ch
+}
+
+// validateCluster lists objects from the cluster and validates them. Resource
+// types that could not be listed while discovering every type are reported on
+// ErrOut and skipped.
+func (v *Validater) validateCluster(ctx context.Context, val *validator.Validator, opts Options, ruless []apiv1.ValidationRules) ([]validator.ValidationResult, error) {
+	if len(opts.Files) > 0 {
+		return nil, fmt.Errorf("files cannot be given with --cluster")
+	}
+	if v.Cluster == nil {
+		return nil, fmt.Errorf("no cluster client configured")
+	}
+
+	resourceTypes := opts.Resources
+	if len(resourceTypes) == 0 {
+		resourceTypes = []string{cluster.AllResources}
+	}
+
+	objects, warnings, err := v.Cluster.List(ctx, resourceTypes, opts.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("listing cluster objects: %w", err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(v.IOStreams.ErrOut, "warning: skipping %v\n", warning)
+	}
+
+	// Namespaces are not among the objects when they were not listed, e.g.
+	// with --namespace or --resources deployments, so namespaceSelectors
+	// look them up.
+	val.NamespaceLabels = v.clusterNamespaceLabels()
+
+	results, err := val.ValidateCluster(ctx, v.ClusterContext, objects, ruless)
+	if err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	return results, nil
+}
+
+// clusterNamespaceLabels returns a lookup of the labels of the cluster's
+// Namespaces. They are listed the first time one is looked up.
+func (v *Validater) clusterNamespaceLabels() func(ctx context.Context, name string) (map[string]string, error) {
+	var once sync.Once
+	var namespaces map[string]map[string]string
+	var listErr error
+	return func(ctx context.Context, name string) (map[string]string, error) {
+		once.Do(func() {
+			var objects []*unstructured.Unstructured
+			objects, _, listErr = v.Cluster.List(ctx, []string{"namespaces"}, "")
+			namespaces = make(map[string]map[string]string, len(objects))
+			for _, object := range objects {
+				namespaces[object.GetName()] = object.GetLabels()
+			}
+		})
+		if listErr != nil {
+			return nil, fmt.Errorf("listing namespaces: %w", listErr)
+		}
+		namespaceLabels, ok := namespaces[name]
+		if !ok {
+			return nil, fmt.Errorf("namespace %s not found", name)
+		}
+		return namespaceLabels, nil
 	}
+}
 
-	val := &validator.Validator{}
-	results, err := val.Validate(ctx, files, ruless)
+// validateChanges validates the resources in opts.New against their previous
+// version in opts.Old. How resources changed is reported on ErrOut, listing
+// removed resources since no rules are evaluated against them.
+func (v *Validater) validateChanges(ctx context.Context, val *validator.Validator, opts Options, ruless []apiv1.ValidationRules) ([]validator.ValidationResult, error) {
+	if len(opts.Files) > 0 {
+		return nil, fmt.Errorf("files cannot be given with --old and --new")
+	}
+	if len(opts.Old) == 0 || len(opts.New) == 0 {
+		return nil, fmt.Errorf("--old and --new must be given together")
+	}
+
+	oldFiles, err := input.Expand(opts.Old, opts.Include, opts.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("resolving --old files: %w", err)
+	}
+	newFiles, err := input.Expand(opts.New, opts.Include, opts.Exclude)
 	if err != nil {
-		return fmt.Errorf("validation failed: %w", err)
+		return nil, fmt.Errorf("resolving --new files: %w", err)
 	}
 
-	return v.displayResults(results, verbose)
+	results, changes, err := val.ValidateChanges(ctx, oldFiles, newFiles, ruless)
+	if err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	fmt.Fprintf(v.IOStreams.ErrOut, "compared resources: %d updated, %d added, %d removed\n", len(changes.Updated), len(changes.Added), len(changes.Removed))
+	for _, resource := range changes.Removed {
+		fmt.Fprintf(v.IOStreams.ErrOut, "removed: %s\n", resourceRef(resource))
+	}
+	return results, nil
+}
+
+// resourceRef formats a resource as apiVersion namespace/Kind/name.
+func resourceRef(resource *unstructured.Unstructured) string {
+	ref := resource.GetKind() + "/" + resource.GetName()
+	if ns := resource.GetNamespace(); ns != "" {
+		ref = ns + "/" + ref
+	}
+	return resource.GetAPIVersion() + " " + ref
 }
 
 func createInlineValidationRule(expression string, targetGroup string, targetVersion string, targetKind string, targetName string, targetNamespace string, targetLabelSelector string, targetAnnotationSelector string) apiv1.ValidationRules {
@@ -105,57 +386,52 @@ func createInlineValidationRule(expression string, targetGroup string, targetVer
 	return rule
 }
 
-func (v *Validater) displayResults(results []validator.ValidationResult, verbose bool) error {
-	type ruleResult struct {
-		RuleName     string
-		ResourceKind string
-		ResourceName string
-		Valid        bool
-		Err          error
-	}
+// textReport collects results for text output as they arrive. Every result is
+// counted but only the ones that are printed are kept.
+type textReport struct {
+	verbose bool
+	tally   tally
+	// shown groups printed results by input file and rule file.
+	shown map[string]map[string][]validator.ValidationResult
+}
 
-	groupedResults := make(map[string]map[string][]ruleResult)
-	hasFailures := false
-	totalValidations := len(results)
-	failureCount := 0
+func newTextReport(verbose bool) *textReport {
+	return &textReport{
+		verbose: verbose,
+		shown:   make(map[string]map[string][]validator.ValidationResult),
+	}
+}
 
-	for _, result := range results {
-		if !result.Valid {
-			hasFailures = true
-			failureCount++
-		}
+func (r *textReport) add(result validator.ValidationResult) {
+	r.tally.add(result)
 
-		if !verbose && result.Valid {
-			continue
-		}
+	// Skipped rules are always listed so suppressions stay visible.
+	if !r.verbose && (result.Valid || result.Baselined) && !result.Skipped {
+		return
+	}
 
-		if groupedResults[result.InputFile] == nil {
-			groupedResults[result.InputFile] = make(map[string][]ruleResult)
-		}
-		groupedResults[result.InputFile][result.RuleFile] = append(
-			groupedResults[result.InputFile][result.RuleFile],
-			ruleResult{
-				RuleName:     result.RuleName,
-				ResourceKind: result.ResourceKind,
-				ResourceName: result.ResourceName,
-				Valid:        result.Valid,
-				Err:          result.Err,
-			},
-		)
+	if r.shown[result.InputFile] == nil {
+		r.shown[result.InputFile] = make(map[string][]validator.ValidationResult)
 	}
+	r.shown[result.InputFile][result.RuleFile] = append(r.shown[result.InputFile][result.RuleFile], result)
+}
 
-	if !hasFailures && !verbose {
+func (v *Validater) writeText(text *textReport, failOn apiv1.Severity) error {
+	hasFailures := text.tally.failed() > 0
+	hasSkips := text.tally.skipped > 0
+	hasBaselined := text.tally.baselined > 0
+	if !hasFailures && !hasSkips && !hasBaselined && !text.verbose {
 		return nil
 	}
 
 	var inputFiles []string
-	for inputFile := range groupedResults {
+	for inputFile := range text.shown {
 		inputFiles = append(inputFiles, inputFile)
 	}
 	sort.Strings(inputFiles)
 
 	for _, inputFile := range inputFiles {
-		ruleFiles := groupedResults[inputFile]
+		ruleFiles := text.shown[inputFile]
 		fmt.Fprintf(v.IOStreams.Out, "\n%s:\n", inputFile)
 
 		var ruleFileNames []string
@@ -165,21 +441,165 @@ func (v *Validater) displayResults(results []validator.ValidationResult, verbose
 		sort.Strings(ruleFileNames)
 
 		for _, ruleFile := range ruleFileNames {
+			// Results arrive in no particular order.
 			results := ruleFiles[ruleFile]
+			sort.SliceStable(results, func(i, j int) bool {
+				a, b := results[i], results[j]
+				if a.ResourceKind != b.ResourceKind {
+					return a.ResourceKind < b.ResourceKind
+				}
+				if a.ResourceName != b.ResourceName {
+					return a.ResourceName < b.ResourceName
+				}
+				if a.RuleName != b.RuleName {
+					return a.RuleName < b.RuleName
+				}
+				return a.Line < b.Line
+			})
 			fmt.Fprintf(v.IOStreams.Out, "  From %s:\n", ruleFile)
 			for _, result := range results {
-				if result.Valid {
-					fmt.Fprintf(v.IOStreams.Out, "    ✅ [%s] %s/%s\n", result.RuleName, result.ResourceKind, result.ResourceName)
+				if result.Skipped {
+					fmt.Fprintf(v.IOStreams.Out, "    ⏭️  [%s] %s/%s: skipped: %s%s\n", result.RuleName, result.ResourceKind, result.ResourceName, result.SkipReason, result.LocationSuffix())
+				} else if result.Baselined {
+					fmt.Fprintf(v.IOStreams.Out, "    📌 [%s] %s/%s: baselined: %v%s\n", result.RuleName, result.ResourceKind, result.ResourceName, result.Err, result.LocationSuffix())
+				} else if result.Valid {
+					fmt.Fprintf(v.IOStreams.Out, "    ✅ [%s] %s/%s%s\n", result.RuleName, result.ResourceKind, result.ResourceName, result.LocationSuffix())
+				} else if result.LimitExceeded {
+					fmt.Fprintf(v.IOStreams.Out, "    ⏱️  [%s] %s/%s: %v%s\n", result.RuleName, result.ResourceKind, result.ResourceName, result.Err, result.LocationSuffix())
 				} else {
-					fmt.Fprintf(v.IOStreams.Out, "    ❌ [%s] %s/%s: %v\n", result.RuleName, result.ResourceKind, result.ResourceName, result.Err)
+					fmt.Fprintf(v.IOStreams.Out, "    %s [%s] %s/%s: %v%s\n", severityIcon(result.Severity), result.RuleName, result.ResourceKind, result.ResourceName, result.Err, result.LocationSuffix())
+					if result.Explanation != nil {
+						v.writeExplanation(*result.Explanation, "        ")
+					}
 				}
 			}
 		}
 	}
 
-	if hasFailures {
-		failurePercentage := float64(failureCount) / float64(totalValidations) * 100
-		return fmt.Errorf("\nvalidation failed: %d/%d checks failed (%.1f%% failure rate)", failureCount, totalValidations, failurePercentage)
+	err := text.tally.err(failOn)
+	if err == nil && (hasFailures || hasSkips || hasBaselined) {
+		fmt.Fprintf(v.IOStreams.Out, "\nvalidation passed%s\n", text.tally.summary())
+	}
+	return err
+}
+
+// writeExplanation prints explanation and its children one per line, each
+// level indented further than the last.
+func (v *Validater) writeExplanation(explanation validator.Explanation, indent string) {
+	fmt.Fprintf(v.IOStreams.Out, "%s%s\n", indent, explanation)
+	for _, child := range explanation.Children {
+		v.writeExplanation(child, indent+"  ")
+	}
+}
+
+func severityIcon(severity apiv1.Severity) string {
+	switch severity {
+	case apiv1.SeverityWarning:
+		return "⚠️ "
+	case apiv1.SeverityInfo:
+		return "ℹ️ "
+	default:
+		return "❌"
+	}
+}
+
+// tally counts results for the summary of a run.
+type tally struct {
+	total         int
+	skipped       int
+	baselined     int
+	limitExceeded int
+	// failures counts the failing results not in the baseline by severity.
+	failures map[apiv1.Severity]int
+}
+
+func (t *tally) add(result validator.ValidationResult) {
+	t.total++
+	if result.Skipped {
+		t.skipped++
+	}
+	if result.Baselined {
+		t.baselined++
+		return
+	}
+	if result.Valid {
+		return
+	}
+	if t.failures == nil {
+		t.failures = make(map[apiv1.Severity]int)
+	}
+	t.failures[result.Severity]++
+	if result.LimitExceeded {
+		t.limitExceeded++
+	}
+}
+
+// failed returns how many results of the given severities failed, or every
+// failure when none are given.
+func (t *tally) failed(severities ...apiv1.Severity) int {
+	count := 0
+	for severity, n := range t.failures {
+		if len(severities) == 0 || slices.Contains(severities, severity) {
+			count += n
+		}
+	}
+	return count
+}
+
+// err returns the error that fails the run when any result at or above the
+// failOn severity failed. Lower severity failures are listed in the message
+// but do not fail the run on their own.
+func (t *tally) err(failOn apiv1.Severity) error {
+	failureCount := 0
+	for severity, n := range t.failures {
+		if severity.AtLeast(failOn) {
+			failureCount += n
+		}
+	}
+
+	if failureCount > 0 {
+		failurePercentage := float64(failureCount) / float64(t.total) * 100
+		return fmt.Errorf("\nvalidation failed: %d/%d checks failed (%.1f%% failure rate)%s", failureCount, t.total, failurePercentage, t.summary())
 	}
 	return nil
 }
+
+// summary describes the failing warning and info results, the results stopped
+// by a limit and the skipped and baselined results, e.g.
+// " with 2 warnings, 1 info, 1 limit exceeded, 1 skipped".
+func (t *tally) summary() string {
+	warnings := t.failed(apiv1.SeverityWarning)
+	infos := t.failed(apiv1.SeverityInfo)
+
+	var parts []string
+	if warnings == 1 {
+		parts = append(parts, "1 warning")
+	} else if warnings > 1 {
+		parts = append(parts, fmt.Sprintf("%d warnings", warnings))
+	}
+	if infos > 0 {
+		parts = append(parts, fmt.Sprintf("%d info", infos))
+	}
+	if t.limitExceeded > 0 {
+		parts = append(parts, fmt.Sprintf("%d limit exceeded", t.limitExceeded))
+	}
+	if t.skipped > 0 {
+		parts = append(parts, fmt.Sprintf("%d skipped", t.skipped))
+	}
+	if t.baselined > 0 {
+		parts = append(parts, fmt.Sprintf("%d baselined", t.baselined))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " with " + strings.Join(parts, ", ")
+}
+
+// summaryError returns the error that fails a run over results, as tally.err.
+func summaryError(results []validator.ValidationResult, failOn apiv1.Severity) error {
+	var t tally
+	for _, result := range results {
+		t.add(result)
+	}
+	return t.err(failOn)
+}
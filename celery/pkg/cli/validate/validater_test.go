@@ -2,13 +2,20 @@ package validate
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
 	"github.com/RRethy/kube-tools/celery/pkg/validator"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/cli-runtime/pkg/genericiooptions"
 )
 
@@ -120,20 +127,14 @@ func TestValidaterValidate(t *testing.T) {
 				},
 			}
 
-			err := v.Validate(
-				tt.files,
-				tt.celExpression,
-				tt.ruleFiles,
-				tt.verbose,
-				128, // maxWorkers
-				"",  // targetGroup
-				"",  // targetVersion
-				tt.targetKind,
-				"", // targetName
-				"", // targetNamespace
-				"", // targetLabelSelector
-				"", // targetAnnotationSelector
-			)
+			err := v.Validate(Options{
+				Files:      tt.files,
+				Expression: tt.celExpression,
+				RuleFiles:  tt.ruleFiles,
+				Verbose:    tt.verbose,
+				MaxWorkers: 128,
+				TargetKind: tt.targetKind,
+			})
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -164,7 +165,7 @@ func TestValidaterValidate(t *testing.T) {
 	}
 }
 
-func TestDisplayResults(t *testing.T) {
+func TestWriteText(t *testing.T) {
 	tests := []struct {
 		name           string
 		results        []validator.ValidationResult
@@ -261,7 +262,11 @@ func TestDisplayResults(t *testing.T) {
 				},
 			}
 
-			err := v.displayResults(tt.results, tt.verbose)
+			text := newTextReport(tt.verbose)
+			for _, result := range tt.results {
+				text.add(result)
+			}
+			err := v.writeText(text, apiv1.SeverityError)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -328,14 +333,11 @@ func TestValidaterGlobExpansion(t *testing.T) {
 	}
 
 	// Test with a glob that matches no files (should treat as literal)
-	err := v.Validate(
-		[]string{filepath.Join("..", "..", "..", "fixtures", "resources", "valid-deployment.yaml")},
-		"",
-		[]string{"/nonexistent/path/*.yaml"}, // Should be treated as literal filename
-		false,
-		128,
-		"", "", "", "", "", "", "",
-	)
+	err := v.Validate(Options{
+		Files:      []string{filepath.Join("..", "..", "..", "fixtures", "resources", "valid-deployment.yaml")},
+		RuleFiles:  []string{"/nonexistent/path/*.yaml"}, // Should be treated as literal filename
+		MaxWorkers: 128,
+	})
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "loading validation rules")
@@ -380,7 +382,11 @@ func TestValidaterSortedOutput(t *testing.T) {
 		},
 	}
 
-	err := v.displayResults(results, false)
+	text := newTextReport(false)
+	for _, result := range results {
+		text.add(result)
+	}
+	err := v.writeText(text, apiv1.SeverityError)
 	require.Error(t, err) // Should error because there are failures
 
 	output := out.String()
@@ -411,3 +417,764 @@ func TestValidaterSortedOutput(t *testing.T) {
 		}
 	}
 }
+
+func TestValidaterValidateStdin(t *testing.T) {
+	stdin := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: from-stdin
+spec:
+  replicas: 1
+`
+
+	tests := []struct {
+		name  string
+		files []string
+	}{
+		{
+			name:  "no files reads stdin",
+			files: nil,
+		},
+		{
+			name:  "dash reads stdin",
+			files: []string{"-"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			v := &Validater{
+				IOStreams: genericiooptions.IOStreams{
+					In:  strings.NewReader(stdin),
+					Out: out,
+				},
+			}
+
+			err := v.Validate(Options{
+				Files:      tt.files,
+				Expression: "object.spec.replicas >= 3",
+				MaxWorkers: 128,
+			})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "1/1 checks failed")
+			assert.Contains(t, out.String(), "<stdin>:")
+			assert.Contains(t, out.String(), "Deployment/from-stdin")
+		})
+	}
+}
+
+func TestValidaterValidateDirectory(t *testing.T) {
+	out := &bytes.Buffer{}
+	v := &Validater{
+		IOStreams: genericiooptions.IOStreams{
+			In:  strings.NewReader(""),
+			Out: out,
+		},
+	}
+
+	err := v.Validate(Options{
+		Files:      []string{filepath.Join("..", "..", "..", "fixtures", "resources")},
+		Include:    []string{"valid-*.yaml", "services.yaml"},
+		Expression: "has(object.metadata.name)",
+		Verbose:    true,
+		MaxWorkers: 128,
+	})
+	require.NoError(t, err)
+
+	output := out.String()
+	assert.Contains(t, output, "valid-deployment.yaml:")
+	assert.Contains(t, output, "services.yaml:")
+	assert.NotContains(t, output, "invalid-deployments.yaml:")
+}
+
+func TestValidaterOutputFormats(t *testing.T) {
+	tests := []struct {
+		name           string
+		output         string
+		expression     string
+		expectError    bool
+		expectInOutput []string
+	}{
+		{
+			name:           "json with failures keeps exit error",
+			output:         "json",
+			expression:     "object.spec.replicas >= 10",
+			expectError:    true,
+			expectInOutput: []string{`"results"`, `"valid": false`, `"ruleName": "inline"`},
+		},
+		{
+			name:           "json includes passes without verbose",
+			output:         "json",
+			expression:     "true",
+			expectInOutput: []string{`"valid": true`, `"passed": 3`},
+		},
+		{
+			name:           "yaml",
+			output:         "yaml",
+			expression:     "true",
+			expectInOutput: []string{"results:", "inputFile:"},
+		},
+		{
+			name:           "sarif",
+			output:         "sarif",
+			expression:     "object.spec.replicas >= 10",
+			expectError:    true,
+			expectInOutput: []string{`"version": "2.1.0"`, `"ruleId": "inline"`},
+		},
+		{
+			name:           "junit",
+			output:         "junit",
+			expression:     "object.spec.replicas >= 10",
+			expectError:    true,
+			expectInOutput: []string{"<testsuites", `failures="3"`},
+		},
+		{
+			name:        "unknown format",
+			output:      "html",
+			expression:  "true",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			v := &Validater{
+				IOStreams: genericiooptions.IOStreams{
+					In:  strings.NewReader(""),
+					Out: out,
+				},
+			}
+
+			err := v.Validate(Options{
+				Files:      []string{filepath.Join("..", "..", "..", "fixtures", "resources", "invalid-deployments.yaml")},
+				Expression: tt.expression,
+				MaxWorkers: 128,
+				Output:     tt.output,
+			})
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			output := out.String()
+			assert.NotContains(t, output, "❌")
+			for _, expected := range tt.expectInOutput {
+				assert.Contains(t, output, expected)
+			}
+		})
+	}
+}
+
+func TestValidaterFailOn(t *testing.T) {
+	ruleFile := filepath.Join(t.TempDir(), "severity-rules.yaml")
+	require.NoError(t, os.WriteFile(ruleFile, []byte(`apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: severity-rules
+spec:
+  rules:
+    - name: high-availability
+      expression: "object.spec.replicas >= 10"
+      message: "Deployments should have at least 10 replicas"
+      severity: warning
+    - name: has-name
+      expression: "has(object.metadata.name)"
+      message: "Resources must have a name"
+`), 0o644))
+
+	tests := []struct {
+		name           string
+		failOn         string
+		expectError    bool
+		expectInOutput []string
+	}{
+		{
+			name:           "warnings do not fail by default",
+			failOn:         "",
+			expectError:    false,
+			expectInOutput: []string{"⚠️", "high-availability", "validation passed with 3 warnings"},
+		},
+		{
+			name:           "fail on warning",
+			failOn:         "warning",
+			expectError:    true,
+			expectInOutput: []string{"⚠️", "high-availability"},
+		},
+		{
+			name:        "invalid threshold",
+			failOn:      "critical",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			v := &Validater{
+				IOStreams: genericiooptions.IOStreams{
+					In:  strings.NewReader(""),
+					Out: out,
+				},
+			}
+
+			err := v.Validate(Options{
+				Files:      []string{filepath.Join("..", "..", "..", "fixtures", "resources", "invalid-deployments.yaml")},
+				RuleFiles:  []string{ruleFile},
+				MaxWorkers: 128,
+				FailOn:     tt.failOn,
+			})
+			if tt.expectError {
+				require.Error(t, err)
+				if tt.failOn == "warning" {
+					assert.Contains(t, err.Error(), "validation failed: 3/6 checks failed (50.0% failure rate) with 3 warnings")
+				}
+			} else {
+				require.NoError(t, err)
+			}
+
+			for _, expected := range tt.expectInOutput {
+				assert.Contains(t, out.String(), expected)
+			}
+		})
+	}
+}
+
+func TestValidaterSkipAnnotation(t *testing.T) {
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: singleton
+  annotations:
+    celery.rrethy.io/skip: "minimum-replicas"
+    celery.rrethy.io/skip-reason: "leader election only supports one replica"
+spec:
+  replicas: 1
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: unjustified
+  annotations:
+    celery.rrethy.io/skip: "minimum-replicas"
+spec:
+  replicas: 1
+`
+	ruleFile := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(ruleFile, []byte(`apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: replicas
+spec:
+  rules:
+    - name: minimum-replicas
+      expression: "object.spec.replicas >= 3"
+      message: "Deployments must have at least 3 replicas"
+`), 0o644))
+
+	out := &bytes.Buffer{}
+	v := &Validater{
+		IOStreams: genericiooptions.IOStreams{
+			In:  strings.NewReader(manifest),
+			Out: out,
+		},
+	}
+
+	err := v.Validate(Options{RuleFiles: []string{ruleFile}, MaxWorkers: 128})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1/2 checks failed (50.0% failure rate) with 1 skipped")
+	assert.Contains(t, out.String(), "⏭️  [minimum-replicas] Deployment/singleton: skipped: leader election only supports one replica")
+	assert.Contains(t, out.String(), "[minimum-replicas] Deployment/unjustified: skipping rule requires a celery.rrethy.io/skip-reason annotation")
+}
+
+func TestValidaterBaseline(t *testing.T) {
+	dir := t.TempDir()
+	manifests := filepath.Join(dir, "manifests.yaml")
+	baselineFile := filepath.Join(dir, "baseline.yaml")
+	writeManifests := func(names ...string) {
+		var docs []string
+		for _, name := range names {
+			docs = append(docs, "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: "+name+"\n  namespace: prod\nspec:\n  replicas: 1\n")
+		}
+		require.NoError(t, os.WriteFile(manifests, []byte(strings.Join(docs, "---\n")), 0o644))
+	}
+	run := func(opts Options) (string, string, error) {
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		v := &Validater{IOStreams: genericiooptions.IOStreams{In: strings.NewReader(""), Out: out, ErrOut: errOut}}
+		opts.Files = []string{manifests}
+		opts.Expression = "object.spec.replicas >= 3"
+		opts.MaxWorkers = 128
+		err := v.Validate(opts)
+		return out.String(), errOut.String(), err
+	}
+
+	writeManifests("legacy-a", "legacy-b")
+	_, errOut, err := run(Options{WriteBaseline: baselineFile})
+	require.NoError(t, err, "writing a baseline should succeed despite failures")
+	assert.Contains(t, errOut, "wrote 2 baseline entries to "+baselineFile)
+
+	out, errOut, err := run(Options{Baseline: baselineFile})
+	require.NoError(t, err, "baselined failures should not fail the run")
+	assert.Contains(t, out, "validation passed with 2 baselined")
+	assert.Empty(t, errOut)
+
+	writeManifests("legacy-a", "new")
+	out, errOut, err = run(Options{Baseline: baselineFile})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1/2 checks failed (50.0% failure rate) with 1 baselined")
+	assert.Contains(t, out, "Deployment/new")
+	assert.NotContains(t, out, "Deployment/legacy-a")
+	assert.Contains(t, errOut, "stale baseline entry, no longer fails: [inline] apps/v1 prod/Deployment/legacy-b (<inline>)")
+
+	out, _, err = run(Options{Baseline: baselineFile, Output: "json"})
+	require.Error(t, err)
+	assert.Contains(t, out, `"baselined": true`)
+
+	_, _, err = run(Options{Baseline: filepath.Join(dir, "missing.yaml")})
+	assert.ErrorContains(t, err, "reading baseline")
+}
+
+type fakeCluster struct {
+	objects       []*unstructured.Unstructured
+	warnings      []error
+	err           error
+	resourceTypes []string
+	namespace     string
+
+	// namespaces are listed on their own for namespaceSelectors.
+	namespaces     []*unstructured.Unstructured
+	namespaceLists int
+}
+
+func (f *fakeCluster) List(_ context.Context, resourceTypes []string, namespace string) ([]*unstructured.Unstructured, []error, error) {
+	if slices.Equal(resourceTypes, []string{"namespaces"}) {
+		f.namespaceLists++
+		return f.namespaces, nil, nil
+	}
+	f.resourceTypes = resourceTypes
+	f.namespace = namespace
+	return f.objects, f.warnings, f.err
+}
+
+func TestValidaterCluster(t *testing.T) {
+	deployment := func(namespace, name string, replicas int64) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]any{"name": name, "namespace": namespace},
+			"spec":       map[string]any{"replicas": replicas},
+		}}
+	}
+	namespace := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]any{"name": "prod"},
+	}}
+
+	fake := &fakeCluster{
+		objects:  []*unstructured.Unstructured{deployment("prod", "web", 1), deployment("staging", "web", 3), namespace},
+		warnings: []error{errors.New("listing secrets: forbidden")},
+	}
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	v := &Validater{
+		IOStreams:      genericiooptions.IOStreams{In: strings.NewReader(""), Out: out, ErrOut: errOut},
+		Cluster:        fake,
+		ClusterContext: "kind-dev",
+	}
+
+	err := v.Validate(Options{
+		Cluster:    true,
+		Expression: "object.kind != 'Deployment' || object.spec.replicas >= 3",
+		Verbose:    true,
+		MaxWorkers: 128,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1/3 checks failed")
+	assert.Equal(t, []string{"all"}, fake.resourceTypes)
+	assert.Empty(t, fake.namespace)
+	assert.Contains(t, out.String(), "kind-dev/prod:\n")
+	assert.Contains(t, out.String(), "kind-dev/staging:\n")
+	assert.Contains(t, out.String(), "kind-dev:\n")
+	assert.Contains(t, errOut.String(), "warning: skipping listing secrets: forbidden")
+
+	err = v.Validate(Options{
+		Cluster:    true,
+		Namespace:  "staging",
+		Resources:  []string{"deployments"},
+		Expression: "true",
+		MaxWorkers: 128,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deployments"}, fake.resourceTypes)
+	assert.Equal(t, "staging", fake.namespace)
+
+	err = v.Validate(Options{Cluster: true, Files: []string{"a.yaml"}, Expression: "true"})
+	assert.ErrorContains(t, err, "files cannot be given with --cluster")
+
+	fake.err = errors.New("connection refused")
+	err = v.Validate(Options{Cluster: true, Expression: "true"})
+	assert.ErrorContains(t, err, "listing cluster objects: connection refused")
+}
+
+func TestValidaterClusterNamespaceSelector(t *testing.T) {
+	object := func(kind, namespace, name string, labels map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       kind,
+			"metadata":   map[string]any{"name": name, "namespace": namespace, "labels": labels},
+			"spec":       map[string]any{"replicas": int64(1)},
+		}}
+	}
+	fake := &fakeCluster{
+		objects: []*unstructured.Unstructured{
+			object("Deployment", "payments", "api", nil),
+			object("Deployment", "sandbox", "api", nil),
+		},
+		namespaces: []*unstructured.Unstructured{
+			object("Namespace", "", "payments", map[string]any{"environment": "production"}),
+			object("Namespace", "", "sandbox", map[string]any{"environment": "development"}),
+		},
+	}
+	out := &bytes.Buffer{}
+	v := &Validater{
+		IOStreams:      genericiooptions.IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}},
+		Cluster:        fake,
+		ClusterContext: "kind-dev",
+	}
+	opts := Options{
+		Cluster:    true,
+		Resources:  []string{"deployments"},
+		RuleFiles:  []string{filepath.Join("..", "..", "..", "fixtures", "rules", "namespace-targets.yaml")},
+		MaxWorkers: 128,
+	}
+
+	err := v.Validate(opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1/1 checks failed", "Namespaces should be looked up when they were not listed")
+	assert.Contains(t, out.String(), "[production-replicas] Deployment/api: Production workloads must run at least 2 replicas")
+	assert.Equal(t, []string{"deployments"}, fake.resourceTypes)
+	assert.Equal(t, 1, fake.namespaceLists, "Namespaces should be listed once")
+
+	fake.objects = append(fake.objects, object("Deployment", "ghost", "api", nil))
+	out.Reset()
+	err = v.Validate(opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2/2 checks failed")
+	assert.Contains(t, out.String(), "looking up the labels of namespace ghost: namespace ghost not found")
+}
+
+func TestValidaterTransitions(t *testing.T) {
+	fixtures := filepath.Join("..", "..", "..", "fixtures")
+	run := func(opts Options) (string, string, error) {
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		v := &Validater{IOStreams: genericiooptions.IOStreams{In: strings.NewReader(""), Out: out, ErrOut: errOut}}
+		opts.RuleFiles = []string{filepath.Join(fixtures, "rules", "transition-rules.yaml")}
+		opts.MaxWorkers = 128
+		err := v.Validate(opts)
+		return out.String(), errOut.String(), err
+	}
+
+	out, errOut, err := run(Options{
+		Old: []string{filepath.Join(fixtures, "transitions", "old")},
+		New: []string{filepath.Join(fixtures, "transitions", "new")},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2/3 checks failed")
+	assert.Contains(t, out, "[pvc-no-shrink] PersistentVolumeClaim/data: PersistentVolumeClaim storage cannot shrink from 10Gi to 5Gi")
+	assert.Contains(t, out, "[statefulset-service-name-immutable] StatefulSet/db: StatefulSet serviceName cannot be changed")
+	assert.Contains(t, errOut, "compared resources: 3 updated, 1 added, 1 removed")
+	assert.Contains(t, errOut, "removed: v1 production/ConfigMap/legacy-config")
+
+	_, _, err = run(Options{
+		Old: []string{filepath.Join(fixtures, "transitions", "new")},
+		New: []string{filepath.Join(fixtures, "transitions", "new")},
+	})
+	require.NoError(t, err, "unchanged manifests should pass")
+
+	out, _, err = run(Options{Files: []string{filepath.Join(fixtures, "transitions", "new")}, Verbose: true})
+	require.NoError(t, err)
+	assert.NotContains(t, out, "pvc-no-shrink", "transition rules should not run without --old")
+
+	_, _, err = run(Options{Old: []string{filepath.Join(fixtures, "transitions", "old")}})
+	assert.ErrorContains(t, err, "--old and --new must be given together")
+
+	_, _, err = run(Options{
+		Files: []string{"extra.yaml"},
+		Old:   []string{filepath.Join(fixtures, "transitions", "old")},
+		New:   []string{filepath.Join(fixtures, "transitions", "new")},
+	})
+	assert.ErrorContains(t, err, "files cannot be given with --old and --new")
+}
+
+func TestValidaterTypeCheck(t *testing.T) {
+	fixtures := filepath.Join("..", "..", "..", "fixtures")
+	run := func(opts Options) error {
+		v := &Validater{IOStreams: genericiooptions.IOStreams{In: strings.NewReader(""), Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}}}
+		opts.Files = []string{filepath.Join(fixtures, "resources", "valid-deployment.yaml")}
+		opts.TargetKind = "Deployment"
+		opts.MaxWorkers = 128
+		return v.Validate(opts)
+	}
+
+	err := run(Options{Expression: "object.spec.replica >= 3"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1/1 checks failed", "without --typecheck misspelt fields only fail when evaluated")
+
+	err = run(Options{Expression: "object.spec.replica >= 3", TypeCheck: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "type error in rule 'inline' (<inline>) against apps/v1 Deployment")
+	assert.Contains(t, err.Error(), "undefined field 'replica'")
+
+	require.NoError(t, run(Options{Expression: "object.spec.replicas >= 3", TypeCheck: true}))
+
+	err = run(Options{RuleFiles: []string{filepath.Join(fixtures, "rules", "*.yaml")}, CRDs: []string{filepath.Join(fixtures, "crds")}})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "type error", "fixture rules should type check")
+}
+
+func TestValidaterLimits(t *testing.T) {
+	fixtures := filepath.Join("..", "..", "..", "fixtures")
+	run := func(opts Options) (string, error) {
+		out := &bytes.Buffer{}
+		v := &Validater{IOStreams: genericiooptions.IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}}
+		opts.Files = []string{filepath.Join(fixtures, "resources", "cross-reference-resources.yaml")}
+		opts.Expression = `allObjects.all(a, allObjects.all(b, allObjects.all(c, a.kind != "" && b.kind != "" && c.kind != "")))`
+		opts.MaxWorkers = 128
+		err := v.Validate(opts)
+		return out.String(), err
+	}
+
+	_, err := run(Options{})
+	require.NoError(t, err)
+
+	out, err := run(Options{CostLimit: 50})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "7/7 checks failed")
+	assert.Contains(t, err.Error(), "with 7 limit exceeded")
+	assert.Contains(t, out, "⏱️  [inline]")
+	assert.Contains(t, out, "limit exceeded: cost of rule exceeds the limit of 50")
+
+	out, err = run(Options{Timeout: time.Nanosecond, Output: "json"})
+	require.Error(t, err)
+	assert.Contains(t, out, `"limitExceeded": true`)
+	assert.Contains(t, out, "limit exceeded: evaluating rule took longer than 1ns")
+}
+
+func TestValidaterScope(t *testing.T) {
+	fixtures := filepath.Join("..", "..", "..", "fixtures")
+	run := func(opts Options) (string, error) {
+		out := &bytes.Buffer{}
+		v := &Validater{IOStreams: genericiooptions.IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}}
+		opts.Files = []string{filepath.Join(fixtures, "cross-file")}
+		opts.MaxWorkers = 128
+		err := v.Validate(opts)
+		return out.String(), err
+	}
+	selects := Options{
+		Expression: "allObjects.exists(d, d.kind == 'Deployment' && d.spec.template.metadata.labels.app == object.spec.selector.app)",
+		TargetKind: "Service",
+	}
+
+	_, err := run(selects)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3/3 checks failed", "Deployments in another file should not be seen by default")
+
+	selects.Scope = "all"
+	out, err := run(selects)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1/3 checks failed")
+	assert.Contains(t, out, "services.yaml")
+	assert.Contains(t, out, "[inline] Service/worker")
+
+	out, err = run(Options{RuleFiles: []string{filepath.Join(fixtures, "rules", "cross-file-rules.yaml")}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1/3 checks failed", "rules may set scope: all themselves")
+	assert.Contains(t, out, "Service worker selects no Deployment in namespace shop")
+
+	selects.Scope = "cluster"
+	_, err = run(selects)
+	assert.ErrorContains(t, err, `invalid --scope: unknown scope "cluster"`)
+}
+
+func TestValidaterPresets(t *testing.T) {
+	fixtures := filepath.Join("..", "..", "..", "fixtures")
+	run := func(opts Options) (string, error) {
+		out := &bytes.Buffer{}
+		v := &Validater{IOStreams: genericiooptions.IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}}
+		opts.Files = []string{filepath.Join(fixtures, "presets", "workloads.yaml")}
+		opts.MaxWorkers = 128
+		err := v.Validate(opts)
+		return out.String(), err
+	}
+
+	out, err := run(Options{Presets: []string{"restricted", "best-practices"}, TypeCheck: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "12/52 checks failed")
+	assert.Contains(t, out, "From preset:pod-security-baseline:")
+	assert.Contains(t, out, "[baseline-privileged-containers] Deployment/legacy")
+	assert.Contains(t, out, "[pinned-image-tags] CronJob/report")
+
+	out, err = run(Options{
+		RuleFiles: []string{filepath.Join(fixtures, "rules", "deployment-standards.yaml")},
+		Presets:   []string{"pod-security-baseline"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, out, "From preset:pod-security-baseline:")
+	assert.Contains(t, out, "deployment-standards.yaml:", "presets should load alongside rule files")
+
+	_, err = run(Options{Presets: []string{"strict"}})
+	assert.ErrorContains(t, err, `unknown preset "strict"`)
+}
+
+func TestValidaterParams(t *testing.T) {
+	fixtures := filepath.Join("..", "..", "..", "fixtures")
+	run := func(opts Options) (string, error) {
+		out := &bytes.Buffer{}
+		v := &Validater{IOStreams: genericiooptions.IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}}
+		opts.Files = []string{filepath.Join(fixtures, "params", "deployments.yaml")}
+		opts.RuleFiles = []string{filepath.Join(fixtures, "rules", "params-rules.yaml")}
+		opts.MaxWorkers = 128
+		err := v.Validate(opts)
+		return out.String(), err
+	}
+
+	out, err := run(Options{Params: filepath.Join(fixtures, "params", "production.yaml")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3/6 checks failed")
+	assert.Contains(t, out, "[allowed-registries] Deployment/indexer: images must come from one of registry.example.com/")
+	assert.Contains(t, out, "[replica-range] Deployment/indexer: replicas must be between 3 and 10")
+	assert.Contains(t, out, "[team-owns-namespace] Deployment/indexer: resources in namespace search must be labelled team: team-search")
+
+	out, err = run(Options{Params: filepath.Join(fixtures, "params", "staging.yaml")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2/6 checks failed")
+	assert.Contains(t, out, "[replica-range] Deployment/api: replicas must be between 1 and 3")
+	assert.Contains(t, out, "[team-owns-namespace] Deployment/indexer", "rules with a paramsRef should keep their own params")
+
+	_, err = run(Options{Params: filepath.Join(fixtures, "params", "missing.yaml")})
+	assert.ErrorContains(t, err, "invalid --params: reading params")
+}
+
+func TestValidaterPositions(t *testing.T) {
+	file := filepath.Join("..", "..", "..", "fixtures", "resources", "invalid-deployments.yaml")
+	run := func(output string) (string, error) {
+		out := &bytes.Buffer{}
+		v := &Validater{IOStreams: genericiooptions.IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}}
+		err := v.Validate(Options{
+			Files:      []string{file},
+			Expression: "object.spec.replicas >= 3",
+			TargetName: "app-insufficient-replicas",
+			Output:     output,
+			MaxWorkers: 128,
+		})
+		return out.String(), err
+	}
+
+	out, err := run("text")
+	require.Error(t, err)
+	assert.Contains(t, out, "[inline] Deployment/app-insufficient-replicas: Validation failed ("+file+":12:3)")
+
+	out, err = run("json")
+	require.Error(t, err)
+	assert.Contains(t, out, `"line": 12,`)
+	assert.Contains(t, out, `"column": 3,`)
+
+	out, err = run("sarif")
+	require.Error(t, err)
+	assert.Contains(t, out, `"startLine": 12,`)
+}
+
+func TestTextReport(t *testing.T) {
+	results := []validator.ValidationResult{
+		{InputFile: "a.yaml", RuleFile: "rules.yaml", RuleName: "a", Valid: false, Severity: apiv1.SeverityError, Err: assert.AnError},
+		{InputFile: "a.yaml", RuleFile: "rules.yaml", RuleName: "b", Valid: true},
+		{InputFile: "b.yaml", RuleFile: "rules.yaml", RuleName: "c", Valid: true},
+		{InputFile: "b.yaml", RuleFile: "rules.yaml", RuleName: "d", Valid: true, Skipped: true, SkipReason: "exempt"},
+	}
+
+	text := newTextReport(false)
+	for _, result := range results {
+		text.add(result)
+	}
+	assert.Equal(t, 4, text.tally.total)
+	assert.Len(t, text.shown["a.yaml"]["rules.yaml"], 1, "passing results should only be counted")
+	assert.Len(t, text.shown["b.yaml"]["rules.yaml"], 1, "skipped results should be kept")
+
+	text = newTextReport(true)
+	for _, result := range results {
+		text.add(result)
+	}
+	assert.Len(t, text.shown["a.yaml"]["rules.yaml"], 2, "verbose output keeps every result")
+}
+
+func TestValidaterMaxWorkers(t *testing.T) {
+	fixtures := filepath.Join("..", "..", "..", "fixtures")
+	var outputs []string
+	for _, workers := range []int{1, 2, 128} {
+		out := &bytes.Buffer{}
+		v := &Validater{IOStreams: genericiooptions.IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}}
+		err := v.Validate(Options{
+			Files:      []string{filepath.Join(fixtures, "resources")},
+			RuleFiles:  []string{filepath.Join(fixtures, "rules", "deployment-standards.yaml")},
+			MaxWorkers: workers,
+		})
+		require.Error(t, err)
+		outputs = append(outputs, out.String()+err.Error())
+	}
+	assert.Equal(t, outputs[0], outputs[1], "output should not depend on the number of workers")
+	assert.Equal(t, outputs[0], outputs[2], "output should not depend on the number of workers")
+}
+
+func TestSummaryError(t *testing.T) {
+	results := []validator.ValidationResult{
+		{RuleName: "a", Valid: false, Severity: apiv1.SeverityError, Err: assert.AnError},
+		{RuleName: "b", Valid: false, Severity: apiv1.SeverityWarning, Err: assert.AnError},
+		{RuleName: "c", Valid: false, Severity: apiv1.SeverityInfo, Err: assert.AnError},
+		{RuleName: "d", Valid: true},
+		{RuleName: "e", Valid: true, Skipped: true, SkipReason: "exempt"},
+	}
+
+	err := summaryError(results, apiv1.SeverityError)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1/5 checks failed (20.0% failure rate) with 1 warning, 1 info, 1 skipped")
+
+	err = summaryError(results, apiv1.SeverityInfo)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3/5 checks failed")
+
+	assert.NoError(t, summaryError(results[1:], apiv1.SeverityError))
+}
+
+func TestValidaterExplain(t *testing.T) {
+	file := filepath.Join("..", "..", "..", "fixtures", "resources", "invalid-deployments.yaml")
+	run := func(output string) (string, error) {
+		out := &bytes.Buffer{}
+		v := &Validater{IOStreams: genericiooptions.IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}}
+		err := v.Validate(Options{
+			Files:      []string{file},
+			Expression: "object.spec.template.spec.containers.all(c, has(c.resources.limits) && c.image.contains('@sha256'))",
+			TargetName: "app-no-limits",
+			Explain:    true,
+			Output:     output,
+			MaxWorkers: 128,
+		})
+		return out.String(), err
+	}
+
+	out, err := run("text")
+	require.Error(t, err)
+	assert.Contains(t, out, `    ❌ [inline] Deployment/app-no-limits: Validation failed (`+file+`:44:7)
+        object.spec.template.spec.containers.all(c, has(c.resources.limits) && c.image.contains("@sha256")) = false
+          index 0: c = {"image":"backend:v1.0.0","name":"app"}
+            has(c.resources.limits) && c.image.contains("@sha256") = false
+              c.image.contains("@sha256") = false
+                c.image = "backend:v1.0.0"
+`)
+
+	out, err = run("json")
+	require.Error(t, err)
+	assert.Contains(t, out, `"element": "index 0",`)
+	assert.Contains(t, out, `"expression": "c.image",`)
+}
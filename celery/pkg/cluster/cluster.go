@@ -0,0 +1,227 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+// AllResources lists every resource type the server can list.
+const AllResources = "all"
+
+// pageSize is the number of objects requested per list call.
+const pageSize = 500
+
+// Interface lists objects from a live cluster.
+type Interface interface {
+	List(ctx context.Context, resourceTypes []string, namespace string) ([]*unstructured.Unstructured, []error, error)
+}
+
+// Lister lists objects from a live cluster with a dynamic client.
+type Lister struct {
+	Dynamic   dynamic.Interface
+	Discovery discovery.DiscoveryInterface
+	Mapper    meta.RESTMapper
+}
+
+// NewLister creates a Lister for the cluster selected by flags.
+func NewLister(flags *genericclioptions.ConfigFlags) (*Lister, error) {
+	restConfig, err := flags.ToRESTConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("creating dynamic client: %w", err)
+	}
+
+	discoveryClient, err := flags.ToDiscoveryClient()
+	if err != nil {
+		return nil, fmt.Errorf("creating discovery client: %w", err)
+	}
+
+	mapper, err := flags.ToRESTMapper()
+	if err != nil {
+		return nil, fmt.Errorf("creating REST mapper: %w", err)
+	}
+
+	return &Lister{
+		Dynamic:   dynamicClient,
+		Discovery: discoveryClient,
+		Mapper:    restmapper.NewShortcutExpander(mapper, discoveryClient, nil),
+	}, nil
+}
+
+// List returns the objects of resourceTypes in namespace, or in every
+// namespace when namespace is empty. Resource types may be plural names, short
+// names, kinds, or resource.group, and "all" lists every discoverable type.
+//
+// Errors listing an explicitly requested type fail the call. When listing every
+// type, types that cannot be listed, e.g. because access is forbidden, are
+// skipped and returned as warnings.
+func (l *Lister) List(ctx context.Context, resourceTypes []string, namespace string) ([]*unstructured.Unstructured, []error, error) {
+	var mappings []*meta.RESTMapping
+	discoverAll := false
+	for _, resourceType := range resourceTypes {
+		if resourceType == AllResources {
+			discoverAll = true
+			continue
+		}
+		mapping, err := l.mappingFor(resourceType)
+		if err != nil {
+			return nil, nil, err
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	var warnings []error
+	if discoverAll {
+		discovered, err := l.discoverMappings(namespace != "")
+		if err != nil {
+			return nil, nil, err
+		}
+		mappings = append(mappings, discovered...)
+	}
+
+	var objects []*unstructured.Unstructured
+	seen := make(map[schema.GroupVersionResource]bool)
+	for _, mapping := range mappings {
+		if seen[mapping.Resource] {
+			continue
+		}
+		seen[mapping.Resource] = true
+
+		listed, err := l.list(ctx, mapping, namespace)
+		if err != nil {
+			if discoverAll {
+				warnings = append(warnings, err)
+				continue
+			}
+			return nil, nil, err
+		}
+		objects = append(objects, listed...)
+	}
+
+	return objects, warnings, nil
+}
+
+func (l *Lister) mappingFor(resourceType string) (*meta.RESTMapping, error) {
+	gvr, gr := schema.ParseResourceArg(strings.ToLower(resourceType))
+	if gvr == nil {
+		g := gr.WithVersion("")
+		gvr = &g
+	}
+
+	resource, err := l.Mapper.ResourceFor(*gvr)
+	if err != nil {
+		// Kinds such as Deployment or Deployment.apps are accepted too.
+		gk := schema.ParseGroupKind(resourceType)
+		mapping, kindErr := l.Mapper.RESTMapping(gk)
+		if kindErr != nil {
+			return nil, fmt.Errorf("unknown resource type %q: %w", resourceType, err)
+		}
+		return mapping, nil
+	}
+
+	gvk, err := l.Mapper.KindFor(resource)
+	if err != nil {
+		return nil, fmt.Errorf("finding kind for %s: %w", resourceType, err)
+	}
+	return l.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
+
+// discoverMappings returns a mapping for every listable resource in the
+// server's preferred versions, skipping subresources. Cluster-scoped resources
+// are left out when namespaced is set.
+func (l *Lister) discoverMappings(namespaced bool) ([]*meta.RESTMapping, error) {
+	lists, err := discovery.ServerPreferredResources(l.Discovery)
+	if err != nil && len(lists) == 0 {
+		return nil, fmt.Errorf("discovering resources: %w", err)
+	}
+
+	var mappings []*meta.RESTMapping
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") || !sets.New(resource.Verbs...).Has("list") {
+				continue
+			}
+			if namespaced && !resource.Namespaced {
+				continue
+			}
+
+			scope := meta.RESTScopeRoot
+			if resource.Namespaced {
+				scope = meta.RESTScopeNamespace
+			}
+			mappings = append(mappings, &meta.RESTMapping{
+				Resource:         gv.WithResource(resource.Name),
+				GroupVersionKind: gv.WithKind(resource.Kind),
+				Scope:            scope,
+			})
+		}
+	}
+
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].Resource.String() < mappings[j].Resource.String()
+	})
+	return mappings, nil
+}
+
+func (l *Lister) list(ctx context.Context, mapping *meta.RESTMapping, namespace string) ([]*unstructured.Unstructured, error) {
+	var client dynamic.ResourceInterface = l.Dynamic.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace && namespace != "" {
+		client = l.Dynamic.Resource(mapping.Resource).Namespace(namespace)
+	}
+
+	var objects []*unstructured.Unstructured
+	opts := metav1.ListOptions{Limit: pageSize}
+	for {
+		list, err := client.List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", mapping.Resource.GroupResource(), err)
+		}
+
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if obj.GetKind() == "" {
+				obj.SetGroupVersionKind(mapping.GroupVersionKind)
+			}
+			// managedFields are bookkeeping for server-side apply and only slow rules down.
+			unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+			objects = append(objects, obj)
+		}
+
+		if list.GetContinue() == "" {
+			return objects, nil
+		}
+		opts.Continue = list.GetContinue()
+	}
+}
+
+// ContextName returns the kubeconfig context flags select.
+func ContextName(flags *genericclioptions.ConfigFlags) (string, error) {
+	if flags.Context != nil && *flags.Context != "" {
+		return *flags.Context, nil
+	}
+	config, err := flags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return "", fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	return config.CurrentContext, nil
+}
@@ -0,0 +1,185 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var (
+	deploymentsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	namespacesGVR  = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	secretsGVR     = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+)
+
+func object(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata": map[string]any{
+			"name":          name,
+			"managedFields": []any{map[string]any{"manager": "kubectl"}},
+		},
+	}}
+	if namespace != "" {
+		obj.SetNamespace(namespace)
+	}
+	return obj
+}
+
+func newTestLister(t *testing.T) *Lister {
+	t.Helper()
+
+	listKinds := map[schema.GroupVersionResource]string{
+		deploymentsGVR: "DeploymentList",
+		namespacesGVR:  "NamespaceList",
+		secretsGVR:     "SecretList",
+	}
+	dynamicClient := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds,
+		object("apps/v1", "Deployment", "prod", "web"),
+		object("apps/v1", "Deployment", "staging", "web"),
+		object("v1", "Namespace", "", "prod"),
+		object("v1", "Secret", "prod", "token"),
+	)
+	dynamicClient.PrependReactor("list", "secrets", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, assert.AnError
+	})
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, meta.RESTScopeNamespace)
+
+	discoveryClient := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: []string{"get", "list"}},
+				{Name: "deployments/status", Kind: "Deployment", Namespaced: true, Verbs: []string{"get", "list"}},
+			},
+		},
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "namespaces", Kind: "Namespace", Verbs: []string{"get", "list"}},
+				{Name: "secrets", Kind: "Secret", Namespaced: true, Verbs: []string{"get", "list"}},
+				{Name: "bindings", Kind: "Binding", Namespaced: true, Verbs: []string{"create"}},
+			},
+		},
+	}}}
+
+	return &Lister{Dynamic: dynamicClient, Discovery: discoveryClient, Mapper: mapper}
+}
+
+func names(objects []*unstructured.Unstructured) []string {
+	var out []string
+	for _, obj := range objects {
+		name := obj.GetKind() + "/" + obj.GetName()
+		if obj.GetNamespace() != "" {
+			name = obj.GetNamespace() + "/" + name
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+func TestListResourceTypes(t *testing.T) {
+	tests := []struct {
+		name          string
+		resourceTypes []string
+		namespace     string
+		want          []string
+		wantErr       string
+	}{
+		{
+			name:          "plural resource in every namespace",
+			resourceTypes: []string{"deployments"},
+			want:          []string{"prod/Deployment/web", "staging/Deployment/web"},
+		},
+		{
+			name:          "resource.group in a namespace",
+			resourceTypes: []string{"deployments.apps"},
+			namespace:     "prod",
+			want:          []string{"prod/Deployment/web"},
+		},
+		{
+			name:          "kind",
+			resourceTypes: []string{"Namespace", "namespaces"},
+			namespace:     "prod",
+			want:          []string{"Namespace/prod"},
+		},
+		{
+			name:          "unknown type",
+			resourceTypes: []string{"widgets"},
+			wantErr:       `unknown resource type "widgets"`,
+		},
+		{
+			name:          "list error for an explicit type",
+			resourceTypes: []string{"secrets"},
+			wantErr:       "listing secrets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, warnings, err := newTestLister(t).List(context.Background(), tt.resourceTypes, tt.namespace)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Empty(t, warnings)
+			assert.ElementsMatch(t, tt.want, names(objects))
+		})
+	}
+}
+
+func TestListAll(t *testing.T) {
+	objects, warnings, err := newTestLister(t).List(context.Background(), []string{AllResources}, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"prod/Deployment/web", "staging/Deployment/web", "Namespace/prod"}, names(objects))
+	require.Len(t, warnings, 1)
+	assert.ErrorContains(t, warnings[0], "listing secrets")
+
+	for _, obj := range objects {
+		_, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "metadata", "managedFields")
+		assert.False(t, found, "managedFields should be removed")
+	}
+
+	objects, _, err = newTestLister(t).List(context.Background(), []string{AllResources}, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod/Deployment/web"}, names(objects), "cluster-scoped types are skipped in a namespace")
+}
+
+func TestNamespaceCacheLabels(t *testing.T) {
+	prod := object("v1", "Namespace", "", "prod")
+	prod.SetLabels(map[string]string{"environment": "production"})
+	dynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), prod)
+	cache := &NamespaceCache{Dynamic: dynamicClient, TTL: time.Minute}
+
+	for range 2 {
+		labels, err := cache.Labels(context.Background(), "prod")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"environment": "production"}, labels)
+	}
+	assert.Len(t, dynamicClient.Actions(), 1, "the second lookup is cached")
+
+	_, err := cache.Labels(context.Background(), "staging")
+	assert.ErrorContains(t, err, "getting namespace staging")
+}
+
+func TestNamespaceCacheWithoutClient(t *testing.T) {
+	_, err := (&NamespaceCache{}).Labels(context.Background(), "prod")
+	assert.EqualError(t, err, "no cluster client configured")
+}
@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+)
+
+// DefaultNamespaceTTL is how long a NamespaceCache keeps the labels of a
+// Namespace by default.
+const DefaultNamespaceTTL = 30 * time.Second
+
+var namespacesResource = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// NamespaceCache looks up the labels of Namespaces in a live cluster and keeps
+// them for TTL, so requests for objects in the same namespace share a lookup.
+type NamespaceCache struct {
+	Dynamic dynamic.Interface
+	TTL     time.Duration
+
+	// newDynamic creates Dynamic on the first lookup when it is nil.
+	newDynamic func() (dynamic.Interface, error)
+	once       sync.Once
+	dynamicErr error
+
+	mu      sync.Mutex
+	entries map[string]namespaceEntry
+}
+
+type namespaceEntry struct {
+	labels  map[string]string
+	expires time.Time
+}
+
+// NewNamespaceCache creates a NamespaceCache for the cluster selected by
+// flags. The client is created on the first lookup, so a cluster that cannot
+// be reached only fails lookups.
+func NewNamespaceCache(flags *genericclioptions.ConfigFlags) *NamespaceCache {
+	return &NamespaceCache{
+		TTL: DefaultNamespaceTTL,
+		newDynamic: func() (dynamic.Interface, error) {
+			restConfig, err := flags.ToRESTConfig()
+			if err != nil {
+				return nil, fmt.Errorf("loading kubeconfig: %w", err)
+			}
+			dynamicClient, err := dynamic.NewForConfig(restConfig)
+			if err != nil {
+				return nil, fmt.Errorf("creating dynamic client: %w", err)
+			}
+			return dynamicClient, nil
+		},
+	}
+}
+
+// Labels returns the labels of the Namespace name. It is safe to call
+// concurrently.
+func (c *NamespaceCache) Labels(ctx context.Context, name string) (map[string]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.labels, nil
+	}
+
+	c.once.Do(func() {
+		if c.Dynamic == nil && c.newDynamic != nil {
+			c.Dynamic, c.dynamicErr = c.newDynamic()
+		}
+	})
+	if c.dynamicErr != nil {
+		return nil, c.dynamicErr
+	}
+	if c.Dynamic == nil {
+		return nil, errors.New("no cluster client configured")
+	}
+
+	namespace, err := c.Dynamic.Resource(namespacesResource).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("getting namespace %s: %w", name, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]namespaceEntry)
+	}
+	c.entries[name] = namespaceEntry{labels: namespace.GetLabels(), expires: time.Now().Add(c.TTL)}
+	return namespace.GetLabels(), nil
+}
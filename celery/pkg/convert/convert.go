@@ -0,0 +1,635 @@
+package convert
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// unsupportedVariables are the admission CEL variables celery does not provide.
+var unsupportedVariables = regexp.MustCompile(`\b(request|namespaceObject|authorizer)\b`)
+
+// paramsVariable matches references to params, but not to fields or variables
+// named params.
+var paramsVariable = regexp.MustCompile(`(^|[^.\w])params\b`)
+
+// oldObjectVariable matches references to oldObject, but not to fields named
+// oldObject.
+var oldObjectVariable = regexp.MustCompile(`(^|[^.\w])oldObject\b`)
+
+// Issue describes part of a resource that could not be converted exactly.
+type Issue struct {
+	// Source identifies the resource the issue came from, e.g. ValidatingAdmissionPolicy/my-policy.
+	Source  string
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s", i.Source, i.Message)
+}
+
+type issues []Issue
+
+func (is *issues) add(source string, format string, args ...any) {
+	*is = append(*is, Issue{Source: source, Message: fmt.Sprintf(format, args...)})
+}
+
+// FromPolicies converts ValidatingAdmissionPolicies into ValidationRules, one per
+// policy. Match constraints become rule targets, validations become rules, and the
+// validationActions of the policy's bindings become the rule severity.
+// Validations that reference oldObject become transition rules.
+func FromPolicies(policies []admissionregistrationv1.ValidatingAdmissionPolicy, bindings []admissionregistrationv1.ValidatingAdmissionPolicyBinding) ([]apiv1.ValidationRules, []Issue) {
+	var is issues
+	var ruless []apiv1.ValidationRules
+
+	bindingsByPolicy := make(map[string][]admissionregistrationv1.ValidatingAdmissionPolicyBinding)
+	for _, binding := range bindings {
+		bindingsByPolicy[binding.Spec.PolicyName] = append(bindingsByPolicy[binding.Spec.PolicyName], binding)
+	}
+	for _, binding := range bindings {
+		if !hasPolicy(policies, binding.Spec.PolicyName) {
+			is.add("ValidatingAdmissionPolicyBinding/"+binding.Name, "policy %q was not found in the input", binding.Spec.PolicyName)
+		}
+	}
+
+	for _, policy := range policies {
+		source := "ValidatingAdmissionPolicy/" + policy.Name
+		spec := policy.Spec
+
+		if spec.ParamKind != nil {
+			is.add(source, "paramKind %s/%s has no equivalent, pass the param object with --params or spec.paramsRef", spec.ParamKind.APIVersion, spec.ParamKind.Kind)
+		}
+		if len(spec.AuditAnnotations) > 0 {
+			is.add(source, "auditAnnotations have no equivalent and were dropped")
+		}
+
+		targets := matchTargets(spec.MatchConstraints, source, &is)
+		severity := apiv1.SeverityError
+		policyBindings := bindingsByPolicy[policy.Name]
+		switch len(policyBindings) {
+		case 0:
+			is.add(source, "no ValidatingAdmissionPolicyBinding found, rules default to error severity")
+		case 1:
+			binding := policyBindings[0]
+			bindingSource := "ValidatingAdmissionPolicyBinding/" + binding.Name
+			severity = actionsToSeverity(binding.Spec.ValidationActions)
+			targets = applyBindingMatch(targets, binding.Spec.MatchResources, bindingSource, &is)
+			if binding.Spec.ParamRef != nil {
+				is.add(bindingSource, "paramRef has no equivalent")
+			}
+		default:
+			severity = apiv1.SeverityInfo
+			for _, binding := range policyBindings {
+				if s := actionsToSeverity(binding.Spec.ValidationActions); s.AtLeast(severity) {
+					severity = s
+				}
+			}
+			is.add(source, "has %d bindings, their match resources were not applied and the most severe validationActions were used", len(policyBindings))
+		}
+
+		var conditions []string
+		for _, condition := range spec.MatchConditions {
+			checkVariables(condition.Expression, fmt.Sprintf("%s matchCondition %q", source, condition.Name), &is)
+			conditions = append(conditions, condition.Expression)
+		}
+
+		rules := apiv1.ValidationRules{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: apiv1.GroupVersion,
+				Kind:       apiv1.ValidationRulesKind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: policy.Name,
+			},
+		}
+
+		// oldVariables are the variables that depend on oldObject.
+		var oldVariables []string
+		for _, variable := range spec.Variables {
+			checkVariables(variable.Expression, fmt.Sprintf("%s variable %q", source, variable.Name), &is)
+			if oldObjectVariable.MatchString(variable.Expression) || referencesAny(variable.Expression, oldVariables) {
+				oldVariables = append(oldVariables, variable.Name)
+			}
+			rules.Spec.Variables = append(rules.Spec.Variables, apiv1.Variable{
+				Name:       variable.Name,
+				Expression: variable.Expression,
+			})
+		}
+
+		names := make(map[string]int)
+		for i, validation := range spec.Validations {
+			validationSource := fmt.Sprintf("%s validations[%d]", source, i)
+			checkVariables(validation.Expression, validationSource, &is)
+			checkVariables(validation.MessageExpression, validationSource+" messageExpression", &is)
+			if validation.Reason != nil {
+				is.add(validationSource, "reason %s has no equivalent", *validation.Reason)
+			}
+
+			expression := foldConditions(conditions, validation.Expression)
+			transition := oldObjectVariable.MatchString(expression) || referencesAny(expression, oldVariables)
+			if transition {
+				is.add(validationSource, "references oldObject, converted to a transition rule that is only evaluated when a previous version is known")
+			}
+
+			baseName := policy.Name
+			if len(spec.Validations) > 1 {
+				baseName = fmt.Sprintf("%s-%d", policy.Name, i)
+			}
+
+			for _, target := range targets {
+				name := baseName
+				if len(targets) > 1 {
+					name = uniqueName(names, baseName+"-"+targetSuffix(target))
+				}
+
+				rule := apiv1.ValidationRule{
+					Name:              name,
+					Expression:        expression,
+					Message:           validation.Message,
+					MessageExpression: validation.MessageExpression,
+					Transition:        transition,
+				}
+				if severity != apiv1.SeverityError {
+					rule.Severity = severity
+				}
+				if target != (apiv1.TargetSelector{}) {
+					t := target
+					rule.Target = &t
+				}
+				rules.Spec.Rules = append(rules.Spec.Rules, rule)
+			}
+		}
+
+		ruless = append(ruless, rules)
+	}
+
+	return ruless, is
+}
+
+// ToPolicies converts ValidationRules into ValidatingAdmissionPolicies and bindings.
+// Rules from the same ValidationRules that share a target and severity are grouped
+// into one policy, and each policy gets a binding whose validationActions match
+// the rule severity.
+func ToPolicies(ruless []apiv1.ValidationRules) ([]admissionregistrationv1.ValidatingAdmissionPolicy, []admissionregistrationv1.ValidatingAdmissionPolicyBinding, []Issue) {
+	var is issues
+	var policies []admissionregistrationv1.ValidatingAdmissionPolicy
+	var bindings []admissionregistrationv1.ValidatingAdmissionPolicyBinding
+
+	for _, rules := range ruless {
+		source := "ValidationRules/" + rules.Name
+		if len(rules.Spec.Include) > 0 || len(rules.Spec.Overrides) > 0 {
+			is.add(source, "include and overrides are not converted, convert the output of celery rules --resolved instead")
+		}
+
+		type group struct {
+			target   apiv1.TargetSelector
+			severity apiv1.Severity
+			rules    []apiv1.ValidationRule
+		}
+		var groups []*group
+		for _, rule := range rules.Spec.Rules {
+			targets := rule.Selectors()
+			if len(targets) == 0 {
+				targets = []apiv1.TargetSelector{{}}
+			}
+			if strings.Contains(rule.Expression, "allObjects") || strings.Contains(rule.MessageExpression, "allObjects") {
+				is.add(source, "rule %q references allObjects, which has no equivalent in admission", rule.Name)
+			}
+			if paramsVariable.MatchString(rule.Expression) || paramsVariable.MatchString(rule.MessageExpression) {
+				is.add(source, "rule %q references params, which need a paramKind on its policy and a paramRef on its binding", rule.Name)
+			}
+			if rule.Fix != nil {
+				is.add(source, "rule %q has a fix, which has no equivalent in admission and is dropped", rule.Name)
+			}
+			if len(rule.Exclude) > 0 {
+				is.add(source, "rule %q: exclude has no equivalent and was dropped", rule.Name)
+			}
+			severity, err := apiv1.ParseSeverity(string(rule.Severity))
+			if err != nil {
+				is.add(source, "rule %q: %v, using error", rule.Name, err)
+				severity = apiv1.SeverityError
+			}
+
+			// A rule with several targets is added to the policy of each.
+			for _, target := range targets {
+				var g *group
+				for _, existing := range groups {
+					if existing.target == target && existing.severity == severity {
+						g = existing
+						break
+					}
+				}
+				if g == nil {
+					g = &group{target: target, severity: severity}
+					groups = append(groups, g)
+				}
+				g.rules = append(g.rules, rule)
+			}
+		}
+
+		names := make(map[string]int)
+		for _, g := range groups {
+			name := rules.Name
+			if len(groups) > 1 {
+				name = uniqueName(names, rules.Name+"-"+g.rules[0].Name)
+			}
+
+			policy := admissionregistrationv1.ValidatingAdmissionPolicy{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: admissionregistrationv1.SchemeGroupVersion.String(),
+					Kind:       "ValidatingAdmissionPolicy",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name,
+				},
+				Spec: admissionregistrationv1.ValidatingAdmissionPolicySpec{
+					MatchConstraints: targetToMatch(g.target, source, &is),
+				},
+			}
+
+			for _, variable := range rules.Spec.Variables {
+				policy.Spec.Variables = append(policy.Spec.Variables, admissionregistrationv1.Variable{
+					Name:       variable.Name,
+					Expression: variable.Expression,
+				})
+			}
+
+			for _, rule := range g.rules {
+				expression := rule.Expression
+				if rule.Transition {
+					expression = foldConditions([]string{"oldObject != null"}, expression)
+				}
+				policy.Spec.Validations = append(policy.Spec.Validations, admissionregistrationv1.Validation{
+					Expression:        expression,
+					Message:           rule.Message,
+					MessageExpression: rule.MessageExpression,
+				})
+			}
+			policies = append(policies, policy)
+
+			bindings = append(bindings, admissionregistrationv1.ValidatingAdmissionPolicyBinding{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: admissionregistrationv1.SchemeGroupVersion.String(),
+					Kind:       "ValidatingAdmissionPolicyBinding",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name + "-binding",
+				},
+				Spec: admissionregistrationv1.ValidatingAdmissionPolicyBindingSpec{
+					PolicyName:        name,
+					ValidationActions: severityToActions(g.severity),
+				},
+			})
+		}
+	}
+
+	return policies, bindings, is
+}
+
+// matchTargets converts match constraints into target selectors, one per
+// group, resource and resource name combination.
+func matchTargets(match *admissionregistrationv1.MatchResources, source string, is *issues) []apiv1.TargetSelector {
+	if match == nil {
+		return []apiv1.TargetSelector{{}}
+	}
+
+	var base apiv1.TargetSelector
+	base.Namespace, base.NamespaceSelector = namespaceFromSelector(match.NamespaceSelector, source, is)
+	if match.ObjectSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(match.ObjectSelector)
+		if err != nil {
+			is.add(source, "objectSelector: %v", err)
+		} else if !selector.Empty() {
+			base.LabelSelector = selector.String()
+		}
+	}
+	if len(match.ExcludeResourceRules) > 0 {
+		is.add(source, "excludeResourceRules have no equivalent and were dropped")
+	}
+
+	if len(match.ResourceRules) == 0 {
+		return []apiv1.TargetSelector{base}
+	}
+
+	var targets []apiv1.TargetSelector
+	seen := make(map[apiv1.TargetSelector]bool)
+	for _, rule := range match.ResourceRules {
+		if !appliesToManifests(rule.Operations) {
+			is.add(source, "resourceRule with operations %v does not apply to CREATE or UPDATE and was dropped", rule.Operations)
+			continue
+		}
+
+		for _, group := range orWildcard(rule.APIGroups) {
+			for _, version := range orWildcard(rule.APIVersions) {
+				for _, resource := range orWildcard(rule.Resources) {
+					if strings.Contains(resource, "/") {
+						is.add(source, "subresource %s has no equivalent and was dropped", resource)
+						continue
+					}
+
+					target := base
+					target.Group = wildcardToEmpty(group)
+					target.Version = wildcardToEmpty(version)
+					if resource != "*" {
+						kind, known := resourceToKind(resource)
+						if !known {
+							is.add(source, "resource %s is not a built-in type, guessed kind %s", resource, kind)
+						}
+						target.Kind = kind
+					}
+
+					for _, name := range orEmpty(rule.ResourceNames) {
+						target.Name = name
+						if !seen[target] {
+							seen[target] = true
+							targets = append(targets, target)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return targets
+}
+
+// applyBindingMatch narrows targets with the namespace and object selectors of a binding.
+func applyBindingMatch(targets []apiv1.TargetSelector, match *admissionregistrationv1.MatchResources, source string, is *issues) []apiv1.TargetSelector {
+	if match == nil {
+		return targets
+	}
+	if len(match.ResourceRules) > 0 || len(match.ExcludeResourceRules) > 0 {
+		is.add(source, "binding resourceRules and excludeResourceRules have no equivalent and were dropped")
+	}
+
+	namespace, namespaceSelector := namespaceFromSelector(match.NamespaceSelector, source, is)
+
+	var labelSelector string
+	if match.ObjectSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(match.ObjectSelector)
+		if err != nil {
+			is.add(source, "objectSelector: %v", err)
+		} else if !selector.Empty() {
+			labelSelector = selector.String()
+		}
+	}
+
+	narrowed := make([]apiv1.TargetSelector, 0, len(targets))
+	for _, target := range targets {
+		if namespace != "" {
+			if target.Namespace != "" && target.Namespace != namespace {
+				is.add(source, "binding namespace %s conflicts with policy namespace %s", namespace, target.Namespace)
+			}
+			target.Namespace = namespace
+		}
+		if namespaceSelector != "" {
+			if target.NamespaceSelector != "" {
+				target.NamespaceSelector += "," + namespaceSelector
+			} else {
+				target.NamespaceSelector = namespaceSelector
+			}
+		}
+		if labelSelector != "" {
+			if target.LabelSelector != "" {
+				target.LabelSelector += "," + labelSelector
+			} else {
+				target.LabelSelector = labelSelector
+			}
+		}
+		narrowed = append(narrowed, target)
+	}
+	return narrowed
+}
+
+// targetToMatch converts a target selector into admission match constraints.
+func targetToMatch(target apiv1.TargetSelector, source string, is *issues) *admissionregistrationv1.MatchResources {
+	resource := "*"
+	if target.Kind != "" {
+		var known bool
+		resource, known = kindToResource(target.Kind)
+		if !known {
+			is.add(source, "kind %s is not a built-in type, guessed resource %s", target.Kind, resource)
+		}
+	}
+
+	rule := admissionregistrationv1.NamedRuleWithOperations{
+		RuleWithOperations: admissionregistrationv1.RuleWithOperations{
+			Operations: []admissionregistrationv1.OperationType{
+				admissionregistrationv1.Create,
+				admissionregistrationv1.Update,
+			},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{emptyToWildcard(target.Group)},
+				APIVersions: []string{emptyToWildcard(target.Version)},
+				Resources:   []string{resource},
+			},
+		},
+	}
+	if target.Name != "" {
+		rule.ResourceNames = []string{target.Name}
+	}
+	if target.NamePattern != "" {
+		is.add(source, "namePattern %q has no equivalent and was dropped", target.NamePattern)
+	}
+	if target.NameRegex != "" {
+		is.add(source, "nameRegex %q has no equivalent and was dropped", target.NameRegex)
+	}
+
+	match := &admissionregistrationv1.MatchResources{
+		ResourceRules: []admissionregistrationv1.NamedRuleWithOperations{rule},
+	}
+	namespaceSelector := &metav1.LabelSelector{}
+	if target.NamespaceSelector != "" {
+		selector, err := metav1.ParseToLabelSelector(target.NamespaceSelector)
+		if err != nil {
+			is.add(source, "namespaceSelector %q: %v", target.NamespaceSelector, err)
+		} else {
+			namespaceSelector = selector
+		}
+	}
+	if target.Namespace != "" {
+		if namespaceSelector.MatchLabels == nil {
+			namespaceSelector.MatchLabels = make(map[string]string)
+		}
+		namespaceSelector.MatchLabels[namespaceNameLabel] = target.Namespace
+	}
+	if len(namespaceSelector.MatchLabels) > 0 || len(namespaceSelector.MatchExpressions) > 0 {
+		match.NamespaceSelector = namespaceSelector
+	}
+	if target.LabelSelector != "" {
+		selector, err := metav1.ParseToLabelSelector(target.LabelSelector)
+		if err != nil {
+			is.add(source, "labelSelector %q: %v", target.LabelSelector, err)
+		} else {
+			match.ObjectSelector = selector
+		}
+	}
+	if target.AnnotationSelector != "" {
+		is.add(source, "annotationSelector %q has no equivalent and was dropped", target.AnnotationSelector)
+	}
+
+	return match
+}
+
+// namespaceFromSelector splits a namespaceSelector into the namespace it
+// selects by name, if any, and a selector over the rest of its requirements.
+// A nil or empty selector maps to all namespaces.
+func namespaceFromSelector(selector *metav1.LabelSelector, source string, is *issues) (string, string) {
+	if selector == nil || (len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0) {
+		return "", ""
+	}
+
+	rest := selector.DeepCopy()
+	namespace, ok := rest.MatchLabels[namespaceNameLabel]
+	if ok {
+		delete(rest.MatchLabels, namespaceNameLabel)
+	} else if len(rest.MatchExpressions) == 1 && len(rest.MatchLabels) == 0 {
+		expr := rest.MatchExpressions[0]
+		if expr.Key == namespaceNameLabel && expr.Operator == metav1.LabelSelectorOpIn && len(expr.Values) == 1 {
+			return expr.Values[0], ""
+		}
+	}
+
+	parsed, err := metav1.LabelSelectorAsSelector(rest)
+	if err != nil {
+		is.add(source, "namespaceSelector: %v", err)
+		return namespace, ""
+	}
+	if parsed.Empty() {
+		return namespace, ""
+	}
+	return namespace, parsed.String()
+}
+
+func actionsToSeverity(actions []admissionregistrationv1.ValidationAction) apiv1.Severity {
+	severity := apiv1.SeverityInfo
+	if len(actions) == 0 {
+		return apiv1.SeverityError
+	}
+	for _, action := range actions {
+		switch action {
+		case admissionregistrationv1.Deny:
+			return apiv1.SeverityError
+		case admissionregistrationv1.Warn:
+			severity = apiv1.SeverityWarning
+		}
+	}
+	return severity
+}
+
+func severityToActions(severity apiv1.Severity) []admissionregistrationv1.ValidationAction {
+	switch severity {
+	case apiv1.SeverityWarning:
+		return []admissionregistrationv1.ValidationAction{admissionregistrationv1.Warn}
+	case apiv1.SeverityInfo:
+		return []admissionregistrationv1.ValidationAction{admissionregistrationv1.Audit}
+	default:
+		return []admissionregistrationv1.ValidationAction{admissionregistrationv1.Deny}
+	}
+}
+
+// foldConditions guards expression with match conditions so the rule passes when
+// any condition is false, mirroring how admission skips the policy.
+func foldConditions(conditions []string, expression string) string {
+	if len(conditions) == 0 {
+		return expression
+	}
+
+	var guards []string
+	for _, condition := range conditions {
+		guards = append(guards, fmt.Sprintf("!(%s)", strings.TrimSpace(condition)))
+	}
+	return fmt.Sprintf("%s || (%s)", strings.Join(guards, " || "), strings.TrimSpace(expression))
+}
+
+func checkVariables(expression string, source string, is *issues) {
+	seen := make(map[string]bool)
+	for _, match := range unsupportedVariables.FindAllString(expression, -1) {
+		if seen[match] {
+			continue
+		}
+		seen[match] = true
+		is.add(source, "references %s, which celery does not provide", match)
+	}
+}
+
+// referencesAny reports whether expression references any of the variables.
+func referencesAny(expression string, variables []string) bool {
+	for _, name := range variables {
+		if regexp.MustCompile(`\bvariables\.` + regexp.QuoteMeta(name) + `\b`).MatchString(expression) {
+			return true
+		}
+	}
+	return false
+}
+
+func appliesToManifests(operations []admissionregistrationv1.OperationType) bool {
+	for _, op := range operations {
+		if op == admissionregistrationv1.OperationAll || op == admissionregistrationv1.Create || op == admissionregistrationv1.Update {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPolicy(policies []admissionregistrationv1.ValidatingAdmissionPolicy, name string) bool {
+	for _, policy := range policies {
+		if policy.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func targetSuffix(target apiv1.TargetSelector) string {
+	suffix := "all"
+	if target.Kind != "" {
+		suffix = strings.ToLower(target.Kind)
+	}
+	if target.Name != "" {
+		suffix += "-" + target.Name
+	}
+	return suffix
+}
+
+func uniqueName(names map[string]int, name string) string {
+	names[name]++
+	if n := names[name]; n > 1 {
+		return fmt.Sprintf("%s-%d", name, n)
+	}
+	return name
+}
+
+func orWildcard(values []string) []string {
+	if len(values) == 0 {
+		return []string{"*"}
+	}
+	return values
+}
+
+func orEmpty(values []string) []string {
+	if len(values) == 0 {
+		return []string{""}
+	}
+	return values
+}
+
+func wildcardToEmpty(value string) string {
+	if value == "*" {
+		return ""
+	}
+	return value
+}
+
+func emptyToWildcard(value string) string {
+	if value == "" {
+		return "*"
+	}
+	return value
+}
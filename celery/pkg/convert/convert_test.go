@@ -0,0 +1,477 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func policy(name string, spec admissionregistrationv1.ValidatingAdmissionPolicySpec) admissionregistrationv1.ValidatingAdmissionPolicy {
+	return admissionregistrationv1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       spec,
+	}
+}
+
+func binding(policyName string, actions ...admissionregistrationv1.ValidationAction) admissionregistrationv1.ValidatingAdmissionPolicyBinding {
+	return admissionregistrationv1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: policyName + "-binding"},
+		Spec: admissionregistrationv1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        policyName,
+			ValidationActions: actions,
+		},
+	}
+}
+
+func resourceRule(groups, versions, resources []string, ops ...admissionregistrationv1.OperationType) admissionregistrationv1.NamedRuleWithOperations {
+	return admissionregistrationv1.NamedRuleWithOperations{
+		RuleWithOperations: admissionregistrationv1.RuleWithOperations{
+			Operations: ops,
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   groups,
+				APIVersions: versions,
+				Resources:   resources,
+			},
+		},
+	}
+}
+
+func issueMessages(issues []Issue) string {
+	var msgs []string
+	for _, issue := range issues {
+		msgs = append(msgs, issue.String())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func TestFromPolicies(t *testing.T) {
+	p := policy("replicas", admissionregistrationv1.ValidatingAdmissionPolicySpec{
+		MatchConstraints: &admissionregistrationv1.MatchResources{
+			ResourceRules: []admissionregistrationv1.NamedRuleWithOperations{
+				resourceRule([]string{"apps"}, []string{"v1"}, []string{"deployments", "statefulsets"}, admissionregistrationv1.Create, admissionregistrationv1.Update),
+			},
+			ObjectSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "web"}},
+		},
+		Variables: []admissionregistrationv1.Variable{
+			{Name: "replicas", Expression: "object.spec.replicas"},
+		},
+		Validations: []admissionregistrationv1.Validation{
+			{Expression: "variables.replicas >= 3", Message: "too few replicas"},
+		},
+	})
+
+	ruless, issues := FromPolicies(
+		[]admissionregistrationv1.ValidatingAdmissionPolicy{p},
+		[]admissionregistrationv1.ValidatingAdmissionPolicyBinding{binding("replicas", admissionregistrationv1.Warn, admissionregistrationv1.Audit)},
+	)
+	assert.Empty(t, issues, issueMessages(issues))
+	require.Len(t, ruless, 1)
+
+	rules := ruless[0]
+	assert.Equal(t, "replicas", rules.Name)
+	assert.Equal(t, apiv1.GroupVersion, rules.APIVersion)
+	assert.Equal(t, []apiv1.Variable{{Name: "replicas", Expression: "object.spec.replicas"}}, rules.Spec.Variables)
+	require.Len(t, rules.Spec.Rules, 2)
+
+	assert.Equal(t, apiv1.ValidationRule{
+		Name:       "replicas-deployment",
+		Expression: "variables.replicas >= 3",
+		Message:    "too few replicas",
+		Severity:   apiv1.SeverityWarning,
+		Target:     &apiv1.TargetSelector{Group: "apps", Version: "v1", Kind: "Deployment", LabelSelector: "tier=web"},
+	}, rules.Spec.Rules[0])
+	assert.Equal(t, "replicas-statefulset", rules.Spec.Rules[1].Name)
+	assert.Equal(t, "StatefulSet", rules.Spec.Rules[1].Target.Kind)
+}
+
+func TestFromPoliciesSeverity(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []admissionregistrationv1.ValidationAction
+		want    apiv1.Severity
+	}{
+		{name: "deny", actions: []admissionregistrationv1.ValidationAction{admissionregistrationv1.Deny}, want: ""},
+		{name: "deny and audit", actions: []admissionregistrationv1.ValidationAction{admissionregistrationv1.Audit, admissionregistrationv1.Deny}, want: ""},
+		{name: "warn", actions: []admissionregistrationv1.ValidationAction{admissionregistrationv1.Warn}, want: apiv1.SeverityWarning},
+		{name: "audit", actions: []admissionregistrationv1.ValidationAction{admissionregistrationv1.Audit}, want: apiv1.SeverityInfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := policy("p", admissionregistrationv1.ValidatingAdmissionPolicySpec{
+				Validations: []admissionregistrationv1.Validation{{Expression: "true"}},
+			})
+			ruless, _ := FromPolicies(
+				[]admissionregistrationv1.ValidatingAdmissionPolicy{p},
+				[]admissionregistrationv1.ValidatingAdmissionPolicyBinding{binding("p", tt.actions...)},
+			)
+			require.Len(t, ruless, 1)
+			assert.Equal(t, tt.want, ruless[0].Spec.Rules[0].Severity)
+		})
+	}
+}
+
+func TestFromPoliciesMatchConditions(t *testing.T) {
+	p := policy("p", admissionregistrationv1.ValidatingAdmissionPolicySpec{
+		MatchConditions: []admissionregistrationv1.MatchCondition{
+			{Name: "has-replicas", Expression: "has(object.spec.replicas)"},
+		},
+		Validations: []admissionregistrationv1.Validation{{Expression: "object.spec.replicas > 0"}},
+	})
+
+	ruless, _ := FromPolicies([]admissionregistrationv1.ValidatingAdmissionPolicy{p}, nil)
+	require.Len(t, ruless, 1)
+	assert.Equal(t, "!(has(object.spec.replicas)) || (object.spec.replicas > 0)", ruless[0].Spec.Rules[0].Expression)
+}
+
+func TestFromPoliciesIssues(t *testing.T) {
+	reason := metav1.StatusReasonForbidden
+	p := policy("p", admissionregistrationv1.ValidatingAdmissionPolicySpec{
+		ParamKind: &admissionregistrationv1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+		MatchConstraints: &admissionregistrationv1.MatchResources{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+			ResourceRules: []admissionregistrationv1.NamedRuleWithOperations{
+				resourceRule([]string{""}, []string{"v1"}, []string{"pods/exec"}, admissionregistrationv1.Connect),
+				resourceRule([]string{"example.com"}, []string{"v1"}, []string{"widgets", "pods/status"}, admissionregistrationv1.OperationAll),
+			},
+		},
+		Validations: []admissionregistrationv1.Validation{
+			{Expression: "object.spec.size <= params.data.max && oldObject == null", Reason: &reason},
+		},
+		AuditAnnotations: []admissionregistrationv1.AuditAnnotation{{Key: "k", ValueExpression: "'v'"}},
+	})
+
+	ruless, issues := FromPolicies([]admissionregistrationv1.ValidatingAdmissionPolicy{p}, nil)
+	require.Len(t, ruless, 1)
+	require.Len(t, ruless[0].Spec.Rules, 1)
+	assert.Equal(t, "Widget", ruless[0].Spec.Rules[0].Target.Kind)
+	assert.Equal(t, "env=prod", ruless[0].Spec.Rules[0].Target.NamespaceSelector)
+
+	msgs := issueMessages(issues)
+	assert.Contains(t, msgs, "references oldObject, converted to a transition rule")
+	assert.NotContains(t, msgs, "references params", "celery provides params")
+	for _, want := range []string{
+		"paramKind v1/ConfigMap has no equivalent, pass the param object with --params",
+		"auditAnnotations",
+		"operations [CONNECT]",
+		"subresource pods/status",
+		"guessed kind Widget",
+		"reason Forbidden",
+		"no ValidatingAdmissionPolicyBinding found",
+	} {
+		assert.Contains(t, msgs, want)
+	}
+}
+
+func TestFromPoliciesOldObject(t *testing.T) {
+	p := policy("p", admissionregistrationv1.ValidatingAdmissionPolicySpec{
+		Variables: []admissionregistrationv1.Variable{
+			{Name: "oldSize", Expression: "oldObject.spec.size"},
+			{Name: "grown", Expression: "object.spec.size >= variables.oldSize"},
+			{Name: "size", Expression: "object.spec.oldObject"},
+		},
+		Validations: []admissionregistrationv1.Validation{
+			{Expression: "object.spec.size >= oldObject.spec.size"},
+			{Expression: "variables.grown"},
+			{Expression: "variables.size > 0 && object.oldObject == null"},
+		},
+	})
+
+	ruless, issues := FromPolicies([]admissionregistrationv1.ValidatingAdmissionPolicy{p}, nil)
+	require.Len(t, ruless, 1)
+	require.Len(t, ruless[0].Spec.Rules, 3)
+	assert.True(t, ruless[0].Spec.Rules[0].Transition)
+	assert.True(t, ruless[0].Spec.Rules[1].Transition, "variables referencing oldObject should make a transition rule")
+	assert.False(t, ruless[0].Spec.Rules[2].Transition, "fields named oldObject are not oldObject")
+
+	msgs := issueMessages(issues)
+	assert.Contains(t, msgs, "validations[0]: references oldObject, converted to a transition rule")
+	assert.Contains(t, msgs, "validations[1]: references oldObject")
+	assert.NotContains(t, msgs, "validations[2]: references oldObject")
+}
+
+func TestFromPoliciesBindingMatch(t *testing.T) {
+	p := policy("p", admissionregistrationv1.ValidatingAdmissionPolicySpec{
+		Validations: []admissionregistrationv1.Validation{{Expression: "true"}},
+	})
+	b := binding("p", admissionregistrationv1.Deny)
+	b.Spec.MatchResources = &admissionregistrationv1.MatchResources{
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: namespaceNameLabel, Operator: metav1.LabelSelectorOpIn, Values: []string{"prod"}},
+			},
+		},
+		ObjectSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+	}
+	other := binding("missing", admissionregistrationv1.Deny)
+
+	ruless, issues := FromPolicies(
+		[]admissionregistrationv1.ValidatingAdmissionPolicy{p},
+		[]admissionregistrationv1.ValidatingAdmissionPolicyBinding{b, other},
+	)
+	require.Len(t, ruless, 1)
+	assert.Equal(t, &apiv1.TargetSelector{Namespace: "prod", LabelSelector: "app=web"}, ruless[0].Spec.Rules[0].Target)
+	require.Len(t, issues, 1)
+	assert.Contains(t, issues[0].String(), `policy "missing" was not found`)
+}
+
+func TestFromPoliciesNamespaceSelector(t *testing.T) {
+	p := policy("p", admissionregistrationv1.ValidatingAdmissionPolicySpec{
+		MatchConstraints: &admissionregistrationv1.MatchResources{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{namespaceNameLabel: "payments", "team": "payments"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "environment", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"dev"}},
+				},
+			},
+		},
+		Validations: []admissionregistrationv1.Validation{{Expression: "true"}},
+	})
+	b := binding("p", admissionregistrationv1.Deny)
+	b.Spec.MatchResources = &admissionregistrationv1.MatchResources{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "backend"}},
+	}
+	invalid := policy("invalid", admissionregistrationv1.ValidatingAdmissionPolicySpec{
+		MatchConstraints: &admissionregistrationv1.MatchResources{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "environment", Operator: "Near"}},
+			},
+		},
+		Validations: []admissionregistrationv1.Validation{{Expression: "true"}},
+	})
+
+	ruless, issues := FromPolicies(
+		[]admissionregistrationv1.ValidatingAdmissionPolicy{p, invalid},
+		[]admissionregistrationv1.ValidatingAdmissionPolicyBinding{b},
+	)
+	require.Len(t, ruless, 2)
+	assert.Equal(t, &apiv1.TargetSelector{
+		Namespace:         "payments",
+		NamespaceSelector: "environment notin (dev),team=payments,tier=backend",
+	}, ruless[0].Spec.Rules[0].Target)
+	assert.Nil(t, ruless[1].Spec.Rules[0].Target, "an invalid namespaceSelector should be dropped")
+
+	msgs := issueMessages(issues)
+	assert.Contains(t, msgs, `ValidatingAdmissionPolicy/invalid: namespaceSelector: "Near" is not a valid label selector operator`)
+	assert.NotContains(t, msgs, "ValidatingAdmissionPolicy/p: namespaceSelector")
+}
+
+func TestToPolicies(t *testing.T) {
+	rules := apiv1.ValidationRules{
+		ObjectMeta: metav1.ObjectMeta{Name: "standards"},
+		Spec: apiv1.ValidationRulesSpec{
+			Variables: []apiv1.Variable{{Name: "labels", Expression: "object.metadata.labels"}},
+			Rules: []apiv1.ValidationRule{
+				{
+					Name:       "replicas",
+					Expression: "object.spec.replicas >= 3",
+					Message:    "too few replicas",
+					Target:     &apiv1.TargetSelector{Group: "apps", Kind: "Deployment", Namespace: "prod", LabelSelector: "tier in (web,api)"},
+				},
+				{
+					Name:              "strategy",
+					Expression:        "has(object.spec.strategy)",
+					MessageExpression: "'missing strategy'",
+					Target:            &apiv1.TargetSelector{Group: "apps", Kind: "Deployment", Namespace: "prod", LabelSelector: "tier in (web,api)"},
+				},
+				{
+					Name:       "team-label",
+					Expression: "'team' in variables.labels",
+					Severity:   apiv1.SeverityWarning,
+				},
+			},
+		},
+	}
+
+	policies, bindings, issues := ToPolicies([]apiv1.ValidationRules{rules})
+	assert.Empty(t, issues, issueMessages(issues))
+	require.Len(t, policies, 2)
+	require.Len(t, bindings, 2)
+
+	deployments := policies[0]
+	assert.Equal(t, "standards-replicas", deployments.Name)
+	assert.Equal(t, "ValidatingAdmissionPolicy", deployments.Kind)
+	assert.Equal(t, "admissionregistration.k8s.io/v1", deployments.APIVersion)
+	assert.Equal(t, []admissionregistrationv1.Variable{{Name: "labels", Expression: "object.metadata.labels"}}, deployments.Spec.Variables)
+	assert.Equal(t, []admissionregistrationv1.Validation{
+		{Expression: "object.spec.replicas >= 3", Message: "too few replicas"},
+		{Expression: "has(object.spec.strategy)", MessageExpression: "'missing strategy'"},
+	}, deployments.Spec.Validations)
+
+	match := deployments.Spec.MatchConstraints
+	require.Len(t, match.ResourceRules, 1)
+	assert.Equal(t, []string{"apps"}, match.ResourceRules[0].APIGroups)
+	assert.Equal(t, []string{"*"}, match.ResourceRules[0].APIVersions)
+	assert.Equal(t, []string{"deployments"}, match.ResourceRules[0].Resources)
+	assert.Equal(t, map[string]string{namespaceNameLabel: "prod"}, match.NamespaceSelector.MatchLabels)
+	require.Len(t, match.ObjectSelector.MatchExpressions, 1)
+	assert.Equal(t, "tier", match.ObjectSelector.MatchExpressions[0].Key)
+
+	assert.Equal(t, "standards-team-label", policies[1].Name)
+	assert.Equal(t, []string{"*"}, policies[1].Spec.MatchConstraints.ResourceRules[0].Resources)
+
+	assert.Equal(t, "standards-replicas-binding", bindings[0].Name)
+	assert.Equal(t, "standards-replicas", bindings[0].Spec.PolicyName)
+	assert.Equal(t, []admissionregistrationv1.ValidationAction{admissionregistrationv1.Deny}, bindings[0].Spec.ValidationActions)
+	assert.Equal(t, []admissionregistrationv1.ValidationAction{admissionregistrationv1.Warn}, bindings[1].Spec.ValidationActions)
+}
+
+func TestToPoliciesTransition(t *testing.T) {
+	rules := apiv1.ValidationRules{
+		ObjectMeta: metav1.ObjectMeta{Name: "immutable"},
+		Spec: apiv1.ValidationRulesSpec{
+			Rules: []apiv1.ValidationRule{
+				{
+					Name:       "service-name",
+					Expression: "object.spec.serviceName == oldObject.spec.serviceName",
+					Transition: true,
+					Target:     &apiv1.TargetSelector{Group: "apps", Kind: "StatefulSet"},
+				},
+			},
+		},
+	}
+
+	policies, _, issues := ToPolicies([]apiv1.ValidationRules{rules})
+	assert.Empty(t, issues, issueMessages(issues))
+	require.Len(t, policies, 1)
+	assert.Equal(t, []admissionregistrationv1.Validation{
+		{Expression: "!(oldObject != null) || (object.spec.serviceName == oldObject.spec.serviceName)"},
+	}, policies[0].Spec.Validations, "transition rules should pass when there is no oldObject")
+}
+
+func TestToPoliciesIssues(t *testing.T) {
+	rules := apiv1.ValidationRules{
+		ObjectMeta: metav1.ObjectMeta{Name: "r"},
+		Spec: apiv1.ValidationRulesSpec{
+			Include: []string{"base.yaml"},
+			Rules: []apiv1.ValidationRule{
+				{
+					Name:       "unique",
+					Expression: "allObjects.filter(o, o.kind == object.kind).size() == 1",
+					Target:     &apiv1.TargetSelector{Kind: "Widget", AnnotationSelector: "owner=me"},
+					Fix:        &apiv1.Fix{Expression: `{"metadata": {"labels": {"unique": "true"}}}`},
+				},
+				{
+					Name:       "allowed-registry",
+					Expression: "object.spec.containers.all(c, params.registries.exists(r, c.image.startsWith(r)))",
+					Target:     &apiv1.TargetSelector{Kind: "Widget", AnnotationSelector: "owner=me"},
+				},
+				{
+					Name:       "not-params",
+					Expression: "object.spec.params.size() <= variables.params",
+					Target:     &apiv1.TargetSelector{Kind: "Widget", AnnotationSelector: "owner=me"},
+				},
+			},
+		},
+	}
+
+	policies, _, issues := ToPolicies([]apiv1.ValidationRules{rules})
+	require.Len(t, policies, 1)
+	assert.Equal(t, "r", policies[0].Name)
+	assert.Equal(t, []string{"widgets"}, policies[0].Spec.MatchConstraints.ResourceRules[0].Resources)
+
+	msgs := issueMessages(issues)
+	assert.Contains(t, msgs, "guessed resource widgets")
+	assert.Contains(t, msgs, "annotationSelector")
+	assert.Contains(t, msgs, "allObjects")
+	assert.Contains(t, msgs, "has a fix")
+	assert.Contains(t, msgs, `rule "allowed-registry" references params, which need a paramKind`)
+	assert.NotContains(t, msgs, `rule "not-params" references params`)
+	assert.Contains(t, msgs, "celery rules --resolved")
+}
+
+func TestToPoliciesTargets(t *testing.T) {
+	rules := apiv1.ValidationRules{
+		ObjectMeta: metav1.ObjectMeta{Name: "workloads"},
+		Spec: apiv1.ValidationRulesSpec{
+			Rules: []apiv1.ValidationRule{
+				{
+					Name:       "replicas",
+					Expression: "object.spec.replicas >= 2",
+					Targets: []apiv1.TargetSelector{
+						{Group: "apps", Kind: "Deployment", Namespace: "prod", NamespaceSelector: "environment=production"},
+						{Group: "apps", Kind: "StatefulSet", NamePattern: "db-*"},
+					},
+					Exclude: []apiv1.TargetSelector{{NameRegex: ".*-canary"}},
+				},
+			},
+		},
+	}
+
+	policies, _, issues := ToPolicies([]apiv1.ValidationRules{rules})
+	require.Len(t, policies, 2)
+	assert.Equal(t, "workloads-replicas", policies[0].Name)
+	assert.Equal(t, "workloads-replicas-2", policies[1].Name)
+	for _, policy := range policies {
+		assert.Equal(t, []admissionregistrationv1.Validation{{Expression: "object.spec.replicas >= 2"}}, policy.Spec.Validations)
+	}
+
+	deployments := policies[0].Spec.MatchConstraints
+	assert.Equal(t, []string{"deployments"}, deployments.ResourceRules[0].Resources)
+	assert.Equal(t, map[string]string{"environment": "production", namespaceNameLabel: "prod"}, deployments.NamespaceSelector.MatchLabels)
+
+	statefulSets := policies[1].Spec.MatchConstraints
+	assert.Equal(t, []string{"statefulsets"}, statefulSets.ResourceRules[0].Resources)
+	assert.Empty(t, statefulSets.ResourceRules[0].ResourceNames)
+	assert.Nil(t, statefulSets.NamespaceSelector)
+
+	msgs := issueMessages(issues)
+	assert.Contains(t, msgs, `rule "replicas": exclude has no equivalent`)
+	assert.Contains(t, msgs, `namePattern "db-*" has no equivalent`)
+}
+
+func TestRoundTrip(t *testing.T) {
+	rules := apiv1.ValidationRules{
+		ObjectMeta: metav1.ObjectMeta{Name: "r"},
+		Spec: apiv1.ValidationRulesSpec{
+			Rules: []apiv1.ValidationRule{
+				{
+					Name:       "r",
+					Expression: "object.spec.replicas >= 3",
+					Message:    "too few replicas",
+					Severity:   apiv1.SeverityInfo,
+					Target:     &apiv1.TargetSelector{Group: "apps", Version: "v1", Kind: "Deployment", Name: "web", Namespace: "prod", LabelSelector: "app=web", NamespaceSelector: "environment in (production,staging)"},
+				},
+			},
+		},
+	}
+
+	policies, bindings, issues := ToPolicies([]apiv1.ValidationRules{rules})
+	require.Empty(t, issues)
+	back, issues := FromPolicies(policies, bindings)
+	require.Empty(t, issues, issueMessages(issues))
+	require.Len(t, back, 1)
+	assert.Equal(t, rules.Spec, back[0].Spec)
+}
+
+func TestResourceKindMapping(t *testing.T) {
+	tests := []struct {
+		resource string
+		kind     string
+		known    bool
+	}{
+		{resource: "deployments", kind: "Deployment", known: true},
+		{resource: "ingresses", kind: "Ingress", known: true},
+		{resource: "networkpolicies", kind: "NetworkPolicy", known: true},
+		{resource: "widgets", kind: "Widget"},
+		{resource: "policies", kind: "Policy"},
+		{resource: "classes", kind: "Class"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			kind, known := resourceToKind(tt.resource)
+			assert.Equal(t, tt.kind, kind)
+			assert.Equal(t, tt.known, known)
+
+			resource, known := kindToResource(tt.kind)
+			assert.Equal(t, tt.resource, resource)
+			assert.Equal(t, tt.known, known)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package convert
+
+import (
+	"strings"
+)
+
+// builtinResources maps the plural resource names of built-in Kubernetes types to their kinds.
+var builtinResources = map[string]string{
+	"bindings":                        "Binding",
+	"configmaps":                      "ConfigMap",
+	"endpoints":                       "Endpoints",
+	"events":                          "Event",
+	"limitranges":                     "LimitRange",
+	"namespaces":                      "Namespace",
+	"nodes":                           "Node",
+	"persistentvolumeclaims":          "PersistentVolumeClaim",
+	"persistentvolumes":               "PersistentVolume",
+	"pods":                            "Pod",
+	"podtemplates":                    "PodTemplate",
+	"replicationcontrollers":          "ReplicationController",
+	"resourcequotas":                  "ResourceQuota",
+	"secrets":                         "Secret",
+	"serviceaccounts":                 "ServiceAccount",
+	"services":                        "Service",
+	"mutatingwebhookconfigurations":   "MutatingWebhookConfiguration",
+	"validatingwebhookconfigurations": "ValidatingWebhookConfiguration",
+	"validatingadmissionpolicies":     "ValidatingAdmissionPolicy",
+	"customresourcedefinitions":       "CustomResourceDefinition",
+	"controllerrevisions":             "ControllerRevision",
+	"daemonsets":                      "DaemonSet",
+	"deployments":                     "Deployment",
+	"replicasets":                     "ReplicaSet",
+	"statefulsets":                    "StatefulSet",
+	"horizontalpodautoscalers":        "HorizontalPodAutoscaler",
+	"cronjobs":                        "CronJob",
+	"jobs":                            "Job",
+	"certificatesigningrequests":      "CertificateSigningRequest",
+	"leases":                          "Lease",
+	"endpointslices":                  "EndpointSlice",
+	"ingressclasses":                  "IngressClass",
+	"ingresses":                       "Ingress",
+	"networkpolicies":                 "NetworkPolicy",
+	"runtimeclasses":                  "RuntimeClass",
+	"poddisruptionbudgets":            "PodDisruptionBudget",
+	"clusterrolebindings":             "ClusterRoleBinding",
+	"clusterroles":                    "ClusterRole",
+	"rolebindings":                    "RoleBinding",
+	"roles":                           "Role",
+	"priorityclasses":                 "PriorityClass",
+	"csidrivers":                      "CSIDriver",
+	"csinodes":                        "CSINode",
+	"storageclasses":                  "StorageClass",
+	"volumeattachments":               "VolumeAttachment",
+}
+
+var builtinKinds = func() map[string]string {
+	kinds := make(map[string]string, len(builtinResources))
+	for resource, kind := range builtinResources {
+		kinds[kind] = resource
+	}
+	return kinds
+}()
+
+// resourceToKind returns the kind for a plural resource name. The second return
+// value is false when the kind had to be guessed because the resource is not a
+// known built-in type.
+func resourceToKind(resource string) (string, bool) {
+	if kind, ok := builtinResources[resource]; ok {
+		return kind, true
+	}
+
+	singular := resource
+	switch {
+	case strings.HasSuffix(singular, "ies"):
+		singular = strings.TrimSuffix(singular, "ies") + "y"
+	case strings.HasSuffix(singular, "ses"):
+		singular = strings.TrimSuffix(singular, "es")
+	case strings.HasSuffix(singular, "s"):
+		singular = strings.TrimSuffix(singular, "s")
+	}
+	if singular == "" {
+		return "", false
+	}
+	return strings.ToUpper(singular[:1]) + singular[1:], false
+}
+
+// kindToResource returns the plural resource name for a kind. The second return
+// value is false when the resource had to be guessed because the kind is not a
+// known built-in type.
+func kindToResource(kind string) (string, bool) {
+	if resource, ok := builtinKinds[kind]; ok {
+		return resource, true
+	}
+
+	lower := strings.ToLower(kind)
+	switch {
+	case strings.HasSuffix(lower, "y"):
+		return strings.TrimSuffix(lower, "y") + "ies", false
+	case strings.HasSuffix(lower, "s"):
+		return lower + "es", false
+	default:
+		return lower + "s", false
+	}
+}
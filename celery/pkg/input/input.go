@@ -0,0 +1,183 @@
+package input
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// Stdin is the path argument that selects standard input.
+	Stdin = "-"
+	// StdinName is the label used for standard input in validation results.
+	StdinName = "<stdin>"
+)
+
+var manifestExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+}
+
+// Expand resolves path arguments into the list of manifest sources to validate.
+// No paths selects stdin. Directories are walked recursively, keeping files with a
+// .yaml, .yml or .json extension that match at least one include glob (when any are
+// given) and no exclude glob. Globs are matched against both the base name and the
+// slash-separated path relative to the walked directory. Explicit file arguments are
+// always kept.
+func Expand(paths []string, include []string, exclude []string) ([]string, error) {
+	if len(paths) == 0 {
+		return []string{Stdin}, nil
+	}
+
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %s: %w", pattern, err)
+		}
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	add := func(file string) {
+		if seen[file] {
+			return
+		}
+		seen[file] = true
+		files = append(files, file)
+	}
+
+	for _, path := range paths {
+		if path == Stdin {
+			add(Stdin)
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			// Missing files are passed through so they are reported alongside other results.
+			add(path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !manifestExtensions[strings.ToLower(filepath.Ext(file))] {
+				return nil
+			}
+
+			rel, err := filepath.Rel(path, file)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			if len(include) > 0 && !matchesAny(include, rel) {
+				return nil
+			}
+			if matchesAny(exclude, rel) {
+				return nil
+			}
+
+			add(file)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walking directory %s: %w", path, err)
+		}
+	}
+
+	return files, nil
+}
+
+func matchesAny(patterns []string, rel string) bool {
+	base := filepath.Base(rel)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the label used for a source in validation results.
+func Name(path string) string {
+	if path == Stdin {
+		return StdinName
+	}
+	return path
+}
+
+// Read returns the raw contents of a source, reading from stdin for "-".
+func Read(path string, stdin io.Reader) ([]byte, error) {
+	if path == Stdin {
+		if stdin == nil {
+			return nil, fmt.Errorf("reading stdin: no stdin available")
+		}
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("reading stdin: %w", err)
+		}
+		return data, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	return data, nil
+}
+
+// ReadResources reads a source and parses it into unstructured Kubernetes objects.
+// Files with a .json extension are parsed as JSON. Stdin is parsed as JSON when it
+// looks like JSON and as YAML otherwise.
+func ReadResources(path string, stdin io.Reader) ([]*unstructured.Unstructured, error) {
+	docs, err := ReadDocuments(path, stdin)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Resources(docs), nil
+}
+
+// ReadDocuments reads a source like ReadResources, keeping the positions of
+// the fields of each resource in the source.
+func ReadDocuments(path string, stdin io.Reader) ([]yaml.Document, error) {
+	data, err := Read(path, stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseDocuments(path, data)
+}
+
+// ParseDocuments parses the contents of a source into Kubernetes objects,
+// keeping the positions of the fields of each resource in the source.
+func ParseDocuments(path string, data []byte) ([]yaml.Document, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return yaml.ParseJSONToDocuments(data)
+	}
+
+	if path == Stdin && looksLikeJSON(data) {
+		if docs, err := yaml.ParseJSONToDocuments(data); err == nil {
+			return docs, nil
+		}
+	}
+
+	return yaml.ParseYAMLToDocuments(data)
+}
+
+func looksLikeJSON(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
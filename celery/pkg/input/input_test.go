@@ -0,0 +1,194 @@
+package input
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"deployment.yaml":          "kind: Deployment",
+		"service.yml":              "kind: Service",
+		"configmap.json":           `{"kind": "ConfigMap"}`,
+		"README.md":                "# docs",
+		"nested/secret.yaml":       "kind: Secret",
+		"nested/deep/ingress.yaml": "kind: Ingress",
+		"testdata/fixture.yaml":    "kind: Pod",
+	})
+
+	tests := []struct {
+		name    string
+		paths   []string
+		include []string
+		exclude []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "no paths reads stdin",
+			paths: nil,
+			want:  []string{Stdin},
+		},
+		{
+			name:  "dash reads stdin once",
+			paths: []string{Stdin, Stdin},
+			want:  []string{Stdin},
+		},
+		{
+			name:  "directory is walked recursively",
+			paths: []string{dir},
+			want: []string{
+				"configmap.json",
+				"deployment.yaml",
+				"nested/deep/ingress.yaml",
+				"nested/secret.yaml",
+				"service.yml",
+				"testdata/fixture.yaml",
+			},
+		},
+		{
+			name:    "include globs match base names",
+			paths:   []string{dir},
+			include: []string{"*.json"},
+			want:    []string{"configmap.json"},
+		},
+		{
+			name:    "exclude globs match relative paths",
+			paths:   []string{dir},
+			exclude: []string{"testdata/*", "nested/*/*"},
+			want: []string{
+				"configmap.json",
+				"deployment.yaml",
+				"nested/secret.yaml",
+				"service.yml",
+			},
+		},
+		{
+			name:    "explicit files bypass filters",
+			paths:   []string{filepath.Join(dir, "README.md")},
+			include: []string{"*.yaml"},
+			want:    []string{"README.md"},
+		},
+		{
+			name:    "invalid glob",
+			paths:   []string{dir},
+			include: []string{"[invalid"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand(tt.paths, tt.include, tt.exclude)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var rel []string
+			for _, file := range got {
+				if file == Stdin {
+					rel = append(rel, file)
+					continue
+				}
+				r, err := filepath.Rel(dir, file)
+				require.NoError(t, err)
+				rel = append(rel, filepath.ToSlash(r))
+			}
+			assert.Equal(t, tt.want, rel)
+		})
+	}
+}
+
+func TestReadResources(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"list.json": `[{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "a"}, "spec": {"ports": [{"port": 80}]}},
+{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "b"}}]`,
+		"deployment.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+	})
+
+	tests := []struct {
+		name      string
+		path      string
+		stdin     string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name:      "json array file",
+			path:      filepath.Join(dir, "list.json"),
+			wantNames: []string{"a", "b"},
+		},
+		{
+			name:      "yaml file",
+			path:      filepath.Join(dir, "deployment.yaml"),
+			wantNames: []string{"web"},
+		},
+		{
+			name:      "yaml stdin",
+			path:      Stdin,
+			stdin:     "kind: ConfigMap\nmetadata:\n  name: cm\n---\nkind: Secret\nmetadata:\n  name: s\n",
+			wantNames: []string{"cm", "s"},
+		},
+		{
+			name:      "json stdin stream",
+			path:      Stdin,
+			stdin:     `{"kind": "ConfigMap", "metadata": {"name": "one"}} {"kind": "ConfigMap", "metadata": {"name": "two"}}`,
+			wantNames: []string{"one", "two"},
+		},
+		{
+			name:    "missing file",
+			path:    filepath.Join(dir, "missing.yaml"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources, err := ReadResources(tt.path, strings.NewReader(tt.stdin))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var names []string
+			for _, r := range resources {
+				names = append(names, r.GetName())
+			}
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+}
+
+func TestReadResourcesJSONNumbers(t *testing.T) {
+	resources, err := ReadResources(Stdin, strings.NewReader(`{"kind": "Deployment", "spec": {"replicas": 3, "ratio": 0.5}}`))
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+
+	spec := resources[0].Object["spec"].(map[string]any)
+	assert.Equal(t, int64(3), spec["replicas"])
+	assert.Equal(t, 0.5, spec["ratio"])
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, StdinName, Name(Stdin))
+	assert.Equal(t, "deployment.yaml", Name("deployment.yaml"))
+}
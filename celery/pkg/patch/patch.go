@@ -0,0 +1,168 @@
+// Package patch applies JSON patches (RFC 6902) and JSON merge patches (RFC
+// 7386) to unstructured objects.
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+)
+
+// Operations lists every supported JSON patch operation.
+var Operations = []string{"add", "remove", "replace", "move", "copy", "test"}
+
+// Validate checks that every operation is known and has the pointers it needs.
+func Validate(ops []apiv1.PatchOperation) error {
+	var errs []error
+	for i, op := range ops {
+		if err := validate(op); err != nil {
+			errs = append(errs, fmt.Errorf("patch operation %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validate(op apiv1.PatchOperation) error {
+	if err := validatePointer(op.Path); err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	switch op.Op {
+	case "add", "remove", "replace", "test":
+		return nil
+	case "move", "copy":
+		if op.From == "" {
+			return errors.New("from is required")
+		}
+		if err := validatePointer(op.From); err != nil {
+			return fmt.Errorf("invalid from: %w", err)
+		}
+		if op.Op == "move" && op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+			return fmt.Errorf("cannot move %s into itself", op.From)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown op %q (must be one of %s)", op.Op, strings.Join(Operations, ", "))
+	}
+}
+
+func validatePointer(s string) error {
+	if s != "" && !strings.HasPrefix(s, "/") {
+		return fmt.Errorf("JSON pointer %q must start with /", s)
+	}
+	return nil
+}
+
+// Apply applies ops to a copy of obj in order, returning the copy. obj is not
+// changed, even when an operation fails.
+func Apply(obj map[string]any, ops []apiv1.PatchOperation) (map[string]any, error) {
+	doc, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("encoding object: %w", err)
+	}
+	for i, op := range ops {
+		operation := map[string]any{"op": op.Op, "path": op.Path, "value": op.Value}
+		if op.From != "" {
+			operation["from"] = op.From
+		}
+		raw, err := json.Marshal([]any{operation})
+		if err != nil {
+			return nil, fmt.Errorf("patch operation %d (%s %s): encoding value: %w", i, op.Op, op.Path, err)
+		}
+		decoded, err := jsonpatch.DecodePatch(raw)
+		if err != nil {
+			return nil, fmt.Errorf("patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+		if doc, err = decoded.Apply(doc); err != nil {
+			return nil, fmt.Errorf("patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return decodeObject(doc)
+}
+
+// Merge applies a JSON merge patch to a copy of obj and returns the copy:
+// objects in patch are merged into obj recursively, null values remove the
+// field, and any other value replaces it.
+func Merge(obj map[string]any, patch map[string]any) (map[string]any, error) {
+	doc, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("encoding object: %w", err)
+	}
+	raw, err := json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("encoding merge patch: %w", err)
+	}
+	merged, err := jsonpatch.MergePatch(doc, raw)
+	if err != nil {
+		return nil, fmt.Errorf("applying merge patch: %w", err)
+	}
+	return decodeObject(merged)
+}
+
+// decodeObject decodes a patched object, with whole numbers as int64 as in
+// unstructured objects.
+func decodeObject(doc []byte) (map[string]any, error) {
+	var obj map[string]any
+	if err := utiljson.Unmarshal(doc, &obj); err != nil {
+		return nil, fmt.Errorf("decoding patched object: %w", err)
+	}
+	if obj == nil {
+		return nil, errors.New("patch replaced the object with null")
+	}
+	return obj, nil
+}
+
+// Equal reports whether two unstructured values are equal, treating numbers
+// of different Go types as equal when their values are.
+func Equal(a, b any) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := b[k]
+			if !ok || !Equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !Equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+func number(value any) (float64, bool) {
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,216 @@
+package patch
+
+import (
+	"strconv"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deployment() map[string]any {
+	return map[string]any{
+		"metadata": map[string]any{
+			"name":   "web",
+			"labels": map[string]any{"tier": "frontend"},
+		},
+		"spec": map[string]any{
+			"replicas": 1,
+			"containers": []any{
+				map[string]any{"name": "web", "image": "web:v1"},
+				map[string]any{"name": "proxy", "image": "proxy:v1"},
+			},
+		},
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		ops     []apiv1.PatchOperation
+		path    []string
+		want    any
+		wantErr string
+	}{
+		{
+			name: "add field",
+			ops:  []apiv1.PatchOperation{{Op: "add", Path: "/metadata/labels/app", Value: "web"}},
+			path: []string{"metadata", "labels"},
+			want: map[string]any{"tier": "frontend", "app": "web"},
+		},
+		{
+			name: "add replaces an existing field",
+			ops:  []apiv1.PatchOperation{{Op: "add", Path: "/spec/replicas", Value: 3}},
+			path: []string{"spec", "replicas"},
+			want: int64(3),
+		},
+		{
+			name: "add inserts into a list",
+			ops:  []apiv1.PatchOperation{{Op: "add", Path: "/spec/containers/1", Value: map[string]any{"name": "init"}}},
+			path: []string{"spec", "containers"},
+			want: []any{
+				map[string]any{"name": "web", "image": "web:v1"},
+				map[string]any{"name": "init"},
+				map[string]any{"name": "proxy", "image": "proxy:v1"},
+			},
+		},
+		{
+			name: "add appends with -",
+			ops:  []apiv1.PatchOperation{{Op: "add", Path: "/spec/containers/-", Value: map[string]any{"name": "log"}}},
+			path: []string{"spec", "containers"},
+			want: []any{
+				map[string]any{"name": "web", "image": "web:v1"},
+				map[string]any{"name": "proxy", "image": "proxy:v1"},
+				map[string]any{"name": "log"},
+			},
+		},
+		{
+			name: "escaped pointer",
+			ops:  []apiv1.PatchOperation{{Op: "add", Path: "/metadata/labels/app.kubernetes.io~1name", Value: "web"}},
+			path: []string{"metadata", "labels", "app.kubernetes.io/name"},
+			want: "web",
+		},
+		{
+			name: "remove list item",
+			ops:  []apiv1.PatchOperation{{Op: "remove", Path: "/spec/containers/0"}},
+			path: []string{"spec", "containers"},
+			want: []any{map[string]any{"name": "proxy", "image": "proxy:v1"}},
+		},
+		{
+			name: "replace",
+			ops:  []apiv1.PatchOperation{{Op: "replace", Path: "/spec/containers/1/image", Value: "proxy:v2"}},
+			path: []string{"spec", "containers", "1", "image"},
+			want: "proxy:v2",
+		},
+		{
+			name: "move",
+			ops:  []apiv1.PatchOperation{{Op: "move", From: "/metadata/labels/tier", Path: "/metadata/labels/app"}},
+			path: []string{"metadata", "labels"},
+			want: map[string]any{"app": "frontend"},
+		},
+		{
+			name: "copy",
+			ops:  []apiv1.PatchOperation{{Op: "copy", From: "/metadata/name", Path: "/metadata/labels/app"}},
+			path: []string{"metadata", "labels", "app"},
+			want: "web",
+		},
+		{
+			name: "test compares numbers by value",
+			ops: []apiv1.PatchOperation{
+				{Op: "test", Path: "/spec/replicas", Value: int64(1)},
+				{Op: "replace", Path: "/spec/replicas", Value: 2},
+			},
+			path: []string{"spec", "replicas"},
+			want: int64(2),
+		},
+		{
+			name:    "failed test",
+			ops:     []apiv1.PatchOperation{{Op: "test", Path: "/spec/replicas", Value: 3}},
+			wantErr: "patch operation 0 (test /spec/replicas): testing value /spec/replicas failed: test failed",
+		},
+		{
+			name: "replace adds a missing field as kubectl patch does",
+			ops:  []apiv1.PatchOperation{{Op: "replace", Path: "/spec/paused", Value: true}},
+			path: []string{"spec", "paused"},
+			want: true,
+		},
+		{
+			name:    "add below missing field",
+			ops:     []apiv1.PatchOperation{{Op: "add", Path: "/spec/template/spec", Value: map[string]any{}}},
+			wantErr: `patch operation 0 (add /spec/template/spec): add operation does not apply: doc is missing path: "/spec/template/spec": missing value`,
+		},
+		{
+			name:    "list index out of range",
+			ops:     []apiv1.PatchOperation{{Op: "remove", Path: "/spec/containers/2"}},
+			wantErr: "patch operation 0 (remove /spec/containers/2): error in remove for path: '/spec/containers/2': Unable to access invalid index: 2: invalid index referenced",
+		},
+		{
+			name:    "later operation fails",
+			ops:     []apiv1.PatchOperation{{Op: "add", Path: "/spec/paused", Value: true}, {Op: "remove", Path: "/status"}},
+			wantErr: "patch operation 1 (remove /status): error in remove for path: '/status': Unable to remove nonexistent key: status: missing value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := deployment()
+			patched, err := Apply(obj, tt.ops)
+			assert.Equal(t, deployment(), obj, "the object should not change")
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var got any = patched
+			for _, key := range tt.path {
+				switch container := got.(type) {
+				case map[string]any:
+					got = container[key]
+				case []any:
+					index, err := strconv.Atoi(key)
+					require.NoError(t, err)
+					got = container[index]
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		ops     []apiv1.PatchOperation
+		wantErr string
+	}{
+		{name: "valid", ops: []apiv1.PatchOperation{{Op: "add", Path: "/a"}, {Op: "move", From: "/a", Path: "/b"}}},
+		{name: "unknown op", ops: []apiv1.PatchOperation{{Op: "merge", Path: "/a"}}, wantErr: `patch operation 0: unknown op "merge" (must be one of add, remove, replace, move, copy, test)`},
+		{name: "relative path", ops: []apiv1.PatchOperation{{Op: "add", Path: "a"}}, wantErr: `patch operation 0: invalid path: JSON pointer "a" must start with /`},
+		{name: "missing from", ops: []apiv1.PatchOperation{{Op: "add", Path: "/a"}, {Op: "copy", Path: "/b"}}, wantErr: "patch operation 1: from is required"},
+		{name: "move into itself", ops: []apiv1.PatchOperation{{Op: "move", From: "/a", Path: "/a/b"}}, wantErr: "patch operation 0: cannot move /a into itself"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.ops)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	obj := deployment()
+	merged, err := Merge(obj, map[string]any{
+		"metadata": map[string]any{
+			"labels":      map[string]any{"app": "web", "tier": nil},
+			"annotations": map[string]any{"owner": "shop"},
+		},
+		"spec": map[string]any{"containers": []any{map[string]any{"name": "web"}}},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, deployment(), obj, "the object should not change")
+	assert.Equal(t, map[string]any{
+		"metadata": map[string]any{
+			"name":        "web",
+			"labels":      map[string]any{"app": "web"},
+			"annotations": map[string]any{"owner": "shop"},
+		},
+		"spec": map[string]any{
+			"replicas":   int64(1),
+			"containers": []any{map[string]any{"name": "web"}},
+		},
+	}, merged)
+}
+
+func TestEqual(t *testing.T) {
+	assert.True(t, Equal(map[string]any{"a": []any{1, "b"}}, map[string]any{"a": []any{int64(1), "b"}}))
+	assert.True(t, Equal(2, 2.0))
+	assert.False(t, Equal(map[string]any{"a": 1}, map[string]any{"a": 1, "b": 2}))
+	assert.False(t, Equal("1", 1))
+	assert.True(t, Equal(nil, nil))
+}
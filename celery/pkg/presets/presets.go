@@ -0,0 +1,91 @@
+// Package presets embeds curated ValidationRules packs in the binary. A preset
+// is referred to as preset:NAME wherever a rule file path is accepted, so
+// presets load, include and override like any other rule file.
+package presets
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+)
+
+// Prefix marks a rule file path as the name of a preset.
+const Prefix = "preset:"
+
+// DescriptionAnnotation is the annotation of a preset's ValidationRules that
+// describes it.
+const DescriptionAnnotation = "celery.rrethy.io/description"
+
+//go:embed packs/*.yaml
+var packs embed.FS
+
+// Preset is an embedded ValidationRules pack.
+type Preset struct {
+	Name        string
+	Description string
+}
+
+// Path returns the rule file path of the preset name.
+func Path(name string) string {
+	return Prefix + name
+}
+
+// Paths returns the rule file paths of the presets names.
+func Paths(names []string) []string {
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, Path(name))
+	}
+	return paths
+}
+
+// Name returns the preset name of a rule file path, and whether the path
+// refers to a preset.
+func Name(ruleFile string) (string, bool) {
+	return strings.CutPrefix(ruleFile, Prefix)
+}
+
+// Read returns the YAML of the preset name.
+func Read(name string) ([]byte, error) {
+	var data []byte
+	err := fs.ErrNotExist
+	if !strings.Contains(name, "/") {
+		data, err = packs.ReadFile(path.Join("packs", name+".yaml"))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("unknown preset %q, available presets are %s", name, strings.Join(names(), ", "))
+	}
+	return data, err
+}
+
+// List returns every preset sorted by name.
+func List() ([]Preset, error) {
+	var presets []Preset
+	for _, name := range names() {
+		data, err := Read(name)
+		if err != nil {
+			return nil, err
+		}
+		ruless, err := yaml.ParseYAMLToValidationRules(data, Path(name))
+		if err != nil {
+			return nil, fmt.Errorf("loading preset %s: %w", name, err)
+		}
+		presets = append(presets, Preset{Name: name, Description: ruless[0].Annotations[DescriptionAnnotation]})
+	}
+	return presets, nil
+}
+
+// names returns the names of every preset, sorted.
+func names() []string {
+	entries, _ := packs.ReadDir("packs")
+	var names []string
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+	return names
+}
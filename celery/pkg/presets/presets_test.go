@@ -0,0 +1,134 @@
+package presets
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/RRethy/kube-tools/celery/pkg/rulelint"
+	"github.com/RRethy/kube-tools/celery/pkg/schemas"
+	"github.com/RRethy/kube-tools/celery/pkg/validator"
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList(t *testing.T) {
+	presets, err := List()
+	require.NoError(t, err)
+
+	var names []string
+	for _, preset := range presets {
+		names = append(names, preset.Name)
+		assert.NotEmpty(t, preset.Description, "preset %s should have a description", preset.Name)
+	}
+	assert.Equal(t, []string{"best-practices", "pod-security-baseline", "restricted"}, names)
+}
+
+func TestRead(t *testing.T) {
+	data, err := Read("restricted")
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "name: restricted")
+
+	for _, name := range []string{"missing", "../presets", "packs/restricted"} {
+		_, err = Read(name)
+		assert.EqualError(t, err, `unknown preset "`+name+`", available presets are best-practices, pod-security-baseline, restricted`)
+	}
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, []string{"preset:restricted", "preset:best-practices"}, Paths([]string{"restricted", "best-practices"}))
+
+	name, ok := Name("preset:restricted")
+	assert.True(t, ok)
+	assert.Equal(t, "restricted", name)
+
+	_, ok = Name("rules/restricted.yaml")
+	assert.False(t, ok)
+}
+
+// TestPresets evaluates the rules of each preset, without its includes,
+// against workloads that pass and fail them.
+func TestPresets(t *testing.T) {
+	inputFiles := []string{filepath.Join("..", "..", "fixtures", "presets", "workloads.yaml")}
+	resolver, err := schemas.NewResolver()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		failed []string
+	}{
+		{
+			name: "pod-security-baseline",
+			failed: []string{
+				"baseline-capabilities Pod/debug",
+				"baseline-host-namespaces Deployment/legacy",
+				"baseline-host-path-volumes Deployment/legacy",
+				"baseline-host-ports Pod/debug",
+				"baseline-privileged-containers Deployment/legacy",
+			},
+		},
+		{
+			name: "restricted",
+			failed: []string{
+				"restricted-capabilities Deployment/legacy",
+				"restricted-capabilities Pod/debug",
+				"restricted-privilege-escalation Deployment/legacy",
+				"restricted-run-as-non-root Deployment/legacy",
+				"restricted-seccomp-profile Deployment/legacy",
+				"restricted-seccomp-profile Pod/debug",
+				"restricted-volume-types Deployment/legacy",
+			},
+		},
+		{
+			name: "best-practices",
+			failed: []string{
+				"container-resource-limits Deployment/legacy",
+				"health-probes Deployment/legacy",
+				"pinned-image-tags CronJob/report",
+				"pinned-image-tags Deployment/legacy",
+				"pod-disruption-budget Deployment/legacy",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Read(tt.name)
+			require.NoError(t, err)
+			ruless, err := yaml.ParseYAMLToValidationRules(data, Path(tt.name))
+			require.NoError(t, err)
+
+			v := &validator.Validator{Schemas: resolver}
+			rules, err := v.CompileRules(ruless)
+			require.NoError(t, err, "preset rules should compile and type check")
+
+			var failed []string
+			for result := range v.Stream(context.Background(), inputFiles, rules) {
+				if !result.Valid {
+					failed = append(failed, result.RuleName+" "+result.ResourceKind+"/"+result.ResourceName)
+				}
+			}
+			sort.Strings(failed)
+			assert.Equal(t, tt.failed, failed)
+		})
+	}
+}
+
+func TestPresetsLint(t *testing.T) {
+	resolver, err := schemas.NewResolver()
+	require.NoError(t, err)
+
+	var sources []rulelint.Source
+	for _, name := range names() {
+		data, err := Read(name)
+		require.NoError(t, err)
+		sources = append(sources, rulelint.Source{Filename: Path(name), Data: data})
+	}
+
+	linter := &rulelint.Linter{Schemas: resolver}
+	diagnostics, err := linter.Lint(sources)
+	require.NoError(t, err)
+	assert.Empty(t, diagnostics)
+}
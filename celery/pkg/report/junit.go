@@ -0,0 +1,94 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes one test suite per input file and one test case per result.
+// Failures of every severity are reported, with the severity as the failure type,
+// and skipped rules and baselined failures are reported as skipped test cases.
+func writeJUnit(w io.Writer, r Report) error {
+	suites := junitTestSuites{Name: toolName}
+	suiteIndex := make(map[string]int)
+
+	for _, result := range r.Results {
+		idx, ok := suiteIndex[result.InputFile]
+		if !ok {
+			idx = len(suites.Suites)
+			suiteIndex[result.InputFile] = idx
+			suites.Suites = append(suites.Suites, junitTestSuite{Name: result.InputFile})
+		}
+		suite := &suites.Suites[idx]
+
+		tc := junitTestCase{
+			Name:      junitCaseName(result),
+			ClassName: result.RuleFile,
+		}
+		if result.Skipped || result.Baselined {
+			message := result.Message
+			if result.Baselined {
+				message = "baselined: " + message
+			}
+			tc.Skipped = &junitSkipped{Message: message}
+			suite.Skipped++
+			suites.Skipped++
+		} else if !result.Valid {
+			tc.Failure = &junitFailure{
+				Message: result.Message,
+				Type:    result.Severity,
+				Text:    fmt.Sprintf("%s\nrule file: %s\ninput file: %s", result.Message, result.RuleFile, result.location()),
+			}
+			suite.Failures++
+			suites.Failures++
+		}
+		suite.Tests++
+		suites.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	return writeXML(w, suites)
+}
+
+func junitCaseName(r Result) string {
+	name := fmt.Sprintf("[%s]", r.RuleName)
+	if ref := r.resourceRef(); ref != "" {
+		name += " " + ref
+	}
+	return name
+}
@@ -0,0 +1,236 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/validator"
+	goyaml "gopkg.in/yaml.v3"
+)
+
+// Format is a serialisation format for validation results.
+type Format string
+
+const (
+	FormatText  Format = "text"
+	FormatJSON  Format = "json"
+	FormatYAML  Format = "yaml"
+	FormatSARIF Format = "sarif"
+	FormatJUnit Format = "junit"
+)
+
+// Formats lists every supported output format.
+var Formats = []Format{FormatText, FormatJSON, FormatYAML, FormatSARIF, FormatJUnit}
+
+// ParseFormat converts a flag value into a Format. An empty value selects text.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return FormatText, nil
+	}
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %q (must be one of text, json, yaml, sarif, junit)", s)
+}
+
+// Result is the serialisable form of a validator.ValidationResult.
+type Result struct {
+	InputFile string `json:"inputFile" yaml:"inputFile"`
+	// Line and Column are where in InputFile the result points, when known.
+	Line         int    `json:"line,omitempty" yaml:"line,omitempty"`
+	Column       int    `json:"column,omitempty" yaml:"column,omitempty"`
+	RuleFile     string `json:"ruleFile,omitempty" yaml:"ruleFile,omitempty"`
+	RuleName     string `json:"ruleName,omitempty" yaml:"ruleName,omitempty"`
+	ResourceKind string `json:"resourceKind,omitempty" yaml:"resourceKind,omitempty"`
+	ResourceName string `json:"resourceName,omitempty" yaml:"resourceName,omitempty"`
+	Severity     string `json:"severity" yaml:"severity"`
+	Valid        bool   `json:"valid" yaml:"valid"`
+	// Skipped results were not evaluated; Message holds the skip reason.
+	Skipped bool `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	// Baselined failures are recorded in the baseline and do not fail the run.
+	Baselined bool `json:"baselined,omitempty" yaml:"baselined,omitempty"`
+	// LimitExceeded failures were stopped by a cost limit, the cost budget or
+	// the timeout rather than failing the rule.
+	LimitExceeded bool   `json:"limitExceeded,omitempty" yaml:"limitExceeded,omitempty"`
+	Message       string `json:"message,omitempty" yaml:"message,omitempty"`
+	// Explanation traces why a failing rule was false, when failures are explained.
+	Explanation *validator.Explanation `json:"explanation,omitempty" yaml:"explanation,omitempty"`
+}
+
+// Summary counts the results of a validation run. Failed counts every failing
+// result not in the baseline; Errors, Warnings and Info break those failures
+// down by severity, and LimitExceeded counts those stopped by a limit. Skipped
+// and Baselined results are counted separately.
+type Summary struct {
+	Total         int `json:"total" yaml:"total"`
+	Passed        int `json:"passed" yaml:"passed"`
+	Skipped       int `json:"skipped" yaml:"skipped"`
+	Baselined     int `json:"baselined" yaml:"baselined"`
+	Failed        int `json:"failed" yaml:"failed"`
+	Errors        int `json:"errors" yaml:"errors"`
+	Warnings      int `json:"warnings" yaml:"warnings"`
+	Info          int `json:"info" yaml:"info"`
+	LimitExceeded int `json:"limitExceeded" yaml:"limitExceeded"`
+}
+
+// Report is the document written for the json and yaml formats.
+type Report struct {
+	Summary Summary  `json:"summary" yaml:"summary"`
+	Results []Result `json:"results" yaml:"results"`
+}
+
+// NewReport converts validation results into a Report, sorted by input file,
+// rule file, rule name, and resource.
+func NewReport(results []validator.ValidationResult) Report {
+	r := Report{Results: make([]Result, 0, len(results))}
+	for _, result := range results {
+		severity := result.Severity
+		if severity == "" {
+			severity = apiv1.SeverityError
+		}
+
+		res := Result{
+			InputFile:     result.InputFile,
+			Line:          result.Line,
+			Column:        result.Column,
+			RuleFile:      result.RuleFile,
+			RuleName:      result.RuleName,
+			ResourceKind:  result.ResourceKind,
+			ResourceName:  result.ResourceName,
+			Severity:      string(severity),
+			Valid:         result.Valid,
+			Skipped:       result.Skipped,
+			Baselined:     result.Baselined,
+			LimitExceeded: result.LimitExceeded,
+			Explanation:   result.Explanation,
+		}
+		if result.Err != nil {
+			res.Message = result.Err.Error()
+		}
+		if result.Skipped {
+			res.Message = result.SkipReason
+		}
+		r.Results = append(r.Results, res)
+
+		r.Summary.Total++
+		if result.Skipped {
+			r.Summary.Skipped++
+			continue
+		}
+		if result.Valid {
+			r.Summary.Passed++
+			continue
+		}
+		if result.Baselined {
+			r.Summary.Baselined++
+			continue
+		}
+		r.Summary.Failed++
+		if result.LimitExceeded {
+			r.Summary.LimitExceeded++
+		}
+		switch severity {
+		case apiv1.SeverityWarning:
+			r.Summary.Warnings++
+		case apiv1.SeverityInfo:
+			r.Summary.Info++
+		default:
+			r.Summary.Errors++
+		}
+	}
+
+	sort.SliceStable(r.Results, func(i, j int) bool {
+		a, b := r.Results[i], r.Results[j]
+		if a.InputFile != b.InputFile {
+			return a.InputFile < b.InputFile
+		}
+		if a.RuleFile != b.RuleFile {
+			return a.RuleFile < b.RuleFile
+		}
+		if a.RuleName != b.RuleName {
+			return a.RuleName < b.RuleName
+		}
+		if a.ResourceKind != b.ResourceKind {
+			return a.ResourceKind < b.ResourceKind
+		}
+		if a.ResourceName != b.ResourceName {
+			return a.ResourceName < b.ResourceName
+		}
+		return a.Line < b.Line
+	})
+
+	return r
+}
+
+// Write serialises results to w in the given machine-readable format.
+func Write(w io.Writer, format Format, results []validator.ValidationResult) error {
+	r := NewReport(results)
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, r)
+	case FormatYAML:
+		return writeYAML(w, r)
+	case FormatSARIF:
+		return writeSARIF(w, r)
+	case FormatJUnit:
+		return writeJUnit(w, r)
+	default:
+		return fmt.Errorf("format %q is not a machine-readable format", format)
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("encoding JSON: %w", err)
+	}
+	return nil
+}
+
+func writeYAML(w io.Writer, r Report) error {
+	encoder := goyaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("encoding YAML: %w", err)
+	}
+	return encoder.Close()
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("writing XML: %w", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("encoding XML: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("writing XML: %w", err)
+	}
+	return nil
+}
+
+// location formats where a result points as file:line:column, or just the
+// file when the line is unknown.
+func (r Result) location() string {
+	if r.Line == 0 {
+		return r.InputFile
+	}
+	return fmt.Sprintf("%s:%d:%d", r.InputFile, r.Line, r.Column)
+}
+
+// resourceRef formats the resource a result refers to as Kind/name.
+func (r Result) resourceRef() string {
+	if r.ResourceKind == "" && r.ResourceName == "" {
+		return ""
+	}
+	return r.ResourceKind + "/" + r.ResourceName
+}
@@ -0,0 +1,261 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	goyaml "gopkg.in/yaml.v3"
+)
+
+var testResults = []validator.ValidationResult{
+	{
+		InputFile:    "b.yaml",
+		RuleFile:     "rules.yaml",
+		RuleName:     "minimum-replicas",
+		ResourceKind: "Deployment",
+		ResourceName: "web",
+		Valid:        false,
+		Err:          errors.New("Deployments must have at least 3 replicas"),
+		Line:         12,
+		Column:       3,
+	},
+	{
+		InputFile:    "a.yaml",
+		RuleFile:     "rules.yaml",
+		RuleName:     "required-labels",
+		ResourceKind: "Service",
+		ResourceName: "web",
+		Valid:        true,
+	},
+	{
+		InputFile:    "b.yaml",
+		RuleFile:     "rules.yaml",
+		RuleName:     "pdb-exists",
+		ResourceKind: "Deployment",
+		ResourceName: "web",
+		Severity:     apiv1.SeverityWarning,
+		Valid:        false,
+		Err:          errors.New("Deployments should have a PodDisruptionBudget"),
+	},
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Format
+		wantErr bool
+	}{
+		{input: "", want: FormatText},
+		{input: "text", want: FormatText},
+		{input: "json", want: FormatJSON},
+		{input: "yaml", want: FormatYAML},
+		{input: "sarif", want: FormatSARIF},
+		{input: "junit", want: FormatJUnit},
+		{input: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseFormat(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	r := NewReport(testResults)
+
+	assert.Equal(t, Summary{Total: 3, Passed: 1, Failed: 2, Errors: 1, Warnings: 1}, r.Summary)
+	require.Len(t, r.Results, 3)
+	assert.Equal(t, "a.yaml", r.Results[0].InputFile, "results should be sorted by input file")
+	assert.Equal(t, "Deployments must have at least 3 replicas", r.Results[1].Message)
+	assert.Equal(t, "error", r.Results[1].Severity, "empty severity defaults to error")
+	assert.False(t, r.Results[1].Valid)
+	assert.Equal(t, "warning", r.Results[2].Severity)
+	assert.Equal(t, 12, r.Results[1].Line)
+	assert.Equal(t, 3, r.Results[1].Column)
+	assert.Equal(t, "b.yaml:12:3", r.Results[1].location())
+	assert.Equal(t, "b.yaml", r.Results[2].location(), "unknown lines are left out")
+}
+
+func TestWriteJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.NoError(t, Write(out, FormatJSON, testResults))
+
+	var got Report
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+	assert.Equal(t, NewReport(testResults), got)
+}
+
+func TestWriteYAML(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.NoError(t, Write(out, FormatYAML, testResults))
+
+	var got Report
+	require.NoError(t, goyaml.Unmarshal(out.Bytes(), &got))
+	assert.Equal(t, NewReport(testResults), got)
+}
+
+func TestWriteSARIF(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.NoError(t, Write(out, FormatSARIF, testResults))
+
+	var got sarifLog
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+	assert.Equal(t, "2.1.0", got.Version)
+	require.Len(t, got.Runs, 1)
+
+	run := got.Runs[0]
+	assert.Equal(t, "celery", run.Tool.Driver.Name)
+	assert.Len(t, run.Tool.Driver.Rules, 3)
+	require.Len(t, run.Results, 3)
+
+	assert.Equal(t, "pass", run.Results[0].Kind)
+	assert.Equal(t, "none", run.Results[0].Level)
+
+	failed := run.Results[1]
+	assert.Equal(t, "minimum-replicas", failed.RuleID)
+	assert.Equal(t, "fail", failed.Kind)
+	assert.Equal(t, "error", failed.Level)
+	assert.Equal(t, "Deployment/web: Deployments must have at least 3 replicas", failed.Message.Text)
+	assert.Equal(t, "b.yaml", failed.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, &sarifRegion{StartLine: 12, StartColumn: 3}, failed.Locations[0].PhysicalLocation.Region)
+	assert.Nil(t, run.Results[2].Locations[0].PhysicalLocation.Region, "results without a line have no region")
+
+	assert.Equal(t, "warning", run.Results[2].Level)
+}
+
+func TestWriteJUnit(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.NoError(t, Write(out, FormatJUnit, testResults))
+
+	var got junitTestSuites
+	require.NoError(t, xml.Unmarshal(out.Bytes(), &got))
+	assert.Equal(t, 3, got.Tests)
+	assert.Equal(t, 2, got.Failures)
+	require.Len(t, got.Suites, 2)
+
+	assert.Equal(t, "a.yaml", got.Suites[0].Name)
+	assert.Nil(t, got.Suites[0].TestCases[0].Failure)
+
+	failing := got.Suites[1].TestCases[0]
+	assert.Equal(t, "[minimum-replicas] Deployment/web", failing.Name)
+	assert.Equal(t, "rules.yaml", failing.ClassName)
+	require.NotNil(t, failing.Failure)
+	assert.Equal(t, "Deployments must have at least 3 replicas", failing.Failure.Message)
+	assert.Equal(t, "error", failing.Failure.Type)
+	assert.Contains(t, failing.Failure.Text, "input file: b.yaml:12:3")
+	assert.Equal(t, "warning", got.Suites[1].TestCases[1].Failure.Type)
+}
+
+func TestWriteText(t *testing.T) {
+	err := Write(&bytes.Buffer{}, FormatText, testResults)
+	assert.Error(t, err)
+}
+
+func TestSkippedResults(t *testing.T) {
+	results := append([]validator.ValidationResult{{
+		InputFile:    "c.yaml",
+		RuleFile:     "rules.yaml",
+		RuleName:     "minimum-replicas",
+		ResourceKind: "Deployment",
+		ResourceName: "singleton",
+		Valid:        true,
+		Skipped:      true,
+		SkipReason:   "leader election only supports one replica",
+	}}, testResults...)
+
+	r := NewReport(results)
+	assert.Equal(t, Summary{Total: 4, Passed: 1, Skipped: 1, Failed: 2, Errors: 1, Warnings: 1}, r.Summary)
+	skipped := r.Results[3]
+	assert.True(t, skipped.Skipped)
+	assert.Equal(t, "leader election only supports one replica", skipped.Message)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, Write(out, FormatSARIF, results))
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(out.Bytes(), &log))
+	sr := log.Runs[0].Results[3]
+	assert.Equal(t, "notApplicable", sr.Kind)
+	assert.Equal(t, "none", sr.Level)
+	assert.Equal(t, []sarifSuppression{{Kind: "inSource", Justification: "leader election only supports one replica"}}, sr.Suppressions)
+	assert.Equal(t, "Deployment/singleton: skipped: leader election only supports one replica", sr.Message.Text)
+
+	out.Reset()
+	require.NoError(t, Write(out, FormatJUnit, results))
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(out.Bytes(), &suites))
+	assert.Equal(t, 1, suites.Skipped)
+	assert.Equal(t, 2, suites.Failures)
+	tc := suites.Suites[2].TestCases[0]
+	assert.Nil(t, tc.Failure)
+	require.NotNil(t, tc.Skipped)
+	assert.Equal(t, "leader election only supports one replica", tc.Skipped.Message)
+}
+
+func TestBaselinedResults(t *testing.T) {
+	results := append([]validator.ValidationResult{{
+		InputFile:    "c.yaml",
+		RuleFile:     "rules.yaml",
+		RuleName:     "minimum-replicas",
+		ResourceKind: "Deployment",
+		ResourceName: "legacy",
+		Baselined:    true,
+		Err:          errors.New("Deployments must have at least 3 replicas"),
+	}}, testResults...)
+
+	r := NewReport(results)
+	assert.Equal(t, Summary{Total: 4, Passed: 1, Baselined: 1, Failed: 2, Errors: 1, Warnings: 1}, r.Summary)
+	assert.True(t, r.Results[3].Baselined)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, Write(out, FormatSARIF, results))
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(out.Bytes(), &log))
+	sr := log.Runs[0].Results[3]
+	assert.Equal(t, "fail", sr.Kind)
+	assert.Equal(t, []sarifSuppression{{Kind: "external", Justification: "recorded in baseline"}}, sr.Suppressions)
+
+	out.Reset()
+	require.NoError(t, Write(out, FormatJUnit, results))
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(out.Bytes(), &suites))
+	assert.Equal(t, 2, suites.Failures)
+	require.NotNil(t, suites.Suites[2].TestCases[0].Skipped)
+	assert.Equal(t, "baselined: Deployments must have at least 3 replicas", suites.Suites[2].TestCases[0].Skipped.Message)
+}
+
+func TestLimitExceededResults(t *testing.T) {
+	results := append([]validator.ValidationResult{{
+		InputFile:     "c.yaml",
+		RuleFile:      "rules.yaml",
+		RuleName:      "unique-names",
+		ResourceKind:  "Deployment",
+		ResourceName:  "web",
+		LimitExceeded: true,
+		Err:           fmt.Errorf("%w: cost of rule exceeds the limit of 1000", validator.ErrLimitExceeded),
+	}}, testResults...)
+
+	r := NewReport(results)
+	assert.Equal(t, Summary{Total: 4, Passed: 1, Failed: 3, Errors: 2, Warnings: 1, LimitExceeded: 1}, r.Summary)
+	assert.True(t, r.Results[3].LimitExceeded)
+	assert.False(t, r.Results[3].Valid)
+	assert.Equal(t, "limit exceeded: cost of rule exceeds the limit of 1000", r.Results[3].Message)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, Write(out, FormatJSON, results))
+	assert.Contains(t, out.String(), `"limitExceeded": true`)
+}
@@ -0,0 +1,186 @@
+package report
+
+import (
+	"io"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolName     = "celery"
+	toolURI      = "https://github.com/RRethy/kube-tools/tree/main/celery"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription sarifMessage  `json:"shortDescription"`
+	Properties       sarifRuleProp `json:"properties"`
+}
+
+type sarifRuleProp struct {
+	RuleFile string `json:"ruleFile,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID       string             `json:"ruleId,omitempty"`
+	Kind         string             `json:"kind"`
+	Level        string             `json:"level"`
+	Message      sarifMessage       `json:"message"`
+	Locations    []sarifLocation    `json:"locations"`
+	Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+	Properties   sarifResultProp    `json:"properties"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+type sarifResultProp struct {
+	RuleFile     string `json:"ruleFile,omitempty"`
+	ResourceKind string `json:"resourceKind,omitempty"`
+	ResourceName string `json:"resourceName,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+func writeSARIF(w io.Writer, r Report) error {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           toolName,
+				InformationURI: toolURI,
+				Rules:          []sarifRule{},
+			},
+		},
+		Results: make([]sarifResult, 0, len(r.Results)),
+	}
+
+	seenRules := make(map[string]bool)
+	for _, result := range r.Results {
+		if result.RuleName != "" && !seenRules[result.RuleName] {
+			seenRules[result.RuleName] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               result.RuleName,
+				ShortDescription: sarifMessage{Text: result.RuleName},
+				Properties:       sarifRuleProp{RuleFile: result.RuleFile},
+			})
+		}
+
+		sr := sarifResult{
+			RuleID:  result.RuleName,
+			Kind:    "fail",
+			Level:   sarifLevel(result.Severity),
+			Message: sarifMessage{Text: sarifText(result)},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: result.InputFile},
+					Region:           sarifRegionOf(result),
+				},
+			}},
+			Properties: sarifResultProp{
+				RuleFile:     result.RuleFile,
+				ResourceKind: result.ResourceKind,
+				ResourceName: result.ResourceName,
+			},
+		}
+		switch {
+		case result.Skipped:
+			// Skipped rules were never evaluated, so the result is reported as
+			// not applicable and suppressed in source by the skip annotation.
+			sr.Kind = "notApplicable"
+			sr.Level = "none"
+			sr.Suppressions = []sarifSuppression{{Kind: "inSource", Justification: result.Message}}
+		case result.Valid:
+			sr.Kind = "pass"
+			sr.Level = "none"
+		case result.Baselined:
+			sr.Suppressions = []sarifSuppression{{Kind: "external", Justification: "recorded in baseline"}}
+		}
+		run.Results = append(run.Results, sr)
+	}
+
+	return writeJSON(w, sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifRegionOf returns the region a result points at, or nil when its line
+// is unknown.
+func sarifRegionOf(r Result) *sarifRegion {
+	if r.Line == 0 {
+		return nil
+	}
+	return &sarifRegion{StartLine: r.Line, StartColumn: r.Column}
+}
+
+// sarifLevel maps a rule severity onto a SARIF result level.
+func sarifLevel(severity string) string {
+	switch apiv1.Severity(severity) {
+	case apiv1.SeverityWarning:
+		return "warning"
+	case apiv1.SeverityInfo:
+		return "note"
+	default:
+		return "error"
+	}
+}
+
+func sarifText(r Result) string {
+	text := r.Message
+	switch {
+	case r.Skipped:
+		text = "skipped: " + text
+	case text == "":
+		text = "passed"
+	}
+	if ref := r.resourceRef(); ref != "" {
+		text = ref + ": " + text
+	}
+	return text
+}
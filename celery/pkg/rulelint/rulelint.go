@@ -0,0 +1,431 @@
+package rulelint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/schemas"
+	"github.com/RRethy/kube-tools/celery/pkg/validator"
+	"github.com/google/cel-go/cel"
+	goyaml "gopkg.in/yaml.v3"
+)
+
+// Diagnostic is a problem found in a rule file.
+type Diagnostic struct {
+	Filename string
+	// Line and Column are where in Filename the problem is, starting at 1.
+	Line   int
+	Column int
+	// Rule is the name of the rule the problem is in, empty for problems
+	// outside of a rule.
+	Rule string
+	// Severity is error for rules that fail to load or can never fail, and
+	// warning for rules that may be meant, such as ones that always fail or
+	// target unknown kinds, and for files without ValidationRules that do not
+	// decode.
+	Severity apiv1.Severity
+	Message  string
+}
+
+// String formats d as file:line:column: severity: [rule] message.
+func (d Diagnostic) String() string {
+	rule := ""
+	if d.Rule != "" {
+		rule = "[" + d.Rule + "] "
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s%s", d.Filename, d.Line, d.Column, d.Severity, rule, d.Message)
+}
+
+// Source is the contents of a rule file.
+type Source struct {
+	Filename string
+	Data     []byte
+}
+
+// Linter checks rule files for rules that cannot load, can never fail, or
+// are otherwise unlikely to do what their author meant.
+type Linter struct {
+	// Schemas are the kinds targets are checked against. Targets are not
+	// checked when it is nil.
+	Schemas *schemas.Resolver
+
+	// names are where each rule name was first seen, to report duplicates
+	// across sources.
+	names map[string]location
+}
+
+// validationRulesKind matches the kind of a ValidationRules document.
+var validationRulesKind = regexp.MustCompile(`(?m)^kind:\s*["']?` + apiv1.ValidationRulesKind + `["']?\s*$`)
+
+// location is a position in a source.
+type location struct {
+	filename     string
+	line, column int
+}
+
+// Lint checks the ValidationRules in sources, which are YAML documents of any
+// kind. Documents of other kinds are skipped. Diagnostics are sorted by file
+// and position. It returns an error when no source contains ValidationRules.
+func (l *Linter) Lint(sources []Source) ([]Diagnostic, error) {
+	l.names = make(map[string]location)
+
+	var diagnostics []Diagnostic
+	found := false
+	for _, src := range sources {
+		decoder := goyaml.NewDecoder(bytes.NewReader(src.Data))
+		for {
+			var doc goyaml.Node
+			err := decoder.Decode(&doc)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				// Files that do not decode, such as Helm templates, are only
+				// an error when they look like they hold ValidationRules.
+				severity := apiv1.SeverityWarning
+				if validationRulesKind.Match(src.Data) {
+					severity = apiv1.SeverityError
+				}
+				diagnostics = append(diagnostics, Diagnostic{
+					Filename: src.Filename,
+					Line:     1,
+					Column:   1,
+					Severity: severity,
+					Message:  fmt.Sprintf("decoding YAML: %v", err),
+				})
+				break
+			}
+			if len(doc.Content) == 0 || scalar(field(doc.Content[0], "kind")) != apiv1.ValidationRulesKind {
+				continue
+			}
+			found = true
+			diagnostics = append(diagnostics, l.lintDocument(src.Filename, doc.Content[0])...)
+		}
+	}
+	if !found {
+		return nil, errors.New("no ValidationRules resources found")
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diagnostics, nil
+}
+
+// document collects the diagnostics of one ValidationRules document.
+type document struct {
+	filename    string
+	diagnostics []Diagnostic
+}
+
+func (d *document) report(node *goyaml.Node, rule string, severity apiv1.Severity, format string, args ...any) {
+	d.diagnostics = append(d.diagnostics, Diagnostic{
+		Filename: d.filename,
+		Line:     node.Line,
+		Column:   node.Column,
+		Rule:     rule,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *Linter) lintDocument(filename string, node *goyaml.Node) []Diagnostic {
+	d := &document{filename: filename}
+
+	var rules apiv1.ValidationRules
+	if err := node.Decode(&rules); err != nil {
+		d.report(node, "", apiv1.SeverityError, "decoding ValidationRules: %v", err)
+		return d.diagnostics
+	}
+	rules.Filename = filename
+
+	d.unknownFields(node)
+
+	spec := field(node, "spec")
+	env, err := validator.NewEnv(rules)
+	if err != nil {
+		d.report(key(node, "spec", "variables"), "", apiv1.SeverityError, "%v", err)
+	}
+
+	rulesNode := field(spec, "rules")
+	for i, rule := range rules.Spec.Rules {
+		ruleNode := item(rulesNode, i)
+		l.lintName(d, ruleNode, rule)
+		if env != nil {
+			lintExpression(d, ruleNode, rule, env)
+		}
+		l.lintSelectors(d, ruleNode, rule)
+
+		if _, err := apiv1.ParseSeverity(string(rule.Severity)); err != nil {
+			d.report(key(ruleNode, "severity"), rule.Name, apiv1.SeverityError, "%v", err)
+		}
+		if _, err := apiv1.ParseScope(string(rule.Scope)); err != nil {
+			d.report(key(ruleNode, "scope"), rule.Name, apiv1.SeverityError, "%v", err)
+		}
+	}
+	return d.diagnostics
+}
+
+// lintName reports rules without a name, and rules with the name of a rule
+// seen before in any source, since results and skips could not tell them apart.
+func (l *Linter) lintName(d *document, ruleNode *goyaml.Node, rule apiv1.ValidationRule) {
+	if rule.Name == "" {
+		d.report(ruleNode, "", apiv1.SeverityError, "rule has no name")
+		return
+	}
+
+	nameNode := key(ruleNode, "name")
+	if first, ok := l.names[rule.Name]; ok {
+		d.report(nameNode, rule.Name, apiv1.SeverityError, "duplicate rule name, first defined at %s:%d:%d", first.filename, first.line, first.column)
+		return
+	}
+	l.names[rule.Name] = location{filename: d.filename, line: nameNode.Line, column: nameNode.Column}
+}
+
+// lintExpression reports expressions that do not compile, do not return a
+// bool, or do not depend on the resource and so always pass or always fail.
+func lintExpression(d *document, ruleNode *goyaml.Node, rule apiv1.ValidationRule, env *cel.Env) {
+	exprNode := key(ruleNode, "expression")
+	if strings.TrimSpace(rule.Expression) == "" {
+		d.report(exprNode, rule.Name, apiv1.SeverityError, "rule has no expression")
+		return
+	}
+	ast, issues := env.Compile(rule.Expression)
+	if issues != nil && issues.Err() != nil {
+		d.report(exprNode, rule.Name, apiv1.SeverityError, "invalid expression: %s", issues.Errors()[0].Message)
+		return
+	}
+	if t := ast.OutputType(); !t.IsExactType(cel.BoolType) && !t.IsExactType(cel.DynType) {
+		d.report(exprNode, rule.Name, apiv1.SeverityError, "expression returns %s, not bool", t)
+	}
+
+	if rule.MessageExpression != "" {
+		messageAst, issues := env.Compile(rule.MessageExpression)
+		if issues != nil && issues.Err() != nil {
+			d.report(key(ruleNode, "messageExpression"), rule.Name, apiv1.SeverityError, "invalid messageExpression: %s", issues.Errors()[0].Message)
+		} else if t := messageAst.OutputType(); !t.IsExactType(cel.StringType) && !t.IsExactType(cel.DynType) {
+			d.report(key(ruleNode, "messageExpression"), rule.Name, apiv1.SeverityError, "messageExpression returns %s, not string", t)
+		}
+	}
+
+	if dependsOnInput(ast) {
+		return
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return
+	}
+	out, _, err := prg.Eval(cel.NoVars())
+	switch {
+	case err != nil:
+		d.report(exprNode, rule.Name, apiv1.SeverityError, "expression does not depend on the resource and always fails: %v", err)
+	case out.Value() == true:
+		d.report(exprNode, rule.Name, apiv1.SeverityError, "expression does not depend on the resource and is always true, so the rule never fails and its message never shows")
+	case out.Value() == false:
+		// Rules that always fail can be meant to forbid what they target.
+		d.report(exprNode, rule.Name, apiv1.SeverityWarning, "expression does not depend on the resource and is always false, so every resource the rule targets fails it")
+	default:
+		d.report(exprNode, rule.Name, apiv1.SeverityError, "expression does not depend on the resource and always returns %v", out.Value())
+	}
+}
+
+// dependsOnInput reports whether ast references object, oldObject, allObjects,
+// params or a variable. params are not known when linting, so expressions of
+// params alone are not constant either.
+func dependsOnInput(ast *cel.Ast) bool {
+	for _, ref := range ast.NativeRep().ReferenceMap() {
+		switch {
+		case ref.Name == "object", ref.Name == "oldObject", ref.Name == "allObjects", ref.Name == "params":
+			return true
+		case strings.HasPrefix(ref.Name, "variables."):
+			return true
+		}
+	}
+	return false
+}
+
+// lintSelectors reports selectors that do not parse, and with Schemas set,
+// selectors of a kind no schema is known for.
+func (l *Linter) lintSelectors(d *document, ruleNode *goyaml.Node, rule apiv1.ValidationRule) {
+	type selector struct {
+		node   *goyaml.Node
+		target apiv1.TargetSelector
+	}
+	var selectors []selector
+	if rule.Target != nil {
+		selectors = append(selectors, selector{key(ruleNode, "target"), *rule.Target})
+	}
+	for i, target := range rule.Targets {
+		selectors = append(selectors, selector{item(field(ruleNode, "targets"), i), target})
+	}
+	for i, target := range rule.Exclude {
+		selectors = append(selectors, selector{item(field(ruleNode, "exclude"), i), target})
+	}
+
+	for _, s := range selectors {
+		if err := validator.ValidateSelector(s.target); err != nil {
+			d.report(s.node, rule.Name, apiv1.SeverityError, "invalid selector: %v", err)
+		}
+		if l.Schemas != nil && s.target.Kind != "" && len(l.Schemas.Kinds(s.target.Group, s.target.Version, s.target.Kind)) == 0 {
+			d.report(s.node, rule.Name, apiv1.SeverityWarning, "selector matches no known kind: %s", gvkString(s.target))
+		}
+	}
+}
+
+func gvkString(target apiv1.TargetSelector) string {
+	parts := []string{target.Kind}
+	if target.Version != "" {
+		parts = append([]string{target.Version}, parts...)
+	}
+	if target.Group != "" {
+		parts = append([]string{target.Group}, parts...)
+	}
+	return strings.Join(parts, "/")
+}
+
+// unknownFields reports keys of node that are not fields of ValidationRules,
+// which the YAML decoder ignores.
+func (d *document) unknownFields(node *goyaml.Node) {
+	if node.Kind != goyaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := node.Content[i], node.Content[i+1]
+		switch k.Value {
+		case "apiVersion", "kind", "metadata":
+		case "spec":
+			d.unknownFieldsOf(v, reflect.TypeOf(apiv1.ValidationRulesSpec{}), "spec")
+		default:
+			d.report(k, "", apiv1.SeverityError, "unknown field %q", k.Value)
+		}
+	}
+}
+
+// unknownFieldsOf reports the keys of node that are not yaml fields of t, and
+// those of the values nested in it.
+func (d *document) unknownFieldsOf(node *goyaml.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == goyaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != goyaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			fieldType, ok := fields[k.Value]
+			if !ok {
+				d.report(k, "", apiv1.SeverityError, "unknown field %q in %s", k.Value, path)
+				continue
+			}
+			d.unknownFieldsOf(v, fieldType, path+"."+k.Value)
+		}
+	case reflect.Slice:
+		if node.Kind != goyaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			d.unknownFieldsOf(item, t.Elem(), path+"["+strconv.Itoa(i)+"]")
+		}
+	}
+}
+
+// yamlFields returns the types of the fields of struct t by their yaml key.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for key, fieldType := range yamlFields(f.Type) {
+				fields[key] = fieldType
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// field returns the value of key in mapping node, or nil.
+func field(node *goyaml.Node, name string) *goyaml.Node {
+	if node == nil || node.Kind != goyaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// key returns the key node of the field at path under node, so diagnostics
+// point at the line the field is named on. When the field is missing it
+// returns the deepest node along path that exists.
+func key(node *goyaml.Node, path ...string) *goyaml.Node {
+	for i, name := range path {
+		if node == nil || node.Kind != goyaml.MappingNode {
+			return node
+		}
+		j := 0
+		for ; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == name {
+				break
+			}
+		}
+		if j+1 >= len(node.Content) {
+			return node
+		}
+		if i == len(path)-1 {
+			return node.Content[j]
+		}
+		node = node.Content[j+1]
+	}
+	return node
+}
+
+// item returns item i of sequence node, or node itself when there is no such item.
+func item(node *goyaml.Node, i int) *goyaml.Node {
+	if node == nil || node.Kind != goyaml.SequenceNode || i >= len(node.Content) {
+		return node
+	}
+	return node.Content[i]
+}
+
+func scalar(node *goyaml.Node) string {
+	if node == nil || node.Kind != goyaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
@@ -0,0 +1,206 @@
+package rulelint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readSources(t *testing.T, files ...string) []Source {
+	t.Helper()
+	var sources []Source
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		sources = append(sources, Source{Filename: filepath.Base(file), Data: data})
+	}
+	return sources
+}
+
+func TestLint(t *testing.T) {
+	resolver, err := schemas.NewResolver()
+	require.NoError(t, err)
+	dir := filepath.Join("..", "..", "fixtures", "lint")
+	sources := readSources(t, filepath.Join(dir, "problems.yaml"), filepath.Join(dir, "shared.yaml"))
+
+	l := &Linter{Schemas: resolver}
+	diagnostics, err := l.Lint(sources)
+	require.NoError(t, err)
+
+	type want struct {
+		line     int
+		severity apiv1.Severity
+		rule     string
+		message  string
+	}
+	wants := []want{
+		{15, apiv1.SeverityError, "replicas", "duplicate rule name, first defined at problems.yaml:10:7"},
+		{16, apiv1.SeverityError, "replicas", "expression returns int, not bool"},
+		{20, apiv1.SeverityError, "always-true", "is always true, so the rule never fails and its message never shows"},
+		{23, apiv1.SeverityWarning, "forbidden", "is always false"},
+		{30, apiv1.SeverityError, "bad-selector", "invalid selector: invalid labelSelector"},
+		{32, apiv1.SeverityWarning, "bad-selector", "selector matches no known kind: Deploymnet"},
+		{34, apiv1.SeverityError, "bad-selector", "invalid selector: invalid nameRegex"},
+		{35, apiv1.SeverityError, "typo", "rule has no expression"},
+		{36, apiv1.SeverityError, "", `unknown field "expresion" in spec.rules[5]`},
+		{37, apiv1.SeverityError, "typo", `unknown severity "critical"`},
+		{7, apiv1.SeverityError, "replicas", "duplicate rule name, first defined at problems.yaml:10:7"},
+	}
+	require.Len(t, diagnostics, len(wants), diagnostics)
+	for i, w := range wants {
+		d := diagnostics[i]
+		assert.Equal(t, w.line, d.Line, d.String())
+		assert.Equal(t, w.severity, d.Severity, d.String())
+		assert.Equal(t, w.rule, d.Rule, d.String())
+		assert.Contains(t, d.Message, w.message)
+	}
+	assert.Equal(t, "shared.yaml", diagnostics[len(diagnostics)-1].Filename)
+}
+
+func TestLintCleanRules(t *testing.T) {
+	sources := readSources(t,
+		filepath.Join("..", "..", "fixtures", "rules", "deployment-standards.yaml"),
+		filepath.Join("..", "..", "fixtures", "rules", "namespace-targets.yaml"),
+		filepath.Join("..", "..", "fixtures", "rules", "params-rules.yaml"),
+	)
+	// params are only known when the rules are evaluated, so a rule that
+	// only depends on them is not constant.
+	sources = append(sources, Source{Filename: "switch.yaml", Data: []byte(`apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: switch
+spec:
+  rules:
+    - name: frozen
+      expression: "!params.frozen"
+`)})
+
+	diagnostics, err := (&Linter{}).Lint(sources)
+	require.NoError(t, err)
+	assert.Empty(t, diagnostics)
+}
+
+func TestLintDocuments(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		line    int
+		message string
+	}{
+		{
+			name: "unknown top-level field",
+			data: `apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: r
+specs: {}
+spec:
+  rules: []
+`,
+			line:    5,
+			message: `unknown field "specs"`,
+		},
+		{
+			name: "unknown field in a selector",
+			data: `apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: r
+spec:
+  rules:
+    - name: r
+      expression: "has(object.spec)"
+      target:
+        kinds: Deployment
+`,
+			line:    10,
+			message: `unknown field "kinds" in spec.rules[0].target`,
+		},
+		{
+			name: "invalid variable",
+			data: `apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: r
+spec:
+  variables:
+    - name: v
+      expression: "object.spec +"
+  rules:
+    - name: r
+      expression: "variables.v"
+`,
+			line:    6,
+			message: "invalid expression in variable 'v'",
+		},
+		{
+			name: "constant expression with a comprehension",
+			data: `apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: r
+spec:
+  rules:
+    - name: r
+      expression: "[1, 2].all(x, x > 0)"
+`,
+			line:    8,
+			message: "is always true",
+		},
+		{
+			name: "invalid messageExpression",
+			data: `apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: r
+spec:
+  rules:
+    - name: r
+      expression: "has(object.spec)"
+      messageExpression: "1 + 1"
+`,
+			line:    9,
+			message: "messageExpression returns int, not string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics, err := (&Linter{}).Lint([]Source{{Filename: "rules.yaml", Data: []byte(tt.data)}})
+			require.NoError(t, err)
+			require.Len(t, diagnostics, 1, diagnostics)
+			assert.Equal(t, tt.line, diagnostics[0].Line)
+			assert.Contains(t, diagnostics[0].Message, tt.message)
+		})
+	}
+}
+
+func TestLintNoRules(t *testing.T) {
+	_, err := (&Linter{}).Lint([]Source{{Filename: "cm.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\n")}})
+	assert.EqualError(t, err, "no ValidationRules resources found")
+}
+
+func TestLintUndecodable(t *testing.T) {
+	rules, err := os.ReadFile(filepath.Join("..", "..", "fixtures", "lint", "shared.yaml"))
+	require.NoError(t, err)
+	helmTemplate := []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: {{ .Release.Name }}\n  labels:\n    {{- include \"labels\" . | nindent 4 }}\n")
+	brokenRules := []byte("apiVersion: celery.rrethy.io/v1\nkind: ValidationRules\nspec:\n  rules: [\n")
+
+	diagnostics, err := (&Linter{}).Lint([]Source{
+		{Filename: "rules.yaml", Data: rules},
+		{Filename: "templates/deployment.yaml", Data: helmTemplate},
+		{Filename: "broken.yaml", Data: brokenRules},
+	})
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 2, diagnostics)
+	assert.Equal(t, "broken.yaml", diagnostics[0].Filename)
+	assert.Equal(t, apiv1.SeverityError, diagnostics[0].Severity, "files with ValidationRules must decode")
+	assert.Equal(t, "templates/deployment.yaml", diagnostics[1].Filename)
+	assert.Equal(t, apiv1.SeverityWarning, diagnostics[1].Severity, "other files that do not decode are only a warning")
+	assert.Contains(t, diagnostics[1].Message, "decoding YAML")
+}
@@ -0,0 +1,317 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/presets"
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+)
+
+// Load reads ValidationRules from rule files. Each pattern may be a glob; a
+// pattern that matches nothing is read as a plain path so the error names it.
+// A pattern of the form preset:NAME loads the embedded preset NAME.
+//
+// Rule files may include other rule files, which are loaded in their place
+// with the overrides of the including file applied, as Resolve describes. The
+// paramsRef of each ValidationRules is read into its Params.
+func Load(patterns []string) ([]apiv1.ValidationRules, error) {
+	resolved, err := Resolve(patterns)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.Rules, nil
+}
+
+// Resolved is the rule set loaded from rule files.
+type Resolved struct {
+	// Rules are the ValidationRules with their includes loaded and their
+	// overrides applied. Their Include and Overrides are cleared.
+	Rules []apiv1.ValidationRules
+	// Files are every rule file read, including the included ones, and every
+	// params file.
+	Files []string
+}
+
+// Resolve reads ValidationRules from rule files like Load, and returns the
+// files that were read with them.
+//
+// The include paths of a ValidationRules are relative to its file. The rules
+// of the included files come before its own, with its overrides applied; an
+// override that matches no included rule is an error, as are include cycles.
+// A file matched by patterns that another matched file includes is only
+// loaded through that file, and a file included more than once with the same
+// overrides applied is only loaded once, so its rules are not evaluated twice.
+// A file with overrides gets its own copy of the rules it includes.
+func Resolve(patterns []string) (Resolved, error) {
+	var files []string
+	for _, ruleFilePattern := range patterns {
+		if _, ok := presets.Name(ruleFilePattern); ok {
+			files = append(files, ruleFilePattern)
+			continue
+		}
+		matches, err := filepath.Glob(ruleFilePattern)
+		if err != nil {
+			return Resolved{}, fmt.Errorf("expanding glob pattern %s: %w", ruleFilePattern, err)
+		}
+
+		if len(matches) == 0 {
+			matches = []string{ruleFilePattern}
+		}
+		files = append(files, matches...)
+	}
+
+	// Every file is loaded on its own first to find the files others include,
+	// then the rest are loaded together so files they share are loaded once.
+	l := &loader{included: make(map[string]bool), read: make(map[string]bool)}
+	for _, ruleFile := range files {
+		l.loaded = make(map[string]bool)
+		if _, err := l.load(ruleFile, nil, ""); err != nil {
+			return Resolved{}, err
+		}
+	}
+
+	var resolved Resolved
+	l.loaded = make(map[string]bool)
+	for _, ruleFile := range files {
+		if l.included[absPath(ruleFile)] || !l.first(ruleFile, "") {
+			continue
+		}
+		ruless, err := l.load(ruleFile, nil, "")
+		if err != nil {
+			return Resolved{}, err
+		}
+		resolved.Rules = append(resolved.Rules, ruless...)
+	}
+	resolved.Files = l.files
+	return resolved, nil
+}
+
+// loader loads rule files and the files they include.
+type loader struct {
+	// included are the absolute paths of every file another file includes.
+	included map[string]bool
+	// loaded are the absolute paths of the files loaded so far, with the
+	// overrides applied to their rules.
+	loaded map[string]bool
+	// read and files are the absolute paths and paths of every file read.
+	read  map[string]bool
+	files []string
+}
+
+// load reads ruleFile and its includes. stack is the chain of files that
+// included ruleFile, and overrides describes the overrides they apply to its
+// rules.
+func (l *loader) load(ruleFile string, stack []string, overrides string) ([]apiv1.ValidationRules, error) {
+	abs := absPath(ruleFile)
+	if slices.ContainsFunc(stack, func(f string) bool { return absPath(f) == abs }) {
+		return nil, fmt.Errorf("include cycle: %s", strings.Join(append(stack, ruleFile), " -> "))
+	}
+	l.record(ruleFile)
+
+	docs, err := readRules(ruleFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading validation rules from %s: %w", ruleFile, err)
+	}
+
+	var ruless []apiv1.ValidationRules
+	for _, rules := range docs {
+		if rules.Spec.ParamsRef != "" {
+			paramsFile := rules.Spec.ParamsRef
+			if !filepath.IsAbs(paramsFile) {
+				paramsFile = filepath.Join(filepath.Dir(ruleFile), paramsFile)
+			}
+			l.record(paramsFile)
+			rules.Params, err = LoadParams(paramsFile)
+			if err != nil {
+				return nil, fmt.Errorf("loading params of %s in %s: %w", rules.Name, ruleFile, err)
+			}
+			rules.Spec.ParamsRef = paramsFile
+		}
+
+		if len(rules.Spec.Include) == 0 && len(rules.Spec.Overrides) == 0 {
+			ruless = append(ruless, rules)
+			continue
+		}
+
+		var included []apiv1.ValidationRules
+		includedOverrides := overrides + describeOverrides(rules.Spec.Overrides)
+		for _, pattern := range rules.Spec.Include {
+			matches, err := expandInclude(ruleFile, pattern)
+			if err != nil {
+				return nil, err
+			}
+			for _, match := range matches {
+				l.included[absPath(match)] = true
+				// A file with overrides needs the rules it includes even
+				// when they were loaded before.
+				if !l.first(match, includedOverrides) && len(rules.Spec.Overrides) == 0 {
+					continue
+				}
+				includedRules, err := l.load(match, append(slices.Clip(stack), ruleFile), includedOverrides)
+				if err != nil {
+					return nil, err
+				}
+				included = append(included, includedRules...)
+			}
+		}
+
+		if err := applyOverrides(included, rules.Spec.Overrides); err != nil {
+			return nil, fmt.Errorf("applying overrides of %s in %s: %w", rules.Name, ruleFile, err)
+		}
+		ruless = append(ruless, included...)
+
+		rules.Spec.Include = nil
+		rules.Spec.Overrides = nil
+		if len(rules.Spec.Rules) > 0 {
+			ruless = append(ruless, rules)
+		}
+	}
+	return ruless, nil
+}
+
+// first records that file is loaded with overrides applied to its rules, and
+// reports whether it was not loaded that way before.
+func (l *loader) first(file string, overrides string) bool {
+	key := absPath(file) + "\n" + overrides
+	if l.loaded[key] {
+		return false
+	}
+	l.loaded[key] = true
+	return true
+}
+
+// describeOverrides returns a string that is equal for equal overrides.
+func describeOverrides(overrides []apiv1.RuleOverride) string {
+	var b strings.Builder
+	for _, override := range overrides {
+		fmt.Fprintf(&b, "%q %t %q %q", override.Rule, override.Disabled, override.Message, override.Severity)
+		if override.Target != nil {
+			fmt.Fprintf(&b, " %#v", *override.Target)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// record adds file to the files read.
+func (l *loader) record(file string) {
+	abs := absPath(file)
+	if !l.read[abs] {
+		l.read[abs] = true
+		l.files = append(l.files, file)
+	}
+}
+
+// LoadParams reads params from a YAML or JSON file holding a single document.
+func LoadParams(file string) (any, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading params: %w", err)
+	}
+	docs, err := yaml.ParseYAMLBytes[any](data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing params %s: %w", file, err)
+	}
+	if len(docs) != 1 {
+		return nil, fmt.Errorf("params file %s must hold exactly one document, found %d", file, len(docs))
+	}
+	return docs[0], nil
+}
+
+// readRules reads the ValidationRules of a rule file or preset.
+func readRules(ruleFile string) ([]apiv1.ValidationRules, error) {
+	name, ok := presets.Name(ruleFile)
+	if !ok {
+		return yaml.ParseYAMLFileToValidationRules(ruleFile)
+	}
+	data, err := presets.Read(name)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.ParseYAMLToValidationRules(data, ruleFile)
+}
+
+// expandInclude returns the files an include pattern of ruleFile matches.
+// Presets are included by name, and presets may only include other presets.
+func expandInclude(ruleFile, pattern string) ([]string, error) {
+	if _, ok := presets.Name(pattern); ok {
+		return []string{pattern}, nil
+	}
+	if _, ok := presets.Name(ruleFile); ok {
+		return nil, fmt.Errorf("preset %s includes %s, presets may only include presets", ruleFile, pattern)
+	}
+
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(ruleFile), pattern)
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("expanding include %s in %s: %w", pattern, ruleFile, err)
+	}
+	if len(matches) == 0 {
+		matches = []string{pattern}
+	}
+	return matches, nil
+}
+
+// applyOverrides applies overrides to the rules in ruless in place.
+func applyOverrides(ruless []apiv1.ValidationRules, overrides []apiv1.RuleOverride) error {
+	var errs []error
+	for _, override := range overrides {
+		if override.Rule == "" {
+			errs = append(errs, errors.New("override is missing the rule name"))
+			continue
+		}
+
+		matched := false
+		for i := range ruless {
+			rules := ruless[i].Spec.Rules
+			for j := range rules {
+				rule := &rules[j]
+				if rule.Name != override.Rule {
+					continue
+				}
+				matched = true
+				if override.Target != nil {
+					target := *override.Target
+					rule.Target = &target
+					rule.Targets = nil
+				}
+				if override.Message != "" {
+					rule.Message = override.Message
+					rule.MessageExpression = ""
+				}
+				if override.Severity != "" {
+					rule.Severity = override.Severity
+				}
+			}
+			if override.Disabled {
+				ruless[i].Spec.Rules = slices.DeleteFunc(rules, func(rule apiv1.ValidationRule) bool {
+					return rule.Name == override.Rule
+				})
+			}
+		}
+		if !matched {
+			errs = append(errs, fmt.Errorf("override of rule %q matches no included rule", override.Rule))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// absPath returns the absolute path of a rule file. Presets have no path on
+// disk and are returned as is.
+func absPath(path string) string {
+	if _, ok := presets.Name(path); ok {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,350 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	rulesDir := filepath.Join("..", "..", "fixtures", "rules")
+
+	ruless, err := Load([]string{filepath.Join(rulesDir, "basic-validation.yaml")})
+	require.NoError(t, err)
+	require.Len(t, ruless, 1)
+	assert.Equal(t, filepath.Join(rulesDir, "basic-validation.yaml"), ruless[0].Filename)
+
+	ruless, err = Load([]string{filepath.Join(rulesDir, "*-validation.yaml")})
+	require.NoError(t, err)
+	assert.Greater(t, len(ruless), 1)
+
+	_, err = Load([]string{filepath.Join(rulesDir, "missing.yaml")})
+	assert.ErrorContains(t, err, "missing.yaml")
+
+	_, err = Load([]string{"[invalid"})
+	assert.ErrorContains(t, err, "expanding glob pattern")
+}
+
+func TestLoadIncludes(t *testing.T) {
+	rulesDir := filepath.Join("..", "..", "fixtures", "rules")
+	team := filepath.Join(rulesDir, "team-deployment-standards.yaml")
+	base := filepath.Join(rulesDir, "deployment-standards.yaml")
+
+	resolved, err := Resolve([]string{team})
+	require.NoError(t, err)
+	assert.Equal(t, []string{team, base}, resolved.Files)
+	require.Len(t, resolved.Rules, 2)
+
+	included := resolved.Rules[0]
+	assert.Equal(t, base, included.Filename, "included rules should keep their file")
+	var names []string
+	for _, rule := range included.Spec.Rules {
+		names = append(names, rule.Name)
+	}
+	assert.Equal(t, []string{"minimum-replicas-production", "resource-limits-required", "no-latest-tags"}, names)
+	replicas := included.Spec.Rules[0]
+	assert.Equal(t, &apiv1.TargetSelector{Kind: "Deployment", Namespace: "production", LabelSelector: "environment=production"}, replicas.Target)
+	assert.Equal(t, "Production deployments in the production namespace need at least 3 replicas", replicas.Message)
+
+	own := resolved.Rules[1]
+	assert.Equal(t, team, own.Filename)
+	assert.Empty(t, own.Spec.Include)
+	assert.Empty(t, own.Spec.Overrides)
+	require.Len(t, own.Spec.Rules, 1)
+	assert.Equal(t, "team-label", own.Spec.Rules[0].Name)
+
+	// The base file is matched too, but only loaded through the team file.
+	ruless, err := Load([]string{filepath.Join(rulesDir, "*deployment-standards.yaml")})
+	require.NoError(t, err)
+	assert.Len(t, ruless, 2)
+
+	// The base file on its own is unchanged.
+	ruless, err = Load([]string{base})
+	require.NoError(t, err)
+	require.Len(t, ruless, 1)
+	assert.Len(t, ruless[0].Spec.Rules, 5)
+	assert.Equal(t, &apiv1.TargetSelector{Kind: "Deployment", LabelSelector: "environment=production"}, ruless[0].Spec.Rules[0].Target)
+}
+
+func TestLoadPresets(t *testing.T) {
+	resolved, err := Resolve([]string{"preset:restricted"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"preset:restricted", "preset:pod-security-baseline"}, resolved.Files)
+	require.Len(t, resolved.Rules, 2)
+	assert.Equal(t, "preset:pod-security-baseline", resolved.Rules[0].Filename)
+	assert.Equal(t, "preset:restricted", resolved.Rules[1].Filename)
+
+	// A rule file can include a preset and override its rules.
+	dir := t.TempDir()
+	ruleFile := filepath.Join(dir, "team.yaml")
+	require.NoError(t, os.WriteFile(ruleFile, []byte(`apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: team
+spec:
+  include: [preset:best-practices]
+  overrides:
+    - rule: pinned-image-tags
+      severity: error
+    - rule: health-probes
+      disabled: true
+`), 0o644))
+
+	ruless, err := Load([]string{ruleFile})
+	require.NoError(t, err)
+	require.Len(t, ruless, 1)
+	assert.Equal(t, "preset:best-practices", ruless[0].Filename)
+	var names []string
+	for _, rule := range ruless[0].Spec.Rules {
+		names = append(names, rule.Name)
+	}
+	assert.Equal(t, []string{"container-resource-limits", "pinned-image-tags", "pod-disruption-budget"}, names)
+	assert.Equal(t, apiv1.SeverityError, ruless[0].Spec.Rules[1].Severity)
+
+	_, err = Load([]string{"preset:missing"})
+	assert.ErrorContains(t, err, `loading validation rules from preset:missing: unknown preset "missing"`)
+}
+
+func TestLoadParams(t *testing.T) {
+	rulesDir := filepath.Join("..", "..", "fixtures", "rules")
+	ruleFile := filepath.Join(rulesDir, "params-rules.yaml")
+	paramsFile := filepath.Join(rulesDir, "..", "params", "namespace-owners.json")
+
+	resolved, err := Resolve([]string{ruleFile})
+	require.NoError(t, err)
+	assert.Equal(t, []string{ruleFile, paramsFile}, resolved.Files, "params files should be listed with the rule files")
+	require.Len(t, resolved.Rules, 2)
+	assert.Nil(t, resolved.Rules[0].Params)
+	assert.Equal(t, paramsFile, resolved.Rules[1].Spec.ParamsRef, "paramsRef should be resolved against the rule file")
+	assert.Equal(t, map[string]any{"payments": "team-payments", "search": "team-search"}, resolved.Rules[1].Params)
+
+	params, err := LoadParams(filepath.Join(rulesDir, "..", "params", "staging.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"registries":  []any{"registry.example.com/", "docker.io/"},
+		"minReplicas": 1,
+		"maxReplicas": 3,
+	}, params)
+
+	dir := t.TempDir()
+	multi := filepath.Join(dir, "multi.yaml")
+	require.NoError(t, os.WriteFile(multi, []byte("a: 1\n---\nb: 2\n"), 0o644))
+	_, err = LoadParams(multi)
+	assert.ErrorContains(t, err, "must hold exactly one document, found 2")
+
+	missing := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, os.WriteFile(missing, []byte(`apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: missing
+spec:
+  paramsRef: missing.json
+  rules: []
+`), 0o644))
+	_, err = Load([]string{missing})
+	assert.ErrorContains(t, err, "loading params of missing in "+missing)
+	assert.ErrorContains(t, err, "missing.json")
+}
+
+func TestLoadDiamondIncludes(t *testing.T) {
+	ruleFile := func(name string, spec string) string {
+		return `apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: ` + name + `
+spec:
+` + spec
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yaml": ruleFile("a", "  include: [b.yaml, c.yaml]\n  rules: []\n"),
+		"b.yaml": ruleFile("b", "  include: [d.yaml]\n  rules: []\n"),
+		"c.yaml": ruleFile("c", "  include: [d.yaml]\n  rules:\n    - name: c-rule\n      expression: \"true\"\n"),
+		"d.yaml": ruleFile("d", "  rules:\n    - name: d-rule\n      expression: \"true\"\n"),
+		"e.yaml": ruleFile("e", "  include: [d.yaml]\n  rules:\n    - name: e-rule\n      expression: \"true\"\n"),
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	ruleNames := func(ruless []apiv1.ValidationRules) []string {
+		var names []string
+		for _, rules := range ruless {
+			for _, rule := range rules.Spec.Rules {
+				names = append(names, rule.Name)
+			}
+		}
+		return names
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{name: "diamond", patterns: []string{"a.yaml"}, want: []string{"d-rule", "c-rule"}},
+		{name: "shared by matched files", patterns: []string{"a.yaml", "e.yaml"}, want: []string{"d-rule", "c-rule", "e-rule"}},
+		{name: "included file matched first", patterns: []string{"d.yaml", "b.yaml", "a.yaml"}, want: []string{"d-rule", "c-rule"}},
+		{name: "every file", patterns: []string{"*.yaml"}, want: []string{"d-rule", "c-rule", "e-rule"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patterns []string
+			for _, pattern := range tt.patterns {
+				patterns = append(patterns, filepath.Join(dir, pattern))
+			}
+			ruless, err := Load(patterns)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ruleNames(ruless))
+		})
+	}
+}
+
+func TestLoadSharedIncludeOverrides(t *testing.T) {
+	ruleFile := func(name string, spec string) string {
+		return `apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: ` + name + `
+spec:
+` + spec
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"base.yaml": ruleFile("base", "  rules:\n    - name: replicas\n      expression: \"object.spec.replicas >= 3\"\n    - name: labels\n      expression: \"has(object.metadata.labels)\"\n"),
+		"a.yaml":    ruleFile("a", "  include: [base.yaml]\n  overrides:\n    - rule: replicas\n      disabled: true\n  rules: []\n"),
+		"b.yaml":    ruleFile("b", "  include: [base.yaml]\n  overrides:\n    - rule: replicas\n      severity: warning\n  rules: []\n"),
+		"c.yaml":    ruleFile("c", "  include: [base.yaml]\n  overrides:\n    - rule: replicas\n      severity: warning\n  rules: []\n"),
+		"all.yaml":  ruleFile("all", "  include: [a.yaml, b.yaml, c.yaml]\n  rules: []\n"),
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	type rule struct {
+		name     string
+		severity apiv1.Severity
+	}
+	rules := func(ruless []apiv1.ValidationRules) []rule {
+		var rules []rule
+		for _, r := range ruless {
+			for _, validationRule := range r.Spec.Rules {
+				rules = append(rules, rule{validationRule.Name, validationRule.Severity})
+			}
+		}
+		return rules
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []rule
+	}{
+		{
+			name:     "matched files",
+			patterns: []string{"a.yaml", "b.yaml"},
+			want:     []rule{{"labels", ""}, {"replicas", apiv1.SeverityWarning}, {"labels", ""}},
+		},
+		{
+			name:     "matched files in the other order",
+			patterns: []string{"b.yaml", "a.yaml"},
+			want:     []rule{{"replicas", apiv1.SeverityWarning}, {"labels", ""}, {"labels", ""}},
+		},
+		{
+			name:     "same overrides",
+			patterns: []string{"b.yaml", "c.yaml"},
+			want:     []rule{{"replicas", apiv1.SeverityWarning}, {"labels", ""}, {"replicas", apiv1.SeverityWarning}, {"labels", ""}},
+		},
+		{
+			name:     "included by one file",
+			patterns: []string{"all.yaml"},
+			want:     []rule{{"labels", ""}, {"replicas", apiv1.SeverityWarning}, {"labels", ""}, {"replicas", apiv1.SeverityWarning}, {"labels", ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patterns []string
+			for _, pattern := range tt.patterns {
+				patterns = append(patterns, filepath.Join(dir, pattern))
+			}
+			ruless, err := Load(patterns)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rules(ruless))
+		})
+	}
+}
+
+func TestLoadIncludeErrors(t *testing.T) {
+	ruleFile := func(name string, spec string) string {
+		return `apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: ` + name + `
+spec:
+` + spec
+	}
+	base := ruleFile("base", `  rules:
+    - name: replicas
+      expression: "object.spec.replicas >= 3"
+`)
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"a.yaml":        ruleFile("a", "  include: [b.yaml]\n  rules: []\n"),
+				"b.yaml":        ruleFile("b", "  include: [nested/c.yaml]\n  rules: []\n"),
+				"nested/c.yaml": ruleFile("c", "  include: [../a.yaml]\n  rules: []\n"),
+			},
+			wantErr: "include cycle: a.yaml -> b.yaml -> nested/c.yaml -> a.yaml",
+		},
+		{
+			name:    "includes itself",
+			files:   map[string]string{"a.yaml": ruleFile("a", "  include: ['*.yaml']\n  rules: []\n")},
+			wantErr: "include cycle: a.yaml -> a.yaml",
+		},
+		{
+			name:    "missing include",
+			files:   map[string]string{"a.yaml": ruleFile("a", "  include: [missing.yaml]\n  rules: []\n")},
+			wantErr: "loading validation rules from missing.yaml",
+		},
+		{
+			name: "override of unknown rule",
+			files: map[string]string{
+				"base.yaml": base,
+				"a.yaml":    ruleFile("a", "  include: [base.yaml]\n  overrides:\n    - rule: replica\n      disabled: true\n  rules: []\n"),
+			},
+			wantErr: `applying overrides of a in a.yaml: override of rule "replica" matches no included rule`,
+		},
+		{
+			name: "override without a rule",
+			files: map[string]string{
+				"base.yaml": base,
+				"a.yaml":    ruleFile("a", "  include: [base.yaml]\n  overrides:\n    - severity: warning\n  rules: []\n"),
+			},
+			wantErr: "override is missing the rule name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+				require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+			}
+
+			_, err := Load([]string{filepath.Join(dir, "a.yaml")})
+			require.Error(t, err)
+			assert.Contains(t, strings.ReplaceAll(err.Error(), dir+string(filepath.Separator), ""), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package ruletest
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/input"
+	"github.com/RRethy/kube-tools/celery/pkg/presets"
+	"github.com/RRethy/kube-tools/celery/pkg/rules"
+	"github.com/RRethy/kube-tools/celery/pkg/validator"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Result is the outcome of running one ValidationTest.
+type Result struct {
+	Name     string
+	Filename string
+	// Checked is the number of rule and resource outcomes that were compared.
+	Checked    int
+	Mismatches []Mismatch
+}
+
+// Passed reports whether every expectation was met.
+func (r Result) Passed() bool {
+	return len(r.Mismatches) == 0
+}
+
+// Mismatch is an outcome that differed from what a test expected.
+type Mismatch struct {
+	Rule     string
+	Resource string
+	Expected apiv1.Outcome
+	// ExpectedMessage is the message the failure was expected to contain.
+	ExpectedMessage string
+	Actual          apiv1.Outcome
+	// Message is the failure message, or why the outcome could not be determined.
+	Message string
+}
+
+// Run validates the test's resources with its rules and compares every
+// outcome against the test's expectations.
+func Run(ctx context.Context, test apiv1.ValidationTest) (Result, error) {
+	result := Result{Name: test.Name, Filename: test.Filename}
+
+	if len(test.Spec.RuleFiles) == 0 {
+		return result, fmt.Errorf("no ruleFiles listed")
+	}
+	if len(test.Spec.Resources) == 0 {
+		return result, fmt.Errorf("no resources listed")
+	}
+
+	dir := filepath.Dir(test.Filename)
+	ruless, err := rules.Load(resolvePaths(dir, test.Spec.RuleFiles))
+	if err != nil {
+		return result, err
+	}
+
+	files, err := input.Expand(resolvePaths(dir, test.Spec.Resources), nil, nil)
+	if err != nil {
+		return result, fmt.Errorf("resolving resources: %w", err)
+	}
+
+	v := &validator.Validator{}
+	if test.Spec.Params != "" {
+		v.Params, err = rules.LoadParams(resolvePaths(dir, []string{test.Spec.Params})[0])
+		if err != nil {
+			return result, err
+		}
+	}
+	compiled, err := v.CompileRules(ruless)
+	if err != nil {
+		return result, err
+	}
+
+	resources := make(map[string]bool)
+	for _, file := range files {
+		parsed, err := input.ReadResources(file, nil)
+		if err != nil {
+			return result, fmt.Errorf("reading resources from %s: %w", file, err)
+		}
+		for _, resource := range parsed {
+			resources[resourceRef(resource)] = true
+		}
+	}
+
+	// The files are validated together, as celery validate does, so rules
+	// with the all scope and namespaceSelectors see every resource listed.
+	var results []validator.ValidationResult
+	for r := range v.Stream(ctx, files, compiled) {
+		results = append(results, r)
+	}
+
+	ruleNames := make(map[string]bool)
+	for _, rule := range compiled {
+		ruleNames[rule.Name] = true
+	}
+
+	for _, expect := range test.Spec.Expect {
+		if _, err := apiv1.ParseOutcome(string(expect.Outcome)); err != nil {
+			return result, fmt.Errorf("expectation for rule %s: %w", expect.Rule, err)
+		}
+
+		if !ruleNames[expect.Rule] {
+			result.Mismatches = append(result.Mismatches, Mismatch{
+				Rule:     expect.Rule,
+				Resource: expect.Resource,
+				Expected: expect.Outcome,
+				Message:  "rule not found in ruleFiles",
+			})
+			continue
+		}
+		if expect.Resource != "" && !resources[expect.Resource] {
+			result.Mismatches = append(result.Mismatches, Mismatch{
+				Rule:     expect.Rule,
+				Resource: expect.Resource,
+				Expected: expect.Outcome,
+				Message:  "resource not found in resources",
+			})
+			continue
+		}
+
+		actuals := outcomes(results, expect.Rule, expect.Resource)
+		if len(actuals) == 0 {
+			// The rule's target did not select the resource (or any resource).
+			actuals = []outcome{{resource: expect.Resource, outcome: apiv1.OutcomeSkip}}
+		}
+
+		for _, actual := range actuals {
+			result.Checked++
+			if actual.outcome == expect.Outcome && (expect.Message == "" || strings.Contains(actual.message, expect.Message)) {
+				continue
+			}
+			result.Mismatches = append(result.Mismatches, Mismatch{
+				Rule:            expect.Rule,
+				Resource:        actual.resource,
+				Expected:        expect.Outcome,
+				ExpectedMessage: expect.Message,
+				Actual:          actual.outcome,
+				Message:         actual.message,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+type outcome struct {
+	resource string
+	outcome  apiv1.Outcome
+	message  string
+}
+
+// outcomes returns the outcome of rule on each resource it was evaluated
+// against, limited to resource when it is set.
+func outcomes(results []validator.ValidationResult, rule string, resource string) []outcome {
+	byResource := make(map[string]*outcome)
+	for _, r := range results {
+		if r.RuleName != rule {
+			continue
+		}
+		ref := r.ResourceKind + "/" + r.ResourceName
+		if resource != "" && ref != resource {
+			continue
+		}
+
+		o := byResource[ref]
+		if o == nil {
+			o = &outcome{resource: ref, outcome: apiv1.OutcomePass}
+			byResource[ref] = o
+		}
+		if r.Skipped && o.outcome != apiv1.OutcomeFail {
+			o.outcome = apiv1.OutcomeSkip
+		}
+		if !r.Valid {
+			o.outcome = apiv1.OutcomeFail
+			if r.Err != nil {
+				o.message = r.Err.Error()
+			}
+		}
+	}
+
+	refs := make([]string, 0, len(byResource))
+	for ref := range byResource {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	out := make([]outcome, 0, len(refs))
+	for _, ref := range refs {
+		out = append(out, *byResource[ref])
+	}
+	return out
+}
+
+func resourceRef(resource *unstructured.Unstructured) string {
+	name := resource.GetName()
+	if name == "" {
+		name = "<unnamed>"
+	}
+	return resource.GetKind() + "/" + name
+}
+
+func resolvePaths(dir string, paths []string) []string {
+	resolved := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if _, ok := presets.Name(path); ok || filepath.IsAbs(path) {
+			resolved = append(resolved, path)
+			continue
+		}
+		resolved = append(resolved, filepath.Join(dir, path))
+	}
+	return resolved
+}
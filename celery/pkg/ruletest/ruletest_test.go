@@ -0,0 +1,154 @@
+package ruletest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTest(t *testing.T, path string) apiv1.ValidationTest {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	tests, err := yaml.ParseYAMLToValidationTests(data, path)
+	require.NoError(t, err)
+	require.Len(t, tests, 1)
+	return tests[0]
+}
+
+func TestRunFixture(t *testing.T) {
+	test := loadTest(t, filepath.Join("..", "..", "fixtures", "tests", "deployment-standards.yaml"))
+
+	result, err := Run(context.Background(), test)
+	require.NoError(t, err)
+	assert.True(t, result.Passed(), "unexpected mismatches: %+v", result.Mismatches)
+	assert.Equal(t, "deployment-standards", result.Name)
+	assert.Equal(t, 7, result.Checked)
+}
+
+func TestRunParams(t *testing.T) {
+	test := loadTest(t, filepath.Join("..", "..", "fixtures", "tests", "params-rules.yaml"))
+
+	result, err := Run(context.Background(), test)
+	require.NoError(t, err)
+	assert.True(t, result.Passed(), "unexpected mismatches: %+v", result.Mismatches)
+	assert.Equal(t, 6, result.Checked)
+
+	test.Spec.Params = "../params/production.yaml"
+	result, err = Run(context.Background(), test)
+	require.NoError(t, err)
+	assert.False(t, result.Passed(), "production params should change the outcomes")
+
+	test.Spec.Params = "../params/missing.yaml"
+	_, err = Run(context.Background(), test)
+	assert.ErrorContains(t, err, "missing.yaml")
+}
+
+func TestRunCrossFile(t *testing.T) {
+	test := loadTest(t, filepath.Join("..", "..", "fixtures", "tests", "cross-file-rules.yaml"))
+
+	result, err := Run(context.Background(), test)
+	require.NoError(t, err)
+	assert.True(t, result.Passed(), "scope: all rules should see the resources of every file: %+v", result.Mismatches)
+	assert.Equal(t, 3, result.Checked)
+}
+
+func TestRunNamespaceSelector(t *testing.T) {
+	test := loadTest(t, filepath.Join("..", "..", "fixtures", "tests", "namespace-targets.yaml"))
+
+	result, err := Run(context.Background(), test)
+	require.NoError(t, err)
+	assert.True(t, result.Passed(), "namespaceSelectors should match Namespaces in other files: %+v", result.Mismatches)
+	assert.Equal(t, 4, result.Checked)
+}
+
+func TestRunMismatches(t *testing.T) {
+	fixtures, err := filepath.Abs(filepath.Join("..", "..", "fixtures"))
+	require.NoError(t, err)
+
+	test := apiv1.ValidationTest{
+		Filename: filepath.Join(t.TempDir(), "suite.yaml"),
+		Spec: apiv1.ValidationTestSpec{
+			RuleFiles: []string{filepath.Join(fixtures, "rules", "deployment-standards.yaml")},
+			Resources: []string{filepath.Join(fixtures, "resources", "invalid-deployments.yaml")},
+			Expect: []apiv1.Expectation{
+				{Rule: "no-latest-tags", Outcome: apiv1.OutcomePass},
+				{Rule: "resource-limits-required", Resource: "Deployment/app-no-limits", Outcome: apiv1.OutcomeFail, Message: "not the message"},
+				{Rule: "minimum-replicas-production", Resource: "Deployment/app-no-limits", Outcome: apiv1.OutcomePass},
+				{Rule: "missing-rule", Outcome: apiv1.OutcomePass},
+				{Rule: "no-latest-tags", Resource: "Deployment/missing", Outcome: apiv1.OutcomePass},
+			},
+		},
+	}
+
+	result, err := Run(context.Background(), test)
+	require.NoError(t, err)
+	assert.False(t, result.Passed())
+	require.Len(t, result.Mismatches, 5)
+
+	assert.Equal(t, Mismatch{
+		Rule:     "no-latest-tags",
+		Resource: "Deployment/app-insufficient-replicas",
+		Expected: apiv1.OutcomePass,
+		Actual:   apiv1.OutcomeFail,
+		Message:  "Container images must not use 'latest' tag",
+	}, result.Mismatches[0])
+	assert.Equal(t, "not the message", result.Mismatches[1].ExpectedMessage)
+	assert.Equal(t, apiv1.OutcomeFail, result.Mismatches[1].Actual)
+	assert.Equal(t, apiv1.OutcomeSkip, result.Mismatches[2].Actual)
+	assert.Equal(t, "rule not found in ruleFiles", result.Mismatches[3].Message)
+	assert.Equal(t, "resource not found in resources", result.Mismatches[4].Message)
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    apiv1.ValidationTestSpec
+		wantErr string
+	}{
+		{
+			name:    "no rule files",
+			spec:    apiv1.ValidationTestSpec{Resources: []string{"r.yaml"}},
+			wantErr: "no ruleFiles listed",
+		},
+		{
+			name:    "no resources",
+			spec:    apiv1.ValidationTestSpec{RuleFiles: []string{"rules.yaml"}},
+			wantErr: "no resources listed",
+		},
+		{
+			name: "missing rule file",
+			spec: apiv1.ValidationTestSpec{
+				RuleFiles: []string{"missing.yaml"},
+				Resources: []string{"r.yaml"},
+			},
+			wantErr: "missing.yaml",
+		},
+		{
+			name: "invalid outcome",
+			spec: apiv1.ValidationTestSpec{
+				RuleFiles: []string{"../rules/basic-validation.yaml"},
+				Resources: []string{"../resources/valid-deployment.yaml"},
+				Expect:    []apiv1.Expectation{{Rule: "x", Outcome: "passes"}},
+			},
+			wantErr: `unknown outcome "passes"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := apiv1.ValidationTest{
+				Filename: filepath.Join("..", "..", "fixtures", "tests", "suite.yaml"),
+				Spec:     tt.spec,
+			}
+			_, err := Run(context.Background(), test)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
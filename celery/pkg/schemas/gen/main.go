@@ -0,0 +1,95 @@
+//go:build ignore
+
+// gen extracts the schemas of the stable built-in Kubernetes API group versions
+// from the OpenAPI v3 documents in kubernetes/api/openapi-spec/v3 and writes
+// them to kubernetes.json.gz. Descriptions are dropped to keep it small.
+//
+// Usage: go run gen/main.go <kubernetes>/api/openapi-spec/v3
+//
+// It is run by go generate in pkg/schemas.
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+var stable = regexp.MustCompile(`__v[0-9]+_openapi\.json$`)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: go run gen/main.go <kubernetes>/api/openapi-spec/v3")
+		os.Exit(1)
+	}
+	if err := run(os.Args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*_openapi.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	schemas := make(map[string]any)
+	for _, file := range files {
+		if !stable.MatchString(filepath.Base(file)) {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var doc struct {
+			Components struct {
+				Schemas map[string]any `json:"schemas"`
+			} `json:"components"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parsing %s: %w", file, err)
+		}
+		for name, schema := range doc.Components.Schemas {
+			schemas[name] = stripDescriptions(schema)
+		}
+	}
+
+	out, err := os.Create("kubernetes.json.gz")
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zw, err := gzip.NewWriterLevel(out, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(zw).Encode(schemas); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func stripDescriptions(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		if _, ok := v["description"].(string); ok {
+			delete(v, "description")
+		}
+		for k, child := range v {
+			v[k] = stripDescriptions(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = stripDescriptions(child)
+		}
+	}
+	return v
+}
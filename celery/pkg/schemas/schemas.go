@@ -0,0 +1,242 @@
+package schemas
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/RRethy/kube-tools/celery/pkg/input"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/cel/openapi/resolver"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// kubernetesSchemas holds the schemas of the stable built-in API group versions
+// of Kubernetes v1.34. Regenerate it from a kubernetes checkout with
+// KUBERNETES_SRC=<checkout> go generate ./pkg/schemas.
+//
+//go:generate go run gen/main.go $KUBERNETES_SRC/api/openapi-spec/v3
+//go:embed kubernetes.json.gz
+var kubernetesSchemas []byte
+
+const (
+	refPrefix     = "#/components/schemas/"
+	objectMetaRef = refPrefix + "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"
+	gvkExtension  = "x-kubernetes-group-version-kind"
+
+	intOrStringExtension     = "x-kubernetes-int-or-string"
+	preserveUnknownFieldsExt = "x-kubernetes-preserve-unknown-fields"
+
+	crdGroup = "apiextensions.k8s.io"
+	crdKind  = "CustomResourceDefinition"
+)
+
+// Resolver finds the OpenAPI schema of a kind among the built-in Kubernetes
+// types and any CustomResourceDefinitions added to it.
+type Resolver struct {
+	definitions map[string]*spec.Schema
+	kinds       map[schema.GroupVersionKind]string
+}
+
+// NewResolver returns a Resolver that knows the built-in Kubernetes types.
+func NewResolver() (*Resolver, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(kubernetesSchemas))
+	if err != nil {
+		return nil, fmt.Errorf("reading built-in schemas: %w", err)
+	}
+	var definitions map[string]*spec.Schema
+	if err := json.NewDecoder(zr).Decode(&definitions); err != nil {
+		return nil, fmt.Errorf("reading built-in schemas: %w", err)
+	}
+
+	r := &Resolver{
+		definitions: make(map[string]*spec.Schema, len(definitions)),
+		kinds:       make(map[schema.GroupVersionKind]string),
+	}
+	for name, s := range definitions {
+		ref := refPrefix + name
+		r.definitions[ref] = markDynamic(s)
+		for _, gvk := range extensionGVKs(s.Extensions) {
+			r.kinds[gvk] = ref
+		}
+	}
+	return r, nil
+}
+
+// LoadCRDs adds the schemas of the CustomResourceDefinitions found in paths,
+// which may be files, directories or globs. Other resources are ignored.
+func (r *Resolver) LoadCRDs(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	files, err := input.Expand(paths, nil, nil)
+	if err != nil {
+		return fmt.Errorf("resolving CRD files: %w", err)
+	}
+
+	for _, file := range files {
+		resources, err := input.ReadResources(file, nil)
+		if err != nil {
+			return fmt.Errorf("reading CRDs from %s: %w", file, err)
+		}
+		for _, resource := range resources {
+			gvk := resource.GroupVersionKind()
+			if gvk.Group != crdGroup || gvk.Kind != crdKind {
+				continue
+			}
+			if err := r.AddCRD(resource); err != nil {
+				return fmt.Errorf("loading CRD %s from %s: %w", resource.GetName(), file, err)
+			}
+		}
+	}
+	return nil
+}
+
+// AddCRD adds the schema of every version of a CustomResourceDefinition.
+// Versions without a schema are skipped.
+func (r *Resolver) AddCRD(crd *unstructured.Unstructured) error {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	if group == "" || kind == "" {
+		return fmt.Errorf("spec.group and spec.names.kind are required")
+	}
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if err != nil {
+		return fmt.Errorf("reading spec.versions: %w", err)
+	}
+
+	for _, v := range versions {
+		version, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(version, "name")
+		openAPISchema, found, _ := unstructured.NestedMap(version, "schema", "openAPIV3Schema")
+		if name == "" || !found {
+			continue
+		}
+
+		data, err := json.Marshal(openAPISchema)
+		if err != nil {
+			return err
+		}
+		s := &spec.Schema{}
+		if err := json.Unmarshal(data, s); err != nil {
+			return fmt.Errorf("parsing schema of version %s: %w", name, err)
+		}
+
+		gvk := schema.GroupVersionKind{Group: group, Version: name, Kind: kind}
+		ref := refPrefix + strings.Join([]string{group, name, kind}, ".")
+		r.definitions[ref] = withTypeMeta(markDynamic(s))
+		r.kinds[gvk] = ref
+	}
+	return nil
+}
+
+// ResolveSchema returns the schema of gvk with every reference resolved.
+func (r *Resolver) ResolveSchema(gvk schema.GroupVersionKind) (*spec.Schema, error) {
+	ref, ok := r.kinds[gvk]
+	if !ok {
+		return nil, fmt.Errorf("no schema for %s: %w", gvk, resolver.ErrSchemaNotFound)
+	}
+	return resolver.PopulateRefs(func(ref string) (*spec.Schema, bool) {
+		s, ok := r.definitions[ref]
+		return s, ok
+	}, ref)
+}
+
+// Kinds returns the known kinds matching group, version and kind, sorted. An
+// empty group or version matches any.
+func (r *Resolver) Kinds(group, version, kind string) []schema.GroupVersionKind {
+	var matches []schema.GroupVersionKind
+	for gvk := range r.kinds {
+		if gvk.Kind != kind {
+			continue
+		}
+		if group != "" && gvk.Group != group {
+			continue
+		}
+		if version != "" && gvk.Version != version {
+			continue
+		}
+		matches = append(matches, gvk)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].String() < matches[j].String()
+	})
+	return matches
+}
+
+// withTypeMeta declares apiVersion, kind and the full ObjectMeta on a custom
+// resource schema, which usually leaves them out.
+func withTypeMeta(s *spec.Schema) *spec.Schema {
+	result := *s
+	props := make(map[string]spec.Schema, len(s.Properties)+3)
+	for k, prop := range s.Properties {
+		props[k] = prop
+	}
+	props["apiVersion"] = *spec.StringProperty()
+	props["kind"] = *spec.StringProperty()
+	props["metadata"] = *spec.RefSchema(objectMetaRef)
+	result.Properties = props
+	if len(result.Type) == 0 {
+		result.Type = spec.StringOrArray{"object"}
+	}
+	return &result
+}
+
+// markDynamic marks the parts of s whose shape the schema does not pin down as
+// dyn, so expressions using them type check and are checked at evaluation time
+// instead. These are untyped schemas like Quantity, a string or a number, and
+// objects preserving unknown fields, whose fields CEL would otherwise hide.
+func markDynamic(s *spec.Schema) *spec.Schema {
+	if s == nil {
+		return nil
+	}
+
+	untyped := len(s.Type) == 0 && len(s.Properties) == 0 && s.Ref.GetURL() == nil && len(s.AllOf) == 0
+	preserveUnknown, _ := s.Extensions.GetBool(preserveUnknownFieldsExt)
+	if untyped || preserveUnknown {
+		s.AddExtension(intOrStringExtension, true)
+		return s
+	}
+
+	for name, prop := range s.Properties {
+		s.Properties[name] = *markDynamic(&prop)
+	}
+	for i := range s.AllOf {
+		markDynamic(&s.AllOf[i])
+	}
+	if s.AdditionalProperties != nil {
+		markDynamic(s.AdditionalProperties.Schema)
+	}
+	if s.Items != nil {
+		markDynamic(s.Items.Schema)
+		for i := range s.Items.Schemas {
+			markDynamic(&s.Items.Schemas[i])
+		}
+	}
+	return s
+}
+
+func extensionGVKs(extensions spec.Extensions) []schema.GroupVersionKind {
+	raw, ok := extensions[gvkExtension]
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var gvks []schema.GroupVersionKind
+	if err := json.Unmarshal(data, &gvks); err != nil {
+		return nil
+	}
+	return gvks
+}
@@ -0,0 +1,78 @@
+package schemas
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/cel/openapi/resolver"
+)
+
+func TestResolverBuiltin(t *testing.T) {
+	r, err := NewResolver()
+	require.NoError(t, err)
+
+	s, err := r.ResolveSchema(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	require.NoError(t, err)
+	replicas := s.Properties["spec"].Properties["replicas"]
+	assert.Equal(t, []string{"integer"}, []string(replicas.Type))
+	assert.Contains(t, s.Properties["metadata"].Properties, "labels", "references should be resolved")
+
+	limits := s.Properties["spec"].Properties["template"].Properties["spec"].Properties["containers"].Items.Schema.Properties["resources"].Properties["limits"]
+	quantity, _ := limits.AdditionalProperties.Schema.Extensions.GetBool(intOrStringExtension)
+	assert.True(t, quantity, "quantities can be strings or numbers and should be dyn")
+
+	_, err = r.ResolveSchema(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"})
+	assert.ErrorIs(t, err, resolver.ErrSchemaNotFound)
+}
+
+func TestResolverKinds(t *testing.T) {
+	r, err := NewResolver()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name                 string
+		group, version, kind string
+		want                 []string
+	}{
+		{name: "kind only", kind: "HorizontalPodAutoscaler", want: []string{"autoscaling/v1, Kind=HorizontalPodAutoscaler", "autoscaling/v2, Kind=HorizontalPodAutoscaler"}},
+		{name: "with version", version: "v2", kind: "HorizontalPodAutoscaler", want: []string{"autoscaling/v2, Kind=HorizontalPodAutoscaler"}},
+		{name: "core group", kind: "Pod", want: []string{"/v1, Kind=Pod"}},
+		{name: "wrong group", group: "apps", kind: "Pod"},
+		{name: "unknown kind", kind: "Widget"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, gvk := range r.Kinds(tt.group, tt.version, tt.kind) {
+				got = append(got, gvk.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolverCRDs(t *testing.T) {
+	r, err := NewResolver()
+	require.NoError(t, err)
+	require.NoError(t, r.LoadCRDs([]string{filepath.Join("..", "..", "fixtures", "crds")}))
+
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	assert.Equal(t, []schema.GroupVersionKind{gvk}, r.Kinds("", "", "Widget"))
+
+	s, err := r.ResolveSchema(gvk)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"integer"}, []string(s.Properties["spec"].Properties["size"].Type))
+	assert.Contains(t, s.Properties["metadata"].Properties, "labels", "custom resources should have the full ObjectMeta")
+	assert.Contains(t, s.Properties, "apiVersion")
+
+	settings := s.Properties["spec"].Properties["settings"]
+	dynamic, _ := settings.Extensions.GetBool(intOrStringExtension)
+	assert.True(t, dynamic, "objects preserving unknown fields should be dyn")
+
+	err = r.LoadCRDs([]string{"does-not-exist.yaml"})
+	assert.ErrorContains(t, err, "does-not-exist.yaml")
+}
@@ -0,0 +1,157 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/input"
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// benchmarkRules are typical per-resource rules plus one using variables.
+var benchmarkRules = []apiv1.ValidationRules{{
+	Filename:   "bench.yaml",
+	ObjectMeta: metav1.ObjectMeta{Name: "bench"},
+	Spec: apiv1.ValidationRulesSpec{
+		Variables: []apiv1.Variable{
+			{Name: "containers", Expression: "object.spec.template.spec.containers"},
+		},
+		Rules: []apiv1.ValidationRule{
+			{Name: "minimum-replicas", Expression: "object.spec.replicas >= 2", Target: &apiv1.TargetSelector{Kind: "Deployment"}},
+			{Name: "has-app-label", Expression: "has(object.metadata.labels) && 'app' in object.metadata.labels"},
+			{Name: "no-latest", Expression: "variables.containers.all(c, !c.image.endsWith(':latest'))", Target: &apiv1.TargetSelector{Kind: "Deployment"}},
+			{Name: "limits", Expression: "variables.containers.all(c, has(c.resources.limits))", Target: &apiv1.TargetSelector{Kind: "Deployment"}},
+		},
+	},
+}}
+
+func benchmarkManifest(file, count int) string {
+	var sb strings.Builder
+	for i := range count {
+		fmt.Fprintf(&sb, `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app-%d-%d
+  labels:
+    app: app-%d-%d
+spec:
+  replicas: %d
+  template:
+    spec:
+      containers:
+        - name: app
+          image: registry.example.com/app:v%d
+          resources:
+            limits:
+              cpu: 500m
+`, file, i, file, i, i%3+1, i)
+	}
+	return sb.String()
+}
+
+// writeBenchmarkFiles writes files manifests of perFile Deployments each.
+func writeBenchmarkFiles(b *testing.B, files, perFile int) []string {
+	b.Helper()
+	dir := b.TempDir()
+	paths := make([]string, 0, files)
+	for i := range files {
+		path := filepath.Join(dir, fmt.Sprintf("manifest-%d.yaml", i))
+		if err := os.WriteFile(path, []byte(benchmarkManifest(i, perFile)), 0o644); err != nil {
+			b.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// benchmarkWorkers returns the MaxWorkers values to compare: serial, a few,
+// one per CPU and the CLI default.
+func benchmarkWorkers() []int {
+	workers := []int{1, 4, runtime.GOMAXPROCS(0), 128}
+	slices.Sort(workers)
+	return slices.Compact(workers)
+}
+
+func benchmarkStream(b *testing.B, files []string, workers int) {
+	v := &Validator{MaxWorkers: workers}
+	rules, err := v.CompileRules(benchmarkRules)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		for range v.Stream(context.Background(), files, rules) {
+		}
+	}
+}
+
+func BenchmarkStreamManyFiles(b *testing.B) {
+	files := writeBenchmarkFiles(b, 200, 20)
+	for _, workers := range benchmarkWorkers() {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			benchmarkStream(b, files, workers)
+		})
+	}
+}
+
+func BenchmarkStreamLargeFile(b *testing.B) {
+	files := writeBenchmarkFiles(b, 1, 5000)
+	for _, workers := range benchmarkWorkers() {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			benchmarkStream(b, files, workers)
+		})
+	}
+}
+
+func BenchmarkValidateResources(b *testing.B) {
+	resources, err := input.ReadResources(writeBenchmarkFiles(b, 1, 1000)[0], nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	v := &Validator{}
+	rules, err := v.CompileRules(benchmarkRules)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		v.ValidateResources(context.Background(), "bench.yaml", resources, rules)
+	}
+}
+
+func BenchmarkCompileRules(b *testing.B) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "fixtures", "rules", "*.yaml"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var ruless []apiv1.ValidationRules
+	for _, file := range files {
+		rules, err := yaml.ParseYAMLFileToValidationRules(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ruless = append(ruless, rules...)
+	}
+
+	v := &Validator{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		if _, err := v.CompileRules(ruless); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
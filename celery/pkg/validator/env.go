@@ -0,0 +1,66 @@
+package validator
+
+import (
+	"fmt"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/util/version"
+	apiservercel "k8s.io/apiserver/pkg/cel"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+// newBaseEnv returns the CEL environment rules are compiled in.
+//
+// It extends the Kubernetes base environment at the default compatibility version,
+// the same one the apiserver uses for new ValidatingAdmissionPolicy and CRD
+// validation expressions. Rules can use the quantity, IP/CIDR, URL, regex, lists,
+// sets, strings, format and semver libraries, and stay portable to in-cluster policies.
+//
+// oldObject is the previous version of object. It is null unless one is known,
+// e.g. for updates at admission time. params are the params of the rules, null
+// when they have none.
+//
+// Macro calls are tracked so that explanations show comprehensions the way
+// they were written.
+func newBaseEnv() (*cel.Env, error) {
+	envSet := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), true)
+	return envSet.NewExpressionsEnv().Extend(append(objectVariables(cel.DynType), cel.EnableMacroCallTracking())...)
+}
+
+// NewEnv returns the CEL environment the rules of rules are compiled in, with
+// their variables declared.
+func NewEnv(rules apiv1.ValidationRules) (*cel.Env, error) {
+	env, err := newBaseEnv()
+	if err != nil {
+		return nil, fmt.Errorf("creating CEL environment: %w", err)
+	}
+	env, _, err = compileVariables(env, rules)
+	return env, err
+}
+
+// newTypedEnv returns the base environment with object and oldObject declared
+// as objectType instead of dyn, the way the apiserver type checks
+// ValidatingAdmissionPolicies.
+func newTypedEnv(objectType *apiservercel.DeclType) (*cel.Env, error) {
+	envSet, err := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), true).Extend(
+		environment.VersionedOptions{
+			IntroducedVersion: version.MajorMinor(1, 0),
+			EnvOptions:        objectVariables(objectType.CelType()),
+			DeclTypes:         []*apiservercel.DeclType{objectType},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return envSet.NewExpressionsEnv(), nil
+}
+
+func objectVariables(objectType *cel.Type) []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Variable("object", objectType),
+		cel.Variable("oldObject", objectType),
+		cel.Variable("allObjects", cel.ListType(cel.DynType)),
+		cel.Variable("params", cel.DynType),
+	}
+}
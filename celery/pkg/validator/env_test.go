@@ -0,0 +1,116 @@
+package validator
+
+import (
+	"context"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestKubernetesLibraries(t *testing.T) {
+	ctx := context.Background()
+	v := &Validator{}
+
+	pod := &unstructured.Unstructured{
+		Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata": map[string]any{
+				"name": "web",
+				"annotations": map[string]any{
+					"docs": "https://example.com/runbook",
+				},
+			},
+			"spec": map[string]any{
+				"containers": []any{
+					map[string]any{
+						"name":  "nginx",
+						"image": "registry.example.com/nginx:1.21",
+						"resources": map[string]any{
+							"limits": map[string]any{
+								"memory": "512Mi",
+								"cpu":    "500m",
+							},
+						},
+					},
+				},
+			},
+			"status": map[string]any{
+				"podIP": "10.0.0.12",
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		want       bool
+	}{
+		{
+			name:       "quantity",
+			expression: "quantity(object.spec.containers[0].resources.limits.memory).isLessThan(quantity('2Gi'))",
+			want:       true,
+		},
+		{
+			name:       "quantity comparison fails",
+			expression: "quantity(object.spec.containers[0].resources.limits.cpu).isGreaterThan(quantity('1'))",
+			want:       false,
+		},
+		{
+			name:       "ip and cidr",
+			expression: "cidr('10.0.0.0/8').containsIP(ip(object.status.podIP))",
+			want:       true,
+		},
+		{
+			name:       "url",
+			expression: "url(object.metadata.annotations.docs).getScheme() == 'https'",
+			want:       true,
+		},
+		{
+			name:       "regex",
+			expression: "object.spec.containers[0].image.find('[0-9.]+$') == '1.21'",
+			want:       true,
+		},
+		{
+			name:       "lists",
+			expression: "[1, 2, 3].isSorted() && [4, 5].sum() == 9",
+			want:       true,
+		},
+		{
+			name:       "sets",
+			expression: "sets.contains(['nginx', 'sidecar'], object.spec.containers.map(c, c.name))",
+			want:       true,
+		},
+		{
+			name:       "strings",
+			expression: "object.metadata.name.upperAscii() == 'WEB' && 'a,b'.split(',').size() == 2",
+			want:       true,
+		},
+		{
+			name:       "format",
+			expression: "!format.dns1123Label().validate(object.metadata.name).hasValue()",
+			want:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := v.CompileRules([]apiv1.ValidationRules{
+				{
+					Filename: "libraries.yaml",
+					Spec: apiv1.ValidationRulesSpec{
+						Rules: []apiv1.ValidationRule{{Name: tt.name, Expression: tt.expression, Message: "failed"}},
+					},
+				},
+			})
+			require.NoError(t, err)
+
+			results := v.ValidateResources(ctx, "pod.yaml", []*unstructured.Unstructured{pod}, rules)
+			require.Len(t, results, 1)
+			assert.Equal(t, tt.want, results[0].Valid, "unexpected result: %v", results[0].Err)
+		})
+	}
+}
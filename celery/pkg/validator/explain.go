@@ -0,0 +1,381 @@
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/parser"
+	"google.golang.org/protobuf/types/known/structpb"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+)
+
+// maxValueLength is the length values in explanations are truncated to.
+const maxValueLength = 120
+
+// Explanation is the value a subexpression of a rule evaluated to, and the
+// subexpressions that made it so. The explanation of a rule that was false
+// starts at its expression and follows the operands that decided the result:
+// the false operands of &&, the arguments of comparisons and functions, and
+// the elements of all, exists and exists_one that decided the comprehension.
+type Explanation struct {
+	// Element is the index of a list element or the key of a map entry, set
+	// when the explanation is of an element of a comprehension. Expression is
+	// then the iteration variable and Value the element.
+	Element    string        `json:"element,omitempty" yaml:"element,omitempty"`
+	Expression string        `json:"expression" yaml:"expression"`
+	Value      string        `json:"value,omitempty" yaml:"value,omitempty"`
+	Children   []Explanation `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// String returns the explanation on one line, e.g. c.image = "nginx".
+func (e Explanation) String() string {
+	s := e.Expression
+	if e.Value != "" {
+		s += " = " + e.Value
+	}
+	if e.Element != "" {
+		s = e.Element + ": " + s
+	}
+	return s
+}
+
+// explain evaluates the rule of e again with activation, tracking the value of
+// every subexpression, and explains the result. The cost of evaluating it
+// again is spent from the budget of v. It returns nil when the rule cannot be
+// evaluated again.
+func (v *Validator) explain(ctx context.Context, e evaluation, activation map[string]any) *Explanation {
+	if e.rule.env == nil || e.rule.ast == nil {
+		return nil
+	}
+	x := &explainer{ctx: ctx, validator: v, env: e.rule.env, ast: e.rule.ast.NativeRep(), costLimit: e.rule.CostLimit}
+	root := x.ast.Expr()
+	state, ok := x.eval(root, activation)
+	if !ok {
+		return nil
+	}
+	explanation := x.explain(root, state, activation)
+	return &explanation
+}
+
+// explainer explains the evaluation of a rule.
+type explainer struct {
+	ctx       context.Context
+	validator *Validator
+	env       *cel.Env
+	ast       *celast.AST
+	costLimit uint64
+}
+
+// eval evaluates expr, a subexpression of the rule, with activation and
+// returns the values of its subexpressions. Subexpressions of comprehensions
+// can be evaluated for one element by binding the iteration variables in
+// activation. Nothing is evaluated once the cost budget is spent.
+func (x *explainer) eval(expr celast.Expr, activation map[string]any) (interpreter.EvalState, bool) {
+	if x.validator.budgetSpent() {
+		return nil, false
+	}
+	checked, err := celast.ToProto(celast.NewCheckedAST(celast.NewAST(expr, x.ast.SourceInfo()), x.ast.TypeMap(), x.ast.ReferenceMap()))
+	if err != nil {
+		return nil, false
+	}
+	ast := cel.CheckedExprToAst(checked)
+
+	// CEL does not track the cost of an evaluation while tracking its state,
+	// so expr is evaluated once to limit and spend its cost and once more,
+	// taking the same steps, for the values of its subexpressions.
+	costPrg, err := x.env.Program(ast, programOptions(x.costLimit)...)
+	if err != nil {
+		return nil, false
+	}
+	_, details, err := costPrg.ContextEval(x.ctx, activation)
+	if details != nil && details.ActualCost() != nil {
+		x.validator.spent.Add(2 * *details.ActualCost())
+	}
+	if err != nil && x.validator.limitError(x.ctx, err, x.costLimit) != nil {
+		return nil, false
+	}
+
+	statePrg, err := x.env.Program(ast, cel.EvalOptions(cel.OptTrackState), cel.InterruptCheckFrequency(celconfig.CheckFrequency))
+	if err != nil {
+		return nil, false
+	}
+	// An error is a value like any other here, the explanation shows where it
+	// came from.
+	_, details, _ = statePrg.ContextEval(x.ctx, activation)
+	if details == nil || details.State() == nil {
+		return nil, false
+	}
+	return details.State(), true
+}
+
+// explain explains expr, whose subexpressions evaluated to state.
+func (x *explainer) explain(expr celast.Expr, state interpreter.EvalState, activation map[string]any) Explanation {
+	explanation := Explanation{Expression: x.unparse(expr)}
+	val, found := state.Value(expr.ID())
+	if !found {
+		return explanation
+	}
+	explanation.Value = formatValue(val)
+
+	if expr.Kind() == celast.ComprehensionKind {
+		explanation.Children = x.explainElements(expr.AsComprehension(), val, state, activation)
+		return explanation
+	}
+	for _, operand := range deciding(expr, val, state) {
+		explanation.Children = append(explanation.Children, x.explain(operand, state, activation))
+	}
+	return explanation
+}
+
+// deciding returns the operands of expr that decided it evaluated to val.
+func deciding(expr celast.Expr, val ref.Val, state interpreter.EvalState) []celast.Expr {
+	switch expr.Kind() {
+	case celast.SelectKind:
+		// has(a.b) is explained by what a is.
+		if sel := expr.AsSelect(); sel.IsTestOnly() {
+			return []celast.Expr{sel.Operand()}
+		}
+	case celast.CallKind:
+		call := expr.AsCall()
+		switch call.FunctionName() {
+		case operators.LogicalAnd, operators.LogicalOr:
+			// The operands that evaluated to the same value as the whole
+			// decided it: the false ones of a false &&, the true ones of a
+			// true ||, and every one otherwise.
+			operands := flatten(expr, call.FunctionName())
+			var same []celast.Expr
+			for _, operand := range operands {
+				if v, found := state.Value(operand.ID()); found && sameResult(v, val) {
+					same = append(same, operand)
+				}
+			}
+			if len(same) == 0 {
+				return operands
+			}
+			return same
+		case operators.LogicalNot:
+			return call.Args()
+		case operators.Conditional:
+			args := call.Args()
+			if cond, found := state.Value(args[0].ID()); found && cond == types.True {
+				return []celast.Expr{args[0], args[1]}
+			}
+			return []celast.Expr{args[0], args[2]}
+		case operators.Index, operators.OptIndex, operators.OptSelect:
+			return nil
+		}
+
+		var operands []celast.Expr
+		if call.IsMemberFunction() {
+			operands = append(operands, call.Target())
+		}
+		for _, arg := range call.Args() {
+			if arg.Kind() != celast.LiteralKind {
+				operands = append(operands, arg)
+			}
+		}
+		return operands
+	}
+	return nil
+}
+
+// flatten returns the operands of the chain of calls of the logical operator
+// function that expr is, so a && b && c has the operands a, b and c.
+func flatten(expr celast.Expr, function string) []celast.Expr {
+	if expr.Kind() != celast.CallKind || expr.AsCall().FunctionName() != function {
+		return []celast.Expr{expr}
+	}
+	var operands []celast.Expr
+	for _, arg := range expr.AsCall().Args() {
+		operands = append(operands, flatten(arg, function)...)
+	}
+	return operands
+}
+
+// sameResult reports whether a and b are the same boolean, or both errors.
+func sameResult(a, b ref.Val) bool {
+	if types.IsError(a) || types.IsError(b) {
+		return types.IsError(a) && types.IsError(b)
+	}
+	return a.Type() == types.BoolType && a.Equal(b) == types.True
+}
+
+// explainElements explains the elements of the range of comp that decided it
+// evaluated to val, when comp is an all, exists or exists_one macro. Each
+// element is evaluated again with the iteration variables bound to it.
+func (x *explainer) explainElements(comp celast.ComprehensionExpr, val ref.Val, state interpreter.EvalState, activation map[string]any) []Explanation {
+	macro, predicate, ok := quantifier(comp)
+	if !ok {
+		return nil
+	}
+	iterRange, found := state.Value(comp.IterRange().ID())
+	if !found {
+		return nil
+	}
+
+	var explanations []Explanation
+	var results []ref.Val
+	for _, element := range elementsOf(iterRange) {
+		elementActivation := maps.Clone(activation)
+		var explanation Explanation
+		switch {
+		case element.key == nil && comp.HasIterVar2():
+			elementActivation[comp.IterVar()] = types.Int(element.index)
+			elementActivation[comp.IterVar2()] = element.value
+			explanation = Explanation{Element: fmt.Sprintf("index %d", element.index), Expression: comp.IterVar2(), Value: formatValue(element.value)}
+		case element.key == nil:
+			elementActivation[comp.IterVar()] = element.value
+			explanation = Explanation{Element: fmt.Sprintf("index %d", element.index), Expression: comp.IterVar(), Value: formatValue(element.value)}
+		case comp.HasIterVar2():
+			elementActivation[comp.IterVar()] = element.key
+			elementActivation[comp.IterVar2()] = element.value
+			explanation = Explanation{Element: "key " + formatValue(element.key), Expression: comp.IterVar2(), Value: formatValue(element.value)}
+		default:
+			elementActivation[comp.IterVar()] = element.key
+			explanation = Explanation{Expression: comp.IterVar(), Value: formatValue(element.key)}
+		}
+
+		elementState, ok := x.eval(predicate, elementActivation)
+		if !ok {
+			return nil
+		}
+		result, found := elementState.Value(predicate.ID())
+		if !found {
+			return nil
+		}
+		explanation.Children = []Explanation{x.explain(predicate, elementState, elementActivation)}
+		explanations = append(explanations, explanation)
+		results = append(results, result)
+	}
+
+	// The elements that decided an all or exists are the ones whose predicate
+	// evaluated to the same value as the whole. An exists_one is false
+	// because of the elements that satisfy it when more than one does, and
+	// because of every element when none does.
+	want := val
+	if macro == "exists_one" {
+		want = types.Bool(val == types.True || slices.ContainsFunc(results, func(result ref.Val) bool { return result == types.True }))
+	}
+	var decided []Explanation
+	for i, result := range results {
+		if sameResult(result, want) {
+			decided = append(decided, explanations[i])
+		}
+	}
+	return decided
+}
+
+// quantifier returns the macro comp was expanded from, all, exists or
+// exists_one, and the predicate it tests each element with.
+func quantifier(comp celast.ComprehensionExpr) (string, celast.Expr, bool) {
+	step := comp.LoopStep()
+	if step.Kind() != celast.CallKind {
+		return "", nil, false
+	}
+	isAccu := func(e celast.Expr) bool {
+		return e.Kind() == celast.IdentKind && e.AsIdent() == comp.AccuVar()
+	}
+
+	call := step.AsCall()
+	args := call.Args()
+	switch call.FunctionName() {
+	case operators.LogicalAnd:
+		if len(args) == 2 && isAccu(args[0]) {
+			return "all", args[1], true
+		}
+	case operators.LogicalOr:
+		if len(args) == 2 && isAccu(args[0]) {
+			return "exists", args[1], true
+		}
+	case operators.Conditional:
+		if len(args) == 3 && isAccu(args[2]) {
+			return "exists_one", args[0], true
+		}
+	}
+	return "", nil, false
+}
+
+// element is an element of the range of a comprehension: a list element and
+// its index, or a map value and its key.
+type element struct {
+	index int
+	key   ref.Val
+	value ref.Val
+}
+
+// elementsOf returns the elements of a list or map, with map keys sorted so
+// explanations are stable.
+func elementsOf(val ref.Val) []element {
+	var elements []element
+	switch v := val.(type) {
+	case traits.Lister:
+		size, ok := v.Size().(types.Int)
+		if !ok {
+			return nil
+		}
+		for i := range int(size) {
+			elements = append(elements, element{index: i, value: v.Get(types.Int(i))})
+		}
+	case traits.Mapper:
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			elements = append(elements, element{key: key, value: v.Get(key)})
+		}
+		slices.SortFunc(elements, func(a, b element) int {
+			return strings.Compare(formatValue(a.key), formatValue(b.key))
+		})
+	}
+	return elements
+}
+
+// unparse returns the source of expr on one line.
+func (x *explainer) unparse(expr celast.Expr) string {
+	source, err := parser.Unparse(expr, x.ast.SourceInfo(), parser.WrapOnColumn(math.MaxInt))
+	if err != nil {
+		return fmt.Sprintf("<expression %d>", expr.ID())
+	}
+	return source
+}
+
+// formatValue formats val as JSON where it can be, truncated to
+// maxValueLength.
+func formatValue(val ref.Val) string {
+	var s string
+	if err, ok := val.(*types.Err); ok {
+		s = "error: " + err.String()
+	} else if native, err := val.ConvertToNative(reflect.TypeFor[*structpb.Value]()); err == nil {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(native.(*structpb.Value).AsInterface()); err == nil {
+			s = strings.TrimSuffix(buf.String(), "\n")
+		}
+	}
+	if s == "" {
+		s = fmt.Sprint(val.Value())
+	}
+
+	if len(s) <= maxValueLength {
+		return s
+	}
+	cut := maxValueLength
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
+}
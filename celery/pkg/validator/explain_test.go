@@ -0,0 +1,305 @@
+package validator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/google/cel-go/common/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// explanationLines returns e and its children one per line, indented by depth.
+func explanationLines(e *Explanation) []string {
+	if e == nil {
+		return nil
+	}
+	var lines []string
+	var walk func(e Explanation, indent string)
+	walk = func(e Explanation, indent string) {
+		lines = append(lines, indent+e.String())
+		for _, child := range e.Children {
+			walk(child, indent+"  ")
+		}
+	}
+	walk(*e, "")
+	return lines
+}
+
+func TestValidatorExplain(t *testing.T) {
+	deployment := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]any{
+			"name":   "web",
+			"labels": map[string]any{"app": "web", "team": ""},
+		},
+		"spec": map[string]any{
+			"replicas": int64(1),
+			"template": map[string]any{"spec": map[string]any{"containers": []any{
+				map[string]any{"name": "app", "image": "app@sha256:abc", "resources": map[string]any{"limits": map[string]any{"cpu": "1"}}},
+				map[string]any{"name": "sidecar", "image": "nginx:1.25"},
+				map[string]any{"name": "proxy", "image": "envoy@sha256:def", "resources": map[string]any{}},
+			}}},
+		},
+	}}
+
+	tests := []struct {
+		name       string
+		variables  []apiv1.Variable
+		expression string
+		expected   []string
+	}{
+		{
+			name:       "failing elements of all",
+			expression: `object.spec.template.spec.containers.all(c, has(c.resources.limits) && c.image.contains('@sha256'))`,
+			expected: []string{
+				`object.spec.template.spec.containers.all(c, has(c.resources.limits) && c.image.contains("@sha256")) = false`,
+				`  index 1: c = {"image":"nginx:1.25","name":"sidecar"}`,
+				`    has(c.resources.limits) && c.image.contains("@sha256") = false`,
+				`      c.image.contains("@sha256") = false`,
+				`        c.image = "nginx:1.25"`,
+				`  index 2: c = {"image":"envoy@sha256:def","name":"proxy","resources":{}}`,
+				`    has(c.resources.limits) && c.image.contains("@sha256") = false`,
+				`      has(c.resources.limits) = false`,
+				`        c.resources = {}`,
+			},
+		},
+		{
+			name:       "every operand of a false or",
+			expression: `object.spec.replicas >= 3 || object.metadata.name.startsWith('prod-')`,
+			expected: []string{
+				`object.spec.replicas >= 3 || object.metadata.name.startsWith("prod-") = false`,
+				`  object.spec.replicas >= 3 = false`,
+				`    object.spec.replicas = 1`,
+				`  object.metadata.name.startsWith("prod-") = false`,
+				`    object.metadata.name = "web"`,
+			},
+		},
+		{
+			name:       "only the false operands of a chain of ands",
+			expression: `object.metadata.name == 'web' && size(object.spec.template.spec.containers) < 3 && !has(object.spec.paused)`,
+			expected: []string{
+				`object.metadata.name == "web" && size(object.spec.template.spec.containers) < 3 && !has(object.spec.paused) = false`,
+				`  size(object.spec.template.spec.containers) < 3 = false`,
+				`    size(object.spec.template.spec.containers) = 3`,
+				`      object.spec.template.spec.containers = [{"image":"app@sha256:abc","name":"app","resources":{"limits":{"cpu":"1"}}},{"image":"nginx:1.25","name":"sidecar"},{"im...`,
+			},
+		},
+		{
+			name:       "map keys",
+			expression: `object.metadata.labels.all(k, object.metadata.labels[k] != '')`,
+			expected: []string{
+				`object.metadata.labels.all(k, object.metadata.labels[k] != "") = false`,
+				`  k = "team"`,
+				`    object.metadata.labels[k] != "" = false`,
+				`      object.metadata.labels[k] = ""`,
+			},
+		},
+		{
+			name:       "map entries",
+			expression: `object.metadata.labels.all(k, v, v != '')`,
+			expected: []string{
+				`object.metadata.labels.all(k, v, v != "") = false`,
+				`  key "team": v = ""`,
+				`    v != "" = false`,
+				`      v = ""`,
+			},
+		},
+		{
+			name:       "every element of exists",
+			expression: `object.spec.template.spec.containers.exists(c, c.name == 'istio-proxy')`,
+			expected: []string{
+				`object.spec.template.spec.containers.exists(c, c.name == "istio-proxy") = false`,
+				`  index 0: c = {"image":"app@sha256:abc","name":"app","resources":{"limits":{"cpu":"1"}}}`,
+				`    c.name == "istio-proxy" = false`,
+				`      c.name = "app"`,
+				`  index 1: c = {"image":"nginx:1.25","name":"sidecar"}`,
+				`    c.name == "istio-proxy" = false`,
+				`      c.name = "sidecar"`,
+				`  index 2: c = {"image":"envoy@sha256:def","name":"proxy","resources":{}}`,
+				`    c.name == "istio-proxy" = false`,
+				`      c.name = "proxy"`,
+			},
+		},
+		{
+			name:       "elements satisfying exists_one",
+			expression: `object.spec.template.spec.containers.exists_one(c, c.image.endsWith(':def') || c.name == 'app')`,
+			expected: []string{
+				`object.spec.template.spec.containers.exists_one(c, c.image.endsWith(":def") || c.name == "app") = false`,
+				`  index 0: c = {"image":"app@sha256:abc","name":"app","resources":{"limits":{"cpu":"1"}}}`,
+				`    c.image.endsWith(":def") || c.name == "app" = true`,
+				`      c.name == "app" = true`,
+				`        c.name = "app"`,
+				`  index 2: c = {"image":"envoy@sha256:def","name":"proxy","resources":{}}`,
+				`    c.image.endsWith(":def") || c.name == "app" = true`,
+				`      c.image.endsWith(":def") = true`,
+				`        c.image = "envoy@sha256:def"`,
+			},
+		},
+		{
+			name:       "nested comprehension over a variable",
+			variables:  []apiv1.Variable{{Name: "containers", Expression: "object.spec.template.spec.containers"}},
+			expression: `variables.containers.all(c, c.name == 'app' || variables.containers.exists(d, d.name == c.name + '-init'))`,
+			expected: []string{
+				`variables.containers.all(c, c.name == "app" || variables.containers.exists(d, d.name == c.name + "-init")) = false`,
+				`  index 1: c = {"image":"nginx:1.25","name":"sidecar"}`,
+				`    c.name == "app" || variables.containers.exists(d, d.name == c.name + "-init") = false`,
+				`      c.name == "app" = false`,
+				`        c.name = "sidecar"`,
+				`      variables.containers.exists(d, d.name == c.name + "-init") = false`,
+				`        index 0: d = {"image":"app@sha256:abc","name":"app","resources":{"limits":{"cpu":"1"}}}`,
+				`          d.name == c.name + "-init" = false`,
+				`            d.name = "app"`,
+				`            c.name + "-init" = "sidecar-init"`,
+				`              c.name = "sidecar"`,
+				`        index 1: d = {"image":"nginx:1.25","name":"sidecar"}`,
+				`          d.name == c.name + "-init" = false`,
+				`            d.name = "sidecar"`,
+				`            c.name + "-init" = "sidecar-init"`,
+				`              c.name = "sidecar"`,
+				`        index 2: d = {"image":"envoy@sha256:def","name":"proxy","resources":{}}`,
+				`          d.name == c.name + "-init" = false`,
+				`            d.name = "proxy"`,
+				`            c.name + "-init" = "sidecar-init"`,
+				`              c.name = "sidecar"`,
+				`  index 2: c = {"image":"envoy@sha256:def","name":"proxy","resources":{}}`,
+				`    c.name == "app" || variables.containers.exists(d, d.name == c.name + "-init") = false`,
+				`      c.name == "app" = false`,
+				`        c.name = "proxy"`,
+				`      variables.containers.exists(d, d.name == c.name + "-init") = false`,
+				`        index 0: d = {"image":"app@sha256:abc","name":"app","resources":{"limits":{"cpu":"1"}}}`,
+				`          d.name == c.name + "-init" = false`,
+				`            d.name = "app"`,
+				`            c.name + "-init" = "proxy-init"`,
+				`              c.name = "proxy"`,
+				`        index 1: d = {"image":"nginx:1.25","name":"sidecar"}`,
+				`          d.name == c.name + "-init" = false`,
+				`            d.name = "sidecar"`,
+				`            c.name + "-init" = "proxy-init"`,
+				`              c.name = "proxy"`,
+				`        index 2: d = {"image":"envoy@sha256:def","name":"proxy","resources":{}}`,
+				`          d.name == c.name + "-init" = false`,
+				`            d.name = "proxy"`,
+				`            c.name + "-init" = "proxy-init"`,
+				`              c.name = "proxy"`,
+			},
+		},
+		{
+			name:       "branch a conditional took",
+			expression: `object.spec.replicas > 1 ? has(object.spec.strategy) : object.metadata.name.endsWith('-singleton')`,
+			expected: []string{
+				`(object.spec.replicas > 1) ? has(object.spec.strategy) : object.metadata.name.endsWith("-singleton") = false`,
+				`  object.spec.replicas > 1 = false`,
+				`    object.spec.replicas = 1`,
+				`  object.metadata.name.endsWith("-singleton") = false`,
+				`    object.metadata.name = "web"`,
+			},
+		},
+		{
+			name:       "errors absorbed by a false and",
+			expression: `object.spec.template.spec.containers[1].resources.limits.cpu == '1' && false`,
+			expected: []string{
+				`object.spec.template.spec.containers[1].resources.limits.cpu == "1" && false = false`,
+				`  false = false`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Validator{Explain: true}
+			rules, err := v.CompileRules(limitRules(tt.variables, apiv1.ValidationRule{Name: "explained", Expression: tt.expression}))
+			require.NoError(t, err)
+
+			results := v.ValidateResources(context.Background(), "deployment.yaml", []*unstructured.Unstructured{deployment}, rules)
+			require.Len(t, results, 1)
+			assert.False(t, results[0].Valid)
+			assert.Equal(t, tt.expected, explanationLines(results[0].Explanation))
+		})
+	}
+}
+
+func TestValidatorExplainOnlyFalseResults(t *testing.T) {
+	resource := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "settings"},
+	}}
+
+	tests := []struct {
+		name       string
+		explain    bool
+		expression string
+		valid      bool
+	}{
+		{name: "passing rule", explain: true, expression: `object.metadata.name == 'settings'`, valid: true},
+		{name: "evaluation error", explain: true, expression: `object.data.missing == 'x'`},
+		{name: "not explaining", expression: `object.metadata.name == 'other'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Validator{Explain: tt.explain}
+			rules, err := v.CompileRules(limitRules(nil, apiv1.ValidationRule{Name: "rule", Expression: tt.expression}))
+			require.NoError(t, err)
+
+			results := v.ValidateResources(context.Background(), "settings.yaml", []*unstructured.Unstructured{resource}, rules)
+			require.Len(t, results, 1)
+			assert.Equal(t, tt.valid, results[0].Valid)
+			assert.Nil(t, results[0].Explanation)
+		})
+	}
+}
+
+func TestValidatorExplainCostBudget(t *testing.T) {
+	resource := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "settings", "finalizers": []any{"a", "b", ""}},
+	}}
+	validate := func(v *Validator) ValidationResult {
+		rules, err := v.CompileRules(limitRules(nil, apiv1.ValidationRule{Name: "rule", Expression: `object.metadata.finalizers.all(f, f != '')`}))
+		require.NoError(t, err)
+		results := v.ValidateResources(context.Background(), "settings.yaml", []*unstructured.Unstructured{resource}, rules)
+		require.Len(t, results, 1)
+		return results[0]
+	}
+
+	plain := &Validator{}
+	validate(plain)
+	explained := &Validator{Explain: true}
+	assert.NotNil(t, validate(explained).Explanation)
+	assert.Greater(t, explained.spent.Load(), 2*plain.spent.Load(), "explaining should be counted against the budget")
+
+	limited := &Validator{Explain: true, CostBudget: plain.spent.Load()}
+	result := validate(limited)
+	assert.False(t, result.Valid)
+	assert.False(t, result.LimitExceeded)
+	assert.Nil(t, result.Explanation, "nothing should be explained once the budget is spent")
+	assert.Equal(t, plain.spent.Load(), limited.spent.Load())
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{name: "string", value: "a<b", expected: `"a<b"`},
+		{name: "int", value: int64(3), expected: "3"},
+		{name: "null", value: nil, expected: "null"},
+		{name: "map", value: map[string]any{"b": true, "a": []any{"x"}}, expected: `{"a":["x"],"b":true}`},
+		{name: "truncated", value: strings.Repeat("é", 100), expected: `"` + strings.Repeat("é", 59) + "..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, formatValue(types.DefaultTypeAdapter.NativeToValue(tt.value)))
+		})
+	}
+	assert.Equal(t, "error: no such key: data", formatValue(types.NewErr("no such key: data")))
+}
@@ -0,0 +1,231 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/patch"
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Fix is the compiled fix of a rule. Exactly one of Patch and Program is set.
+type Fix struct {
+	// Patch are JSON patch operations applied to the resource.
+	Patch []apiv1.PatchOperation
+	// Program returns an object merged into the resource as a JSON merge patch.
+	Program cel.Program
+}
+
+// FixResult is a rule a resource failed, and whether its fix made it pass.
+type FixResult struct {
+	ValidationResult
+	// Fixed is set when the fix of the rule was applied and the rule passed
+	// after it, and Err is then the failure that was fixed. Otherwise Err says
+	// why the rule still fails.
+	Fixed bool
+}
+
+func compileFix(env *cel.Env, fix *apiv1.Fix, costLimit uint64) (*Fix, error) {
+	switch {
+	case len(fix.Patch) > 0 && fix.Expression != "":
+		return nil, errors.New("only one of patch and expression may be set")
+	case len(fix.Patch) > 0:
+		if err := patch.Validate(fix.Patch); err != nil {
+			return nil, err
+		}
+		return &Fix{Patch: fix.Patch}, nil
+	case fix.Expression != "":
+		ast, issues := env.Compile(fix.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("invalid expression: %s", issueMessage(issues))
+		}
+		if t := ast.OutputType(); t.Kind() != types.MapKind && !t.IsExactType(cel.DynType) {
+			return nil, fmt.Errorf("expression must return an object but returns %s", t)
+		}
+		prg, err := env.Program(ast, programOptions(costLimit)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile expression: %w", err)
+		}
+		return &Fix{Program: prg}, nil
+	default:
+		return nil, errors.New("one of patch or expression must be set")
+	}
+}
+
+// FixFile applies the fixes of rules to the resources of an input file, docs,
+// and returns the fixed object of each document along with a result for every
+// rule a resource failed. Rules with the all scope see global as allObjects
+// when it is set; other rules see the resources of the file. Rules are
+// selected by the labels of the Namespaces in global, or in docs when global
+// is nil.
+func (v *Validator) FixFile(ctx context.Context, inputName string, docs []yaml.Document, global []map[string]any, rules []Rule) ([]map[string]any, []FixResult) {
+	fileObjects := make([]map[string]any, 0, len(docs))
+	for _, doc := range docs {
+		fileObjects = append(fileObjects, doc.Resource.Object)
+	}
+	namespaces := namespacesOf(fileObjects)
+	if global != nil {
+		namespaces = namespacesOf(global)
+	}
+
+	var results []FixResult
+	fixed := make([]map[string]any, 0, len(docs))
+	for _, doc := range docs {
+		obj, docResults := v.fixResource(ctx, inputName, doc, fileObjects, global, namespaces, rules)
+		fixed = append(fixed, obj)
+		results = append(results, docResults...)
+	}
+	return fixed, results
+}
+
+// fixResource evaluates rules against doc in order and applies the fix of
+// every rule it fails, so later rules see the resource as changed by earlier
+// fixes. A fix is only kept when the rule passes after it. Transition rules
+// are not evaluated, as there is no previous version of the resource.
+//
+// It returns the fixed object, which is the object of doc when nothing was
+// fixed.
+func (v *Validator) fixResource(ctx context.Context, inputName string, doc yaml.Document, fileObjects []map[string]any, global []map[string]any, namespaces namespaceLabels, rules []Rule) (map[string]any, []FixResult) {
+	obj := doc.Resource.Object
+	skips := parseSkips(doc.Resource)
+
+	var results []FixResult
+	for _, rule := range rules {
+		resource := &unstructured.Unstructured{Object: obj}
+		if rule.Transition || !rule.Target.Matches(resource, namespaces) {
+			continue
+		}
+		if skips.matches(rule.Name) {
+			if result := skips.result(inputName, resource, rule); !result.Valid {
+				results = append(results, FixResult{ValidationResult: result})
+			}
+			continue
+		}
+
+		e := evaluation{
+			inputName:  inputName,
+			resource:   resource,
+			allObjects: fileObjects,
+			positions:  doc.Positions,
+			rule:       rule,
+		}
+		if rule.Scope == apiv1.ScopeAll && global != nil {
+			e.allObjects = global
+		}
+		if rule.Variables != nil {
+			e.variables = rule.Variables.newCache(&v.spent)
+		}
+
+		result := v.evaluate(ctx, e)
+		if result.Valid {
+			continue
+		}
+		if rule.Fix == nil || result.LimitExceeded {
+			results = append(results, FixResult{ValidationResult: result})
+			continue
+		}
+
+		fixed, err := v.applyFix(ctx, e)
+		if err != nil {
+			result.Err = fmt.Errorf("fix failed: %w", err)
+			results = append(results, FixResult{ValidationResult: result})
+			continue
+		}
+		e.resource = &unstructured.Unstructured{Object: fixed}
+		if rule.Variables != nil {
+			e.variables = rule.Variables.newCache(&v.spent)
+		}
+		if after := v.evaluate(ctx, e); !after.Valid {
+			result.Err = fmt.Errorf("rule still fails after its fix: %w", after.Err)
+			results = append(results, FixResult{ValidationResult: result})
+			continue
+		}
+
+		obj = fixed
+		results = append(results, FixResult{ValidationResult: result, Fixed: true})
+	}
+	return obj, results
+}
+
+// applyFix returns the object of e with the fix of its rule applied.
+func (v *Validator) applyFix(ctx context.Context, e evaluation) (map[string]any, error) {
+	fix := e.rule.Fix
+	if fix.Program == nil {
+		return patch.Apply(e.resource.Object, fix.Patch)
+	}
+
+	evalCtx, cancel := v.withTimeout(ctx)
+	defer cancel()
+
+	out, details, err := fix.Program.ContextEval(evalCtx, e.activation(evalCtx))
+	spend(&v.spent, details)
+	if err != nil {
+		if limitErr := v.limitError(evalCtx, err, e.rule.CostLimit); limitErr != nil {
+			return nil, limitErr
+		}
+		return nil, fmt.Errorf("evaluating fix expression: %w", err)
+	}
+
+	value, err := nativeValue(out)
+	if err != nil {
+		return nil, fmt.Errorf("fix expression: %w", err)
+	}
+	mergePatch, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("fix expression returned %s, not an object", out.Type().TypeName())
+	}
+	return patch.Merge(e.resource.Object, mergePatch)
+}
+
+// nativeValue converts a CEL value into an unstructured value.
+func nativeValue(val ref.Val) (any, error) {
+	switch v := val.(type) {
+	case types.Null:
+		return nil, nil
+	case types.Bool:
+		return bool(v), nil
+	case types.Int:
+		return int64(v), nil
+	case types.Uint:
+		return int64(v), nil
+	case types.Double:
+		return float64(v), nil
+	case types.String:
+		return string(v), nil
+	case traits.Mapper:
+		obj := make(map[string]any)
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			name, ok := key.(types.String)
+			if !ok {
+				return nil, fmt.Errorf("object keys must be strings, not %s", key.Type().TypeName())
+			}
+			field, err := nativeValue(v.Get(key))
+			if err != nil {
+				return nil, err
+			}
+			obj[string(name)] = field
+		}
+		return obj, nil
+	case traits.Lister:
+		size := int(v.Size().(types.Int))
+		list := make([]any, 0, size)
+		for i := range size {
+			item, err := nativeValue(v.Get(types.Int(i)))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("cannot use a value of type %s in a resource", val.Type().TypeName())
+	}
+}
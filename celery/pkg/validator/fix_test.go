@@ -0,0 +1,168 @@
+package validator
+
+import (
+	"context"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"github.com/google/cel-go/common/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileRulesInvalidFix(t *testing.T) {
+	tests := []struct {
+		name    string
+		fix     apiv1.Fix
+		wantErr string
+	}{
+		{
+			name:    "empty",
+			wantErr: "invalid fix in rule 'r' (limits.yaml): one of patch or expression must be set",
+		},
+		{
+			name:    "patch and expression",
+			fix:     apiv1.Fix{Patch: []apiv1.PatchOperation{{Op: "remove", Path: "/a"}}, Expression: "{}"},
+			wantErr: "invalid fix in rule 'r' (limits.yaml): only one of patch and expression may be set",
+		},
+		{
+			name:    "unknown op",
+			fix:     apiv1.Fix{Patch: []apiv1.PatchOperation{{Op: "delete", Path: "/a"}}},
+			wantErr: `invalid fix in rule 'r' (limits.yaml): patch operation 0: unknown op "delete"`,
+		},
+		{
+			name:    "expression not an object",
+			fix:     apiv1.Fix{Expression: "object.metadata.name == 'web'"},
+			wantErr: "invalid fix in rule 'r' (limits.yaml): expression must return an object but returns bool",
+		},
+		{
+			name:    "expression does not compile",
+			fix:     apiv1.Fix{Expression: "{'a': }"},
+			wantErr: "invalid fix in rule 'r' (limits.yaml): invalid expression",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Validator{}
+			_, err := v.CompileRules(limitRules(nil, apiv1.ValidationRule{Name: "r", Expression: "true", Fix: &tt.fix}))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestFixFile(t *testing.T) {
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  labels:
+    app: api
+  annotations:
+    celery.rrethy.io/skip: replicas
+    celery.rrethy.io/skip-reason: scaled by an autoscaler
+spec:
+  replicas: 1
+`
+	f, err := yaml.ParseFile([]byte(manifest))
+	require.NoError(t, err)
+
+	v := &Validator{}
+	rules, err := v.CompileRules(limitRules(
+		[]apiv1.Variable{{Name: "labels", Expression: "has(object.metadata.labels) ? object.metadata.labels : {}"}},
+		apiv1.ValidationRule{
+			Name:       "app-label",
+			Expression: "'app' in variables.labels",
+			Fix:        &apiv1.Fix{Expression: `{"metadata": {"labels": {"app": object.metadata.name}}}`},
+		},
+		apiv1.ValidationRule{
+			// Passes only because of the fix of app-label.
+			Name:       "app-label-matches",
+			Expression: "variables.labels.app == object.metadata.name",
+		},
+		apiv1.ValidationRule{
+			Name:       "replicas",
+			Expression: "object.spec.replicas >= 2",
+			Message:    "too few replicas",
+			Fix:        &apiv1.Fix{Patch: []apiv1.PatchOperation{{Op: "replace", Path: "/spec/replicas", Value: 2}}},
+		},
+		apiv1.ValidationRule{
+			Name:       "paused",
+			Expression: "has(object.spec.paused) && object.spec.paused",
+			Message:    "not paused",
+			Fix:        &apiv1.Fix{Expression: `{"spec": {"paused": false}}`},
+		},
+		apiv1.ValidationRule{
+			Name:       "bad-patch",
+			Expression: "has(object.spec.strategy)",
+			Fix:        &apiv1.Fix{Patch: []apiv1.PatchOperation{{Op: "add", Path: "/spec/strategy/type", Value: "Recreate"}}},
+		},
+		apiv1.ValidationRule{
+			Name:       "transition",
+			Expression: "false",
+			Transition: true,
+		},
+	))
+	require.NoError(t, err)
+
+	docs := f.Documents()
+	fixed, results := v.FixFile(context.Background(), "web.yaml", docs, nil, rules)
+	require.Len(t, fixed, 2)
+
+	assert.Equal(t, map[string]any{"app": "web"}, fixed[0]["metadata"].(map[string]any)["labels"])
+	assert.Equal(t, map[string]any{"replicas": int64(2)}, fixed[0]["spec"])
+	assert.Equal(t, map[string]any{"app": "api"}, fixed[1]["metadata"].(map[string]any)["labels"])
+	assert.Equal(t, int(1), fixed[1]["spec"].(map[string]any)["replicas"], "skipped rules should not be fixed")
+	assert.Equal(t, "web", docs[0].Resource.GetName())
+	assert.NotContains(t, docs[0].Resource.Object["metadata"], "labels", "the parsed documents should not change")
+
+	type outcome struct {
+		resource string
+		rule     string
+		fixed    bool
+		err      string
+	}
+	var got []outcome
+	for _, result := range results {
+		got = append(got, outcome{result.ResourceName, result.RuleName, result.Fixed, result.Err.Error()})
+	}
+	assert.Equal(t, []outcome{
+		{"web", "app-label", true, ""},
+		{"web", "replicas", true, "too few replicas"},
+		{"web", "paused", false, "rule still fails after its fix: not paused"},
+		{"web", "bad-patch", false, "fix failed: patch operation 0 (add /spec/strategy/type): add operation does not apply: doc is missing path: \"/spec/strategy/type\": missing value"},
+		{"api", "paused", false, "rule still fails after its fix: not paused"},
+		{"api", "bad-patch", false, "fix failed: patch operation 0 (add /spec/strategy/type): add operation does not apply: doc is missing path: \"/spec/strategy/type\": missing value"},
+	}, got)
+	assert.Equal(t, 6, results[1].Line, "results should point at the failing field")
+}
+
+func TestNativeValue(t *testing.T) {
+	v := &Validator{}
+	rules, err := v.CompileRules(limitRules(nil, apiv1.ValidationRule{
+		Name:       "r",
+		Expression: "false",
+		Fix:        &apiv1.Fix{Expression: `{"a": dyn([dyn(1), dyn(2u), dyn(1.5), dyn("s"), dyn(true), dyn(null)]), "b": dyn({"c": {}})}`},
+	}))
+	require.NoError(t, err)
+
+	out, _, err := rules[0].Fix.Program.Eval(map[string]any{})
+	require.NoError(t, err)
+	value, err := nativeValue(out)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"a": []any{int64(1), int64(2), 1.5, "s", true, nil},
+		"b": map[string]any{"c": map[string]any{}},
+	}, value)
+
+	_, err = nativeValue(types.Bytes("b"))
+	assert.EqualError(t, err, "cannot use a value of type bytes in a resource")
+}
@@ -0,0 +1,127 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/interpreter"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	"k8s.io/apiserver/pkg/cel/library"
+)
+
+// DefaultCostLimit is the cost a single evaluation of a rule may use unless the
+// rule or Validator sets a limit. It is the per expression limit the apiserver
+// enforces for ValidatingAdmissionPolicies, roughly 0.1s of CPU.
+const DefaultCostLimit uint64 = celconfig.PerCallLimit
+
+// ErrLimitExceeded is wrapped by the error of a result whose evaluation was
+// stopped by a cost limit, the cost budget or the timeout.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// errTimeout is the cause of the context of an evaluation that ran out of time.
+var errTimeout = errors.New("evaluation timed out")
+
+// variableError is the error of a variable that failed to evaluate. It is
+// kept as the cause so that rules using the variable can tell why it failed.
+type variableError struct {
+	name string
+	err  error
+	// cause is why the context of the evaluation was done, if it was.
+	cause error
+}
+
+func (e *variableError) Error() string {
+	return fmt.Sprintf("evaluating %s%s: %v", variablesPrefix, e.name, e.err)
+}
+
+func (e *variableError) Unwrap() error {
+	return e.err
+}
+
+// costLimit returns the limit of evaluations that do not set their own.
+func (v *Validator) costLimit() uint64 {
+	if v.CostLimit > 0 {
+		return v.CostLimit
+	}
+	return DefaultCostLimit
+}
+
+// ruleCostLimit returns the limit of a rule, which may raise or lower the
+// Validator's.
+func (v *Validator) ruleCostLimit(limit uint64) uint64 {
+	if limit > 0 {
+		return limit
+	}
+	return v.costLimit()
+}
+
+// programOptions limits the cost of every evaluation of a program to limit and
+// lets it be interrupted when its context is done.
+func programOptions(limit uint64) []cel.ProgramOption {
+	return []cel.ProgramOption{
+		cel.CostTracking(&library.CostEstimator{}),
+		cel.CostLimit(limit),
+		cel.InterruptCheckFrequency(celconfig.CheckFrequency),
+	}
+}
+
+// estimateCost estimates the cost of evaluating ast. Sizes of the lists, maps
+// and strings in resources are unknown when rules are compiled, so only the
+// minimum is useful to reject a rule that will always exceed its limit.
+func estimateCost(env *cel.Env, ast *cel.Ast) (checker.CostEstimate, error) {
+	return env.EstimateCost(ast, &library.CostEstimator{})
+}
+
+// budgetSpent reports whether every evaluation together has used the cost
+// budget. Evaluations already running when it is spent run to completion, so
+// the budget can be exceeded by up to the cost limit of each of them.
+func (v *Validator) budgetSpent() bool {
+	return v.CostBudget > 0 && v.spent.Load() >= v.CostBudget
+}
+
+// spend adds the cost of an evaluation to the total checked against the budget.
+func spend(spent *atomic.Uint64, details *cel.EvalDetails) {
+	if details == nil || details.ActualCost() == nil {
+		return
+	}
+	spent.Add(*details.ActualCost())
+}
+
+// withTimeout returns the context of a single evaluation.
+func (v *Validator) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if v.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, v.Timeout, errTimeout)
+}
+
+// limitError returns the error of a rule evaluation that was stopped by its
+// cost limit or the timeout, or nil if err has another cause. A variable the
+// rule uses may have been stopped while evaluated for another rule.
+func (v *Validator) limitError(evalCtx context.Context, err error, limit uint64) error {
+	what := "rule"
+	cause := context.Cause(evalCtx)
+	var varErr *variableError
+	if errors.As(err, &varErr) {
+		what = variablesPrefix + varErr.name
+		limit = v.costLimit()
+		cause = varErr.cause
+	}
+
+	var cancelled interpreter.EvalCancelledError
+	if errors.As(err, &cancelled) && cancelled.Cause == interpreter.CostLimitExceeded {
+		return fmt.Errorf("%w: cost of %s exceeds the limit of %d", ErrLimitExceeded, what, limit)
+	}
+	if errors.Is(cause, errTimeout) {
+		return fmt.Errorf("%w: evaluating %s took longer than %s", ErrLimitExceeded, what, v.Timeout)
+	}
+	return nil
+}
+
+func (v *Validator) budgetError() error {
+	return fmt.Errorf("%w: cost budget of %d is spent, rule not evaluated", ErrLimitExceeded, v.CostBudget)
+}
@@ -0,0 +1,167 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// quadraticRule compares every pair of resources, which is expensive on large
+// inputs.
+const quadraticRule = "allObjects.all(a, allObjects.all(b, a.metadata.name == b.metadata.name || a.kind == b.kind))"
+
+func configMaps(n int) []*unstructured.Unstructured {
+	resources := make([]*unstructured.Unstructured, 0, n)
+	for i := range n {
+		resources = append(resources, &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]any{"name": fmt.Sprintf("config-%d", i)},
+		}})
+	}
+	return resources
+}
+
+func limitRules(variables []apiv1.Variable, rules ...apiv1.ValidationRule) []apiv1.ValidationRules {
+	return []apiv1.ValidationRules{{
+		Filename:   "limits.yaml",
+		ObjectMeta: metav1.ObjectMeta{Name: "limits"},
+		Spec:       apiv1.ValidationRulesSpec{Variables: variables, Rules: rules},
+	}}
+}
+
+func TestValidatorCostLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		validator   *Validator
+		variables   []apiv1.Variable
+		rule        apiv1.ValidationRule
+		wantLimit   bool
+		wantMessage string
+	}{
+		{
+			name:      "within default limit",
+			validator: &Validator{},
+			rule:      apiv1.ValidationRule{Name: "pairs", Expression: quadraticRule},
+		},
+		{
+			name:        "over validator limit",
+			validator:   &Validator{CostLimit: 1000},
+			rule:        apiv1.ValidationRule{Name: "pairs", Expression: quadraticRule},
+			wantLimit:   true,
+			wantMessage: "limit exceeded: cost of rule exceeds the limit of 1000",
+		},
+		{
+			name:        "over rule limit",
+			validator:   &Validator{},
+			rule:        apiv1.ValidationRule{Name: "pairs", Expression: quadraticRule, CostLimit: 500},
+			wantLimit:   true,
+			wantMessage: "limit exceeded: cost of rule exceeds the limit of 500",
+		},
+		{
+			name:      "rule limit raises validator limit",
+			validator: &Validator{CostLimit: 1000},
+			rule:      apiv1.ValidationRule{Name: "pairs", Expression: quadraticRule, CostLimit: DefaultCostLimit},
+		},
+		{
+			name:        "over limit in variable",
+			validator:   &Validator{CostLimit: 1000},
+			variables:   []apiv1.Variable{{Name: "unique", Expression: quadraticRule}},
+			rule:        apiv1.ValidationRule{Name: "pairs", Expression: "variables.unique", CostLimit: DefaultCostLimit},
+			wantLimit:   true,
+			wantMessage: "limit exceeded: cost of variables.unique exceeds the limit of 1000",
+		},
+		{
+			name:        "over timeout",
+			validator:   &Validator{Timeout: time.Nanosecond},
+			rule:        apiv1.ValidationRule{Name: "pairs", Expression: quadraticRule},
+			wantLimit:   true,
+			wantMessage: "limit exceeded: evaluating rule took longer than 1ns",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := tt.validator.CompileRules(limitRules(tt.variables, tt.rule))
+			require.NoError(t, err)
+
+			results := tt.validator.ValidateResources(context.Background(), "input.yaml", configMaps(50), rules)
+			require.Len(t, results, 50)
+			for _, result := range results {
+				assert.Equal(t, tt.wantLimit, result.LimitExceeded)
+				if !tt.wantLimit {
+					assert.True(t, result.Valid)
+					continue
+				}
+				assert.False(t, result.Valid)
+				assert.ErrorIs(t, result.Err, ErrLimitExceeded)
+				assert.EqualError(t, result.Err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestValidatorCostBudget(t *testing.T) {
+	ctx := context.Background()
+	v := &Validator{CostBudget: 10000}
+	rules, err := v.CompileRules(limitRules(nil, apiv1.ValidationRule{Name: "pairs", Expression: quadraticRule}))
+	require.NoError(t, err)
+
+	// Evaluations run concurrently, so some may start before the budget is
+	// spent, but none can start after.
+	v.ValidateResources(ctx, "first.yaml", configMaps(20), rules)
+
+	results := v.ValidateResources(ctx, "second.yaml", configMaps(5), rules)
+	require.Len(t, results, 5)
+	for _, result := range results {
+		assert.True(t, result.LimitExceeded)
+		assert.False(t, result.Valid)
+		assert.EqualError(t, result.Err, "limit exceeded: cost budget of 10000 is spent, rule not evaluated")
+	}
+}
+
+func TestValidatorCostBudgetMessageExpression(t *testing.T) {
+	spent := func(rule apiv1.ValidationRule) uint64 {
+		v := &Validator{}
+		rules, err := v.CompileRules(limitRules(nil, rule))
+		require.NoError(t, err)
+		results := v.ValidateResources(context.Background(), "configmaps.yaml", configMaps(1), rules)
+		require.Len(t, results, 1)
+		require.False(t, results[0].Valid)
+		return v.spent.Load()
+	}
+
+	withMessage := spent(apiv1.ValidationRule{Name: "rule", Expression: "object.kind == 'Secret'", Message: "not a Secret"})
+	withMessageExpression := spent(apiv1.ValidationRule{
+		Name:              "rule",
+		Expression:        "object.kind == 'Secret'",
+		MessageExpression: "object.metadata.name + ' is a ' + object.kind + ', not a Secret'",
+	})
+	assert.Greater(t, withMessageExpression, withMessage, "messageExpression should be counted against the budget")
+}
+
+func TestCompileRulesEstimatedCost(t *testing.T) {
+	v := &Validator{}
+
+	rules, err := v.CompileRules(limitRules(nil, apiv1.ValidationRule{Name: "pairs", Expression: quadraticRule}))
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, DefaultCostLimit, rules[0].CostLimit)
+	assert.Greater(t, rules[0].EstimatedCost.Max, rules[0].CostLimit, "cost of iterating unknown lists is unbounded")
+
+	_, err = v.CompileRules(limitRules(nil, apiv1.ValidationRule{
+		Name:       "products",
+		Expression: "[1, 2, 3, 4, 5, 6, 7, 8, 9, 10].map(x, [1, 2, 3, 4, 5, 6, 7, 8, 9, 10].map(y, x * y)).size() == 10",
+		CostLimit:  100,
+	}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rule 'products' (limits.yaml) costs at least")
+	assert.Contains(t, err.Error(), "more than its cost limit of 100")
+}
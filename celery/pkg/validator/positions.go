@@ -0,0 +1,119 @@
+package validator
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+)
+
+// objectRoots are the identifiers field paths start from when no variables
+// are declared.
+var objectRoots = map[string][]string{"object": nil}
+
+// fieldPaths returns the path of every field of object that ast selects, e.g.
+// spec, replicas for object.spec.replicas. roots maps identifiers to the path
+// of object they stand for, so that fields selected through variables are
+// found too.
+func fieldPaths(ast *cel.Ast, roots map[string][]string) [][]string {
+	var paths [][]string
+	celast.PreOrderVisit(ast.NativeRep().Expr(), celast.NewExprVisitor(func(e celast.Expr) {
+		if path, ok := selectPath(e, roots); ok && len(path) > 0 {
+			paths = append(paths, path)
+		}
+	}))
+	return paths
+}
+
+// selectPath returns the path of object e selects, following field selections
+// and indexes with constant keys back to one of roots.
+func selectPath(e celast.Expr, roots map[string][]string) ([]string, bool) {
+	switch e.Kind() {
+	case celast.IdentKind:
+		root, ok := roots[e.AsIdent()]
+		return slices.Clone(root), ok
+	case celast.SelectKind:
+		sel := e.AsSelect()
+		// Unchecked variables.<name> is a selection from variables.
+		if operand := sel.Operand(); operand.Kind() == celast.IdentKind {
+			if root, ok := roots[operand.AsIdent()+"."+sel.FieldName()]; ok {
+				return slices.Clone(root), true
+			}
+		}
+		path, ok := selectPath(sel.Operand(), roots)
+		return append(path, sel.FieldName()), ok
+	case celast.CallKind:
+		call := e.AsCall()
+		switch call.FunctionName() {
+		case operators.Index, operators.OptIndex, operators.OptSelect:
+			args := call.Args()
+			key, ok := constantKey(args[1])
+			if !ok {
+				return nil, false
+			}
+			path, ok := selectPath(args[0], roots)
+			return append(path, key), ok
+		}
+	}
+	return nil, false
+}
+
+// constantKey returns the field name or list index a constant index stands for.
+func constantKey(e celast.Expr) (string, bool) {
+	if e.Kind() != celast.LiteralKind {
+		return "", false
+	}
+	switch key := e.AsLiteral().(type) {
+	case types.String:
+		return string(key), true
+	case types.Int:
+		return strconv.FormatInt(int64(key), 10), true
+	case types.Uint:
+		return strconv.FormatUint(uint64(key), 10), true
+	}
+	return "", false
+}
+
+// position returns where the resource of e is in its input, or, when the rule
+// failed, the deepest field the rule selects that the resource has. Rules that
+// select several fields to the same depth point at the first of them.
+func (e evaluation) position(failed bool) yaml.Position {
+	position, depth := e.positions.Field(nil)
+	if !failed {
+		return position
+	}
+	for _, path := range e.rule.Fields {
+		if fieldPosition, fieldDepth := e.positions.Field(path); fieldDepth > depth {
+			position, depth = fieldPosition, fieldDepth
+		}
+	}
+	return position
+}
+
+// at records where in its input a result points.
+func (r *ValidationResult) at(position yaml.Position) {
+	r.Line = position.Line
+	r.Column = position.Column
+}
+
+// Location returns the input of the result with the line and column it points
+// at when they are known, as file:line:column.
+func (r ValidationResult) Location() string {
+	if r.Line == 0 {
+		return r.InputFile
+	}
+	return r.InputFile + ":" + strconv.Itoa(r.Line) + ":" + strconv.Itoa(r.Column)
+}
+
+// LocationSuffix returns the Location of the result in parentheses, to end a
+// line of text output with, or nothing when its line is not known.
+func (r ValidationResult) LocationSuffix() string {
+	if r.Line == 0 {
+		return ""
+	}
+	return " (" + r.Location() + ")"
+}
@@ -0,0 +1,119 @@
+package validator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileRulesFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		variables  []apiv1.Variable
+		expression string
+		want       [][]string
+	}{
+		{
+			name:       "field selection",
+			expression: "object.spec.replicas >= 3",
+			want:       [][]string{{"spec", "replicas"}, {"spec"}},
+		},
+		{
+			name:       "presence test",
+			expression: "has(object.metadata.labels)",
+			want:       [][]string{{"metadata", "labels"}, {"metadata"}},
+		},
+		{
+			name:       "constant indexes",
+			expression: "object.spec.containers[0]['image'] != ''",
+			want:       [][]string{{"spec", "containers", "0", "image"}, {"spec", "containers", "0"}, {"spec", "containers"}, {"spec"}},
+		},
+		{
+			name:       "optional selection",
+			expression: "object.?metadata.?labels.orValue({}).size() > 0",
+			want:       [][]string{{"metadata", "labels"}, {"metadata"}},
+		},
+		{
+			name:       "variable index is not followed",
+			expression: "object.spec.containers[size(object.spec.containers) - 1].image != ''",
+			want:       [][]string{{"spec", "containers"}, {"spec"}, {"spec", "containers"}, {"spec"}},
+		},
+		{
+			name:       "through a variable",
+			variables:  []apiv1.Variable{{Name: "spec", Expression: "object.spec"}},
+			expression: "variables.spec.replicas >= 3",
+			want:       [][]string{{"spec", "replicas"}, {"spec"}},
+		},
+		{
+			name:       "other roots are ignored",
+			expression: "allObjects.all(o, o.kind != '') && oldObject == null",
+			want:       nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Validator{}
+			rules, err := v.CompileRules(limitRules(tt.variables, apiv1.ValidationRule{Name: "fields", Expression: tt.expression}))
+			require.NoError(t, err)
+			require.Len(t, rules, 1)
+			assert.Equal(t, tt.want, rules[0].Fields)
+		})
+	}
+}
+
+func TestValidatePositions(t *testing.T) {
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    celery.rrethy.io/skip: skipped
+    celery.rrethy.io/skip-reason: not applicable
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+        - name: web
+          image: web:latest
+`
+	file := filepath.Join(t.TempDir(), "web.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(manifest), 0o644))
+
+	v := &Validator{}
+	results, err := v.Validate(context.Background(), []string{file}, limitRules(
+		[]apiv1.Variable{{Name: "containers", Expression: "object.spec.template.spec.containers"}},
+		apiv1.ValidationRule{Name: "replicas", Expression: "object.spec.replicas >= 3"},
+		apiv1.ValidationRule{Name: "labels", Expression: "has(object.metadata.labels)"},
+		apiv1.ValidationRule{Name: "image", Expression: "!variables.containers[0].image.endsWith(':latest')"},
+		apiv1.ValidationRule{Name: "passes", Expression: "object.spec.replicas > 0"},
+		apiv1.ValidationRule{Name: "skipped", Expression: "false"},
+	))
+	require.NoError(t, err)
+
+	got := make(map[string]string)
+	for _, result := range results {
+		assert.Equal(t, file, result.InputFile)
+		got[result.RuleName] = result.Location()
+	}
+	assert.Equal(t, map[string]string{
+		"replicas": file + ":9:3",
+		"labels":   file + ":3:1",
+		"image":    file + ":14:11",
+		"passes":   file + ":1:1",
+		"skipped":  file + ":1:1",
+	}, got)
+}
+
+func TestValidationResultLocation(t *testing.T) {
+	assert.Equal(t, "a.yaml", ValidationResult{InputFile: "a.yaml"}.Location())
+	assert.Equal(t, "a.yaml:3:5", ValidationResult{InputFile: "a.yaml", Line: 3, Column: 5}.Location())
+	assert.Empty(t, ValidationResult{InputFile: "a.yaml"}.LocationSuffix())
+	assert.Equal(t, " (a.yaml:3:5)", ValidationResult{InputFile: "a.yaml", Line: 3, Column: 5}.LocationSuffix())
+}
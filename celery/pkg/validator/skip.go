@@ -0,0 +1,55 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// skips are the rules a resource opted out of with the skip annotation.
+type skips struct {
+	all    bool
+	rules  map[string]bool
+	reason string
+}
+
+func parseSkips(resource *unstructured.Unstructured) skips {
+	annotations := resource.GetAnnotations()
+	s := skips{
+		rules:  make(map[string]bool),
+		reason: strings.TrimSpace(annotations[apiv1.SkipReasonAnnotation]),
+	}
+	for _, name := range strings.Split(annotations[apiv1.SkipAnnotation], ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+		case "*":
+			s.all = true
+		default:
+			s.rules[name] = true
+		}
+	}
+	return s
+}
+
+func (s skips) matches(rule string) bool {
+	return s.all || s.rules[rule]
+}
+
+// result reports a skipped rule. A skip without a reason is not honoured: the
+// rule fails with an error explaining the missing justification instead.
+func (s skips) result(inputName string, resource *unstructured.Unstructured, rule Rule) ValidationResult {
+	result := newResult(inputName, resource, rule)
+	if s.reason == "" {
+		result.Severity = apiv1.SeverityError
+		result.Err = fmt.Errorf("skipping rule requires a %s annotation", apiv1.SkipReasonAnnotation)
+		return result
+	}
+
+	result.Valid = true
+	result.Skipped = true
+	result.SkipReason = s.reason
+	return result
+}
@@ -0,0 +1,338 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"sync"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/input"
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// evaluation is a rule to evaluate against one resource.
+type evaluation struct {
+	inputName  string
+	resource   *unstructured.Unstructured
+	oldObject  any
+	allObjects []map[string]any
+	positions  *yaml.Positions
+	rule       Rule
+	variables  *variableCache
+}
+
+// inputFile is the resources read from one input.
+type inputFile struct {
+	name      string
+	resources []*unstructured.Unstructured
+	// oldObjects and positions are either nil or parallel to resources.
+	oldObjects []*unstructured.Unstructured
+	positions  []*yaml.Positions
+}
+
+func newInputFile(name string, docs []yaml.Document) inputFile {
+	file := inputFile{name: name}
+	for _, doc := range docs {
+		file.resources = append(file.resources, doc.Resource)
+		file.positions = append(file.positions, doc.Positions)
+	}
+	return file
+}
+
+// Stream evaluates rules against inputFiles and sends every result on the
+// returned channel as soon as it is produced, closing it when done. Results
+// are not ordered. At most MaxWorkers files are read and MaxWorkers rules
+// evaluated at once, so memory use is bounded by the files in flight rather
+// than by the number of results. The channel must be drained.
+//
+// When any rule has the all scope or selects resources by the labels of their
+// namespace, every file is read before anything is evaluated, since each
+// resource may be compared with any other and Namespaces may be in any file.
+func (v *Validator) Stream(ctx context.Context, inputFiles []string, rules []Rule) <-chan ValidationResult {
+	return v.run(ctx, func(jobs chan<- evaluation, results chan<- ValidationResult) {
+		if !needsGlobal(rules) {
+			v.readFiles(inputFiles, results, func(_ int, file inputFile) {
+				v.enqueue(ctx, file, nil, rules, jobs, results)
+			})
+			return
+		}
+
+		files := make([]inputFile, len(inputFiles))
+		v.readFiles(inputFiles, results, func(i int, file inputFile) {
+			files[i] = file
+		})
+		var resources []*unstructured.Unstructured
+		for _, file := range files {
+			resources = append(resources, file.resources...)
+		}
+		global := newGlobalInputs(allObjectsOf(resources))
+		for _, file := range files {
+			v.enqueue(ctx, file, global, rules, jobs, results)
+		}
+	})
+}
+
+// run evaluates the jobs produce sends on MaxWorkers workers. Results of the
+// jobs and any produce sends itself are sent on the returned channel, which is
+// closed once produce returns and every job is evaluated.
+func (v *Validator) run(ctx context.Context, produce func(jobs chan<- evaluation, results chan<- ValidationResult)) <-chan ValidationResult {
+	jobs := make(chan evaluation)
+	results := make(chan ValidationResult, v.workers())
+	workers := v.startWorkers(ctx, jobs, results)
+
+	go func() {
+		produce(jobs, results)
+		close(jobs)
+		workers.Wait()
+		close(results)
+	}()
+	return results
+}
+
+func collect(results <-chan ValidationResult) []ValidationResult {
+	var collected []ValidationResult
+	for result := range results {
+		collected = append(collected, result)
+	}
+	return collected
+}
+
+// readFiles reads inputFiles on up to MaxWorkers goroutines, calling handle
+// with the index and contents of each. Files that cannot be read are reported
+// on results instead.
+func (v *Validator) readFiles(inputFiles []string, results chan<- ValidationResult, handle func(i int, file inputFile)) {
+	indexes := make(chan int)
+	var readers sync.WaitGroup
+	for range min(v.workers(), len(inputFiles)) {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for i := range indexes {
+				docs, err := input.ReadDocuments(inputFiles[i], v.Stdin)
+				if err != nil {
+					results <- readErrorResult(inputFiles[i], err)
+					continue
+				}
+				handle(i, newInputFile(input.Name(inputFiles[i]), docs))
+			}
+		}()
+	}
+
+	for i := range inputFiles {
+		indexes <- i
+	}
+	close(indexes)
+	readers.Wait()
+}
+
+// workers returns how many evaluations may run at once.
+func (v *Validator) workers() int {
+	if v.MaxWorkers > 0 {
+		return v.MaxWorkers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// startWorkers starts the workers evaluating jobs, sending their results to
+// results, until jobs is closed. The returned WaitGroup is done once every
+// worker has stopped.
+func (v *Validator) startWorkers(ctx context.Context, jobs <-chan evaluation, results chan<- ValidationResult) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for range v.workers() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				results <- v.evaluate(ctx, job)
+			}
+		}()
+	}
+	return &wg
+}
+
+// globalInputs is what rules see of every input rather than of one file.
+type globalInputs struct {
+	// allObjects are the objects of every input, which rules with the all
+	// scope see as allObjects.
+	allObjects []map[string]any
+	// namespaces are the labels of every Namespace among the inputs.
+	namespaces namespaceLabels
+}
+
+func newGlobalInputs(allObjects []map[string]any) *globalInputs {
+	return &globalInputs{allObjects: allObjects, namespaces: namespacesOf(allObjects)}
+}
+
+// needsGlobal reports whether any of rules needs to see every input.
+func needsGlobal(rules []Rule) bool {
+	return slices.ContainsFunc(rules, func(rule Rule) bool {
+		return rule.Scope == apiv1.ScopeAll || rule.Target.selectsNamespaces()
+	})
+}
+
+func allObjectsOf(resources []*unstructured.Unstructured) []map[string]any {
+	allObjects := make([]map[string]any, 0, len(resources))
+	for _, resource := range resources {
+		allObjects = append(allObjects, resource.Object)
+	}
+	return allObjects
+}
+
+// enqueue sends the evaluation of every rule matching each resource of file to
+// jobs, and the results of rules a resource skips to results. Rules with the
+// all scope see the objects of global as allObjects when it is set; other
+// rules see the resources of file. Rules are selected by the labels of the
+// Namespaces in global, or in file when global is nil, and of those
+// NamespaceLabels looks up.
+func (v *Validator) enqueue(ctx context.Context, file inputFile, global *globalInputs, rules []Rule, jobs chan<- evaluation, results chan<- ValidationResult) {
+	allObjects := allObjectsOf(file.resources)
+	namespaces := namespacesOf(allObjects)
+	if global != nil {
+		namespaces = global.namespaces
+	}
+	lookup := v.NamespaceLabels != nil && slices.ContainsFunc(rules, func(rule Rule) bool { return rule.Target.selectsNamespaces() })
+	if lookup {
+		namespaces = maps.Clone(namespaces)
+	}
+
+	for i, resource := range file.resources {
+		var oldObject any
+		if i < len(file.oldObjects) && file.oldObjects[i] != nil {
+			oldObject = file.oldObjects[i].Object
+		}
+		var positions *yaml.Positions
+		if i < len(file.positions) {
+			positions = file.positions[i]
+		}
+
+		var lookupErr error
+		if lookup {
+			lookupErr = v.lookupNamespace(ctx, namespaces, resource.GetNamespace())
+		}
+
+		skips := parseSkips(resource)
+
+		// Variables are evaluated lazily and cached per resource, shared by every
+		// rule from the same ValidationRules that sees the same allObjects.
+		type cacheKey struct {
+			variables *VariableSet
+			scope     apiv1.Scope
+		}
+		caches := make(map[cacheKey]*variableCache)
+		for _, rule := range rules {
+			if lookupErr != nil && rule.Target.dependsOnNamespace(resource) {
+				result := newResult(file.name, resource, rule)
+				result.Err = lookupErr
+				position, _ := positions.Field(nil)
+				result.at(position)
+				results <- result
+				continue
+			}
+			if !rule.Target.Matches(resource, namespaces) || (rule.Transition && oldObject == nil) {
+				continue
+			}
+			if skips.matches(rule.Name) {
+				result := skips.result(file.name, resource, rule)
+				position, _ := positions.Field(nil)
+				result.at(position)
+				results <- result
+				continue
+			}
+			e := evaluation{
+				inputName:  file.name,
+				resource:   resource,
+				oldObject:  oldObject,
+				allObjects: allObjects,
+				positions:  positions,
+				rule:       rule,
+			}
+			key := cacheKey{variables: rule.Variables, scope: apiv1.ScopeFile}
+			if rule.Scope == apiv1.ScopeAll && global != nil {
+				e.allObjects = global.allObjects
+				key.scope = apiv1.ScopeAll
+			}
+			if rule.Variables != nil {
+				if caches[key] == nil {
+					caches[key] = rule.Variables.newCache(&v.spent)
+				}
+				e.variables = caches[key]
+			}
+			jobs <- e
+		}
+	}
+}
+
+// lookupNamespace adds the labels of the Namespace name to namespaces with
+// NamespaceLabels, unless they are already known.
+func (v *Validator) lookupNamespace(ctx context.Context, namespaces namespaceLabels, name string) error {
+	if _, ok := namespaces[name]; ok || name == "" {
+		return nil
+	}
+	found, err := v.NamespaceLabels(ctx, name)
+	if err != nil {
+		return fmt.Errorf("looking up the labels of namespace %s: %w", name, err)
+	}
+	namespaces[name] = labels.Set(found)
+	return nil
+}
+
+// activation binds the variables e is evaluated with.
+func (e evaluation) activation(ctx context.Context) map[string]any {
+	activation := map[string]any{
+		"object":     e.resource.Object,
+		"oldObject":  e.oldObject,
+		"allObjects": e.allObjects,
+		"params":     e.rule.Params,
+	}
+	e.variables.bind(ctx, activation)
+	return activation
+}
+
+// evaluate evaluates one rule against one resource.
+func (v *Validator) evaluate(ctx context.Context, e evaluation) ValidationResult {
+	result := newResult(e.inputName, e.resource, e.rule)
+	if v.budgetSpent() {
+		result.Valid = false
+		result.LimitExceeded = true
+		result.Err = v.budgetError()
+		result.at(e.position(false))
+		return result
+	}
+
+	evalCtx, cancel := v.withTimeout(ctx)
+	defer cancel()
+
+	activation := e.activation(evalCtx)
+	out, details, err := e.rule.Program.ContextEval(evalCtx, activation)
+	spend(&v.spent, details)
+	if err != nil {
+		result.Valid = false
+		if limitErr := v.limitError(evalCtx, err, e.rule.CostLimit); limitErr != nil {
+			result.LimitExceeded = true
+			result.Err = limitErr
+		} else {
+			result.Err = fmt.Errorf("evaluating rule: %w", err)
+		}
+	} else if out == nil || out.Type() != cel.BoolType {
+		result.Valid = false
+		result.Err = errors.New("expression did not return a boolean")
+	} else if out.Value().(bool) {
+		result.Valid = true
+		result.Err = nil
+	} else {
+		result.Valid = false
+		result.Err = errors.New(e.rule.failureMessage(evalCtx, activation, &v.spent))
+		if v.Explain {
+			result.Explanation = v.explain(evalCtx, e, activation)
+		}
+	}
+	result.at(e.position(!result.Valid))
+	return result
+}
@@ -0,0 +1,163 @@
+package validator
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/input"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	inputFiles, err := filepath.Glob(filepath.Join("..", "..", "fixtures", "resources", "*.yaml"))
+	require.NoError(t, err)
+
+	resourceCount := 0
+	for _, file := range inputFiles {
+		resources, err := input.ReadResources(file, nil)
+		require.NoError(t, err)
+		resourceCount += len(resources)
+	}
+	missing := filepath.Join("..", "..", "fixtures", "resources", "missing.yaml")
+	inputFiles = append(inputFiles, missing)
+
+	ruless := []apiv1.ValidationRules{{
+		Filename:   "stream.yaml",
+		ObjectMeta: metav1.ObjectMeta{Name: "stream"},
+		Spec: apiv1.ValidationRulesSpec{
+			Rules: []apiv1.ValidationRule{
+				{Name: "has-metadata", Expression: "has(object.metadata)"},
+				{Name: "named", Expression: "object.metadata.name != ''"},
+			},
+		},
+	}}
+
+	for _, workers := range []int{0, 1, 3, 128} {
+		v := &Validator{MaxWorkers: workers}
+		rules, err := v.CompileRules(ruless)
+		require.NoError(t, err)
+
+		var results []ValidationResult
+		for result := range v.Stream(ctx, inputFiles, rules) {
+			results = append(results, result)
+		}
+
+		require.Len(t, results, 2*resourceCount+1, "workers=%d", workers)
+		failures := 0
+		for _, result := range results {
+			if !result.Valid {
+				failures++
+				assert.Equal(t, "missing.yaml", filepath.Base(result.InputFile), "workers=%d", workers)
+				assert.ErrorContains(t, result.Err, "reading resources from file")
+			}
+		}
+		assert.Equal(t, 1, failures, "workers=%d", workers)
+	}
+}
+
+func TestStreamNoFiles(t *testing.T) {
+	v := &Validator{MaxWorkers: 4}
+	rules, err := v.CompileRules(limitRules(nil, apiv1.ValidationRule{Name: "always", Expression: "true"}))
+	require.NoError(t, err)
+
+	count := 0
+	for range v.Stream(context.Background(), nil, rules) {
+		count++
+	}
+	assert.Zero(t, count)
+}
+
+func TestStreamScope(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join("..", "..", "fixtures", "cross-file")
+	inputFiles := []string{filepath.Join(dir, "deployments.yaml"), filepath.Join(dir, "services.yaml")}
+
+	selects := apiv1.ValidationRule{
+		Name:       "selects",
+		Expression: "allObjects.exists(d, d.kind == 'Deployment' && d.spec.template.metadata.labels.app == object.spec.selector.app)",
+		Target:     &apiv1.TargetSelector{Kind: "Service"},
+	}
+	failures := func(v *Validator, rule apiv1.ValidationRule) map[string]string {
+		rules, err := v.CompileRules(limitRules(nil, rule))
+		require.NoError(t, err)
+		failed := make(map[string]string)
+		for result := range v.Stream(ctx, inputFiles, rules) {
+			assert.Equal(t, "services.yaml", filepath.Base(result.InputFile), "results should name the file of their resource")
+			if !result.Valid {
+				failed[result.ResourceName] = filepath.Base(result.InputFile)
+			}
+		}
+		return failed
+	}
+
+	tests := []struct {
+		name      string
+		validator *Validator
+		scope     apiv1.Scope
+		want      map[string]string
+	}{
+		{
+			name:      "file scope by default",
+			validator: &Validator{},
+			want:      map[string]string{"web": "services.yaml", "api": "services.yaml", "worker": "services.yaml"},
+		},
+		{
+			name:      "all scope from the validator",
+			validator: &Validator{Scope: apiv1.ScopeAll},
+			want:      map[string]string{"worker": "services.yaml"},
+		},
+		{
+			name:      "all scope from the rule",
+			validator: &Validator{},
+			scope:     apiv1.ScopeAll,
+			want:      map[string]string{"worker": "services.yaml"},
+		},
+		{
+			name:      "rule overrides the validator",
+			validator: &Validator{Scope: apiv1.ScopeAll},
+			scope:     apiv1.ScopeFile,
+			want:      map[string]string{"web": "services.yaml", "api": "services.yaml", "worker": "services.yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := selects
+			rule.Scope = tt.scope
+			assert.Equal(t, tt.want, failures(tt.validator, rule))
+		})
+	}
+}
+
+func TestStreamMixedScopes(t *testing.T) {
+	dir := filepath.Join("..", "..", "fixtures", "cross-file")
+	inputFiles := []string{filepath.Join(dir, "deployments.yaml"), filepath.Join(dir, "services.yaml")}
+
+	v := &Validator{MaxWorkers: 4}
+	rules, err := v.CompileRules(limitRules(
+		[]apiv1.Variable{{Name: "count", Expression: "size(allObjects)"}},
+		apiv1.ValidationRule{Name: "file", Expression: "variables.count == (object.kind == 'Service' ? 3 : 2)"},
+		apiv1.ValidationRule{Name: "all", Expression: "variables.count == 5", Scope: apiv1.ScopeAll},
+	))
+	require.NoError(t, err)
+
+	count := 0
+	for result := range v.Stream(context.Background(), inputFiles, rules) {
+		count++
+		assert.True(t, result.Valid, "%s %s: %v", result.RuleName, result.ResourceName, result.Err)
+	}
+	assert.Equal(t, 10, count)
+}
+
+func TestCompileRulesInvalidScope(t *testing.T) {
+	v := &Validator{}
+	_, err := v.CompileRules(limitRules(nil, apiv1.ValidationRule{Name: "scoped", Expression: "true", Scope: "cluster"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid rule 'scoped' (limits.yaml)")
+	assert.Contains(t, err.Error(), `unknown scope "cluster" (must be one of file, all)`)
+}
@@ -0,0 +1,211 @@
+package validator
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Target is the compiled target, targets and exclude of a rule. A nil Target
+// matches every resource.
+type Target struct {
+	include []selector
+	exclude []selector
+}
+
+// selector is a compiled apiv1.TargetSelector.
+type selector struct {
+	apiv1.TargetSelector
+	nameRegex         *regexp.Regexp
+	labels            labels.Selector
+	annotations       labels.Selector
+	namespaceSelector labels.Selector
+}
+
+// namespaceLabels are the labels of Namespace objects by name.
+type namespaceLabels map[string]labels.Set
+
+// compileTarget compiles the selectors of rule, returning nil when it has
+// none, so that a selector that does not parse is reported once rather than
+// failing to match every resource.
+func compileTarget(rule apiv1.ValidationRule) (*Target, error) {
+	if rule.Target == nil && len(rule.Targets) == 0 && len(rule.Exclude) == 0 {
+		return nil, nil
+	}
+
+	target := &Target{}
+	if rule.Target != nil {
+		compiled, err := compileSelector(*rule.Target)
+		if err != nil {
+			return nil, fmt.Errorf("target: %w", err)
+		}
+		target.include = append(target.include, compiled)
+	}
+	for i, s := range rule.Targets {
+		compiled, err := compileSelector(s)
+		if err != nil {
+			return nil, fmt.Errorf("targets[%d]: %w", i, err)
+		}
+		target.include = append(target.include, compiled)
+	}
+	for i, s := range rule.Exclude {
+		compiled, err := compileSelector(s)
+		if err != nil {
+			return nil, fmt.Errorf("exclude[%d]: %w", i, err)
+		}
+		target.exclude = append(target.exclude, compiled)
+	}
+	return target, nil
+}
+
+// ValidateSelector reports why s does not parse, or nil when it does.
+func ValidateSelector(s apiv1.TargetSelector) error {
+	_, err := compileSelector(s)
+	return err
+}
+
+func compileSelector(s apiv1.TargetSelector) (selector, error) {
+	compiled := selector{TargetSelector: s}
+	var err error
+	if s.NamePattern != "" {
+		if _, err = path.Match(s.NamePattern, ""); err != nil {
+			return selector{}, fmt.Errorf("invalid namePattern %q: %w", s.NamePattern, err)
+		}
+	}
+	if s.NameRegex != "" {
+		if _, err = regexp.Compile(s.NameRegex); err != nil {
+			return selector{}, fmt.Errorf("invalid nameRegex: %w", err)
+		}
+		compiled.nameRegex = regexp.MustCompile("^(?:" + s.NameRegex + ")$")
+	}
+	if s.LabelSelector != "" {
+		if compiled.labels, err = labels.Parse(s.LabelSelector); err != nil {
+			return selector{}, fmt.Errorf("invalid labelSelector: %w", err)
+		}
+	}
+	if s.AnnotationSelector != "" {
+		if compiled.annotations, err = labels.Parse(s.AnnotationSelector); err != nil {
+			return selector{}, fmt.Errorf("invalid annotationSelector: %w", err)
+		}
+	}
+	if s.NamespaceSelector != "" {
+		if compiled.namespaceSelector, err = labels.Parse(s.NamespaceSelector); err != nil {
+			return selector{}, fmt.Errorf("invalid namespaceSelector: %w", err)
+		}
+	}
+	return compiled, nil
+}
+
+// Matches reports whether the rule applies to resource: it matches one of the
+// targets, or there are none, and none of the excludes. namespaces are the
+// labels namespaceSelectors are matched against.
+func (t *Target) Matches(resource *unstructured.Unstructured, namespaces namespaceLabels) bool {
+	if t == nil {
+		return true
+	}
+
+	included := len(t.include) == 0
+	for _, s := range t.include {
+		if s.matches(resource, namespaces) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+
+	for _, s := range t.exclude {
+		if s.matches(resource, namespaces) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectsNamespaces reports whether any selector of t matches on the labels
+// of namespaces.
+func (t *Target) selectsNamespaces() bool {
+	if t == nil {
+		return false
+	}
+	selectsNamespaces := func(s selector) bool { return s.namespaceSelector != nil }
+	return slices.ContainsFunc(t.include, selectsNamespaces) || slices.ContainsFunc(t.exclude, selectsNamespaces)
+}
+
+// dependsOnNamespace reports whether resource matching a selector of t
+// depends on the labels of its namespace, i.e. it matches the selector apart
+// from its namespaceSelector.
+func (t *Target) dependsOnNamespace(resource *unstructured.Unstructured) bool {
+	if t == nil {
+		return false
+	}
+	matchesOtherwise := func(s selector) bool {
+		if s.namespaceSelector == nil {
+			return false
+		}
+		s.namespaceSelector = nil
+		return s.matches(resource, nil)
+	}
+	return slices.ContainsFunc(t.include, matchesOtherwise) || slices.ContainsFunc(t.exclude, matchesOtherwise)
+}
+
+func (s selector) matches(resource *unstructured.Unstructured, namespaces namespaceLabels) bool {
+	gvk := resource.GroupVersionKind()
+	if s.Group != "" && gvk.Group != s.Group {
+		return false
+	}
+	if s.Version != "" && gvk.Version != s.Version {
+		return false
+	}
+	if s.Kind != "" && gvk.Kind != s.Kind {
+		return false
+	}
+
+	if s.Name != "" && resource.GetName() != s.Name {
+		return false
+	}
+	if s.NamePattern != "" {
+		if matched, _ := path.Match(s.NamePattern, resource.GetName()); !matched {
+			return false
+		}
+	}
+	if s.nameRegex != nil && !s.nameRegex.MatchString(resource.GetName()) {
+		return false
+	}
+
+	if s.Namespace != "" && resource.GetNamespace() != s.Namespace {
+		return false
+	}
+	if s.labels != nil && !s.labels.Matches(labels.Set(resource.GetLabels())) {
+		return false
+	}
+	if s.annotations != nil && !s.annotations.Matches(labels.Set(resource.GetAnnotations())) {
+		return false
+	}
+	if s.namespaceSelector != nil {
+		namespaceLabels, ok := namespaces[resource.GetNamespace()]
+		if !ok || !s.namespaceSelector.Matches(namespaceLabels) {
+			return false
+		}
+	}
+	return true
+}
+
+// namespacesOf returns the labels of the Namespace objects among objects.
+func namespacesOf(objects []map[string]any) namespaceLabels {
+	namespaces := make(namespaceLabels)
+	for _, obj := range objects {
+		resource := &unstructured.Unstructured{Object: obj}
+		if resource.GetAPIVersion() != "v1" || resource.GetKind() != "Namespace" {
+			continue
+		}
+		namespaces[resource.GetName()] = labels.Set(resource.GetLabels())
+	}
+	return namespaces
+}
@@ -0,0 +1,267 @@
+package validator
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestCompileTargetErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    apiv1.ValidationRule
+		wantErr string
+	}{
+		{
+			name:    "invalid label selector",
+			rule:    apiv1.ValidationRule{Target: &apiv1.TargetSelector{LabelSelector: "app in (web"}},
+			wantErr: "target: invalid labelSelector",
+		},
+		{
+			name:    "invalid annotation selector in targets",
+			rule:    apiv1.ValidationRule{Targets: []apiv1.TargetSelector{{Kind: "Service"}, {AnnotationSelector: "a b c"}}},
+			wantErr: "targets[1]: invalid annotationSelector",
+		},
+		{
+			name:    "invalid namespace selector in exclude",
+			rule:    apiv1.ValidationRule{Exclude: []apiv1.TargetSelector{{NamespaceSelector: "=="}}},
+			wantErr: "exclude[0]: invalid namespaceSelector",
+		},
+		{
+			name:    "invalid name regex",
+			rule:    apiv1.ValidationRule{Target: &apiv1.TargetSelector{NameRegex: "web-("}},
+			wantErr: "target: invalid nameRegex",
+		},
+		{
+			name:    "invalid name pattern",
+			rule:    apiv1.ValidationRule{Target: &apiv1.TargetSelector{NamePattern: "web-["}},
+			wantErr: `target: invalid namePattern "web-["`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileTarget(tt.rule)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestCompileRulesReportsInvalidTarget(t *testing.T) {
+	v := &Validator{}
+	_, err := v.CompileRules(limitRules(nil,
+		apiv1.ValidationRule{Name: "typo", Expression: "true", Target: &apiv1.TargetSelector{LabelSelector: "app in (web"}},
+		apiv1.ValidationRule{Name: "fine", Expression: "true", Target: &apiv1.TargetSelector{LabelSelector: "app=web"}},
+	))
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "invalid target in rule 'typo' (limits.yaml): target: invalid labelSelector")
+	assert.NotContains(t, err.Error(), "'fine'")
+}
+
+func TestTargetMatches(t *testing.T) {
+	resource := func(kind, namespace, name string, labels map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       kind,
+			"metadata":   map[string]any{"name": name, "namespace": namespace, "labels": labels},
+		}}
+	}
+	web := resource("Deployment", "prod", "web-frontend", map[string]any{"tier": "web"})
+	canary := resource("Deployment", "prod", "web-frontend-canary", map[string]any{"tier": "web"})
+	db := resource("StatefulSet", "dev", "db", nil)
+	namespaces := namespaceLabels{
+		"prod": labels.Set{"environment": "production"},
+		"dev":  labels.Set{"environment": "development"},
+	}
+
+	tests := []struct {
+		name     string
+		rule     apiv1.ValidationRule
+		resource *unstructured.Unstructured
+		expected bool
+	}{
+		{
+			name:     "no selectors matches everything",
+			resource: db,
+			expected: true,
+		},
+		{
+			name:     "name glob",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{NamePattern: "web-*"}},
+			resource: canary,
+			expected: true,
+		},
+		{
+			name:     "name pattern must match the whole name",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{NamePattern: "web"}},
+			resource: web,
+			expected: false,
+		},
+		{
+			name:     "exact name",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{Name: "web-frontend"}},
+			resource: web,
+			expected: true,
+		},
+		{
+			name:     "name is not a glob",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{Name: "web-*"}},
+			resource: canary,
+			expected: false,
+		},
+		{
+			name:     "name with glob characters matches literally",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{Name: "web-[frontend]"}},
+			resource: resource("Deployment", "prod", "web-[frontend]", nil),
+			expected: true,
+		},
+		{
+			name:     "name regex must match the whole name",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{NameRegex: "web-[a-z]+"}},
+			resource: canary,
+			expected: false,
+		},
+		{
+			name:     "name regex",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{NameRegex: "web-[a-z]+"}},
+			resource: web,
+			expected: true,
+		},
+		{
+			name:     "any of targets",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{Kind: "Deployment"}, Targets: []apiv1.TargetSelector{{Kind: "StatefulSet"}}},
+			resource: db,
+			expected: true,
+		},
+		{
+			name:     "none of targets",
+			rule:     apiv1.ValidationRule{Targets: []apiv1.TargetSelector{{Kind: "Service"}, {Kind: "Deployment"}}},
+			resource: db,
+			expected: false,
+		},
+		{
+			name:     "excluded",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{Kind: "Deployment"}, Exclude: []apiv1.TargetSelector{{NamePattern: "*-canary"}}},
+			resource: canary,
+			expected: false,
+		},
+		{
+			name:     "not excluded",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{Kind: "Deployment"}, Exclude: []apiv1.TargetSelector{{NamePattern: "*-canary"}}},
+			resource: web,
+			expected: true,
+		},
+		{
+			name:     "exclude without targets",
+			rule:     apiv1.ValidationRule{Exclude: []apiv1.TargetSelector{{LabelSelector: "tier=web"}}},
+			resource: db,
+			expected: true,
+		},
+		{
+			name:     "namespace selector",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{NamespaceSelector: "environment=production"}},
+			resource: web,
+			expected: true,
+		},
+		{
+			name:     "namespace selector does not match",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{NamespaceSelector: "environment=production"}},
+			resource: db,
+			expected: false,
+		},
+		{
+			name:     "namespace not among the inputs",
+			rule:     apiv1.ValidationRule{Target: &apiv1.TargetSelector{NamespaceSelector: "!environment"}},
+			resource: resource("Deployment", "unknown", "web", nil),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := compileTarget(tt.rule)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, target.Matches(tt.resource, namespaces))
+		})
+	}
+}
+
+func TestStreamNamespaceSelector(t *testing.T) {
+	dir := filepath.Join("..", "..", "fixtures", "namespaces")
+	inputFiles := []string{filepath.Join(dir, "workloads.yaml"), filepath.Join(dir, "namespaces.yaml")}
+	ruless, err := yaml.ParseYAMLFileToValidationRules(filepath.Join("..", "..", "fixtures", "rules", "namespace-targets.yaml"))
+	require.NoError(t, err)
+
+	for _, workers := range []int{1, 4} {
+		v := &Validator{MaxWorkers: workers}
+		rules, err := v.CompileRules(ruless)
+		require.NoError(t, err)
+
+		var evaluated, failed []string
+		for result := range v.Stream(context.Background(), inputFiles, rules) {
+			key := result.RuleName + " " + result.ResourceName
+			evaluated = append(evaluated, key)
+			if !result.Valid {
+				failed = append(failed, key)
+			}
+		}
+		sort.Strings(evaluated)
+		sort.Strings(failed)
+
+		assert.Equal(t, []string{
+			"pinned-payments-images payments-api",
+			"pinned-payments-images payments-api-canary",
+			"production-replicas payments-api",
+			"production-replicas search-index",
+		}, evaluated, "workers=%d", workers)
+		assert.Equal(t, []string{
+			"pinned-payments-images payments-api-canary",
+			"production-replicas payments-api",
+			"production-replicas search-index",
+		}, failed, "workers=%d", workers)
+	}
+}
+
+func TestNamespaceLabels(t *testing.T) {
+	dir := filepath.Join("..", "..", "fixtures", "namespaces")
+	ruless, err := yaml.ParseYAMLFileToValidationRules(filepath.Join("..", "..", "fixtures", "rules", "namespace-targets.yaml"))
+	require.NoError(t, err)
+
+	var lookups []string
+	v := &Validator{NamespaceLabels: func(_ context.Context, name string) (map[string]string, error) {
+		lookups = append(lookups, name)
+		switch name {
+		case "payments":
+			return map[string]string{"environment": "production"}, nil
+		case "sandbox":
+			return map[string]string{"environment": "development"}, nil
+		}
+		return nil, assert.AnError
+	}}
+	rules, err := v.CompileRules(ruless)
+	require.NoError(t, err)
+
+	var failed []string
+	for result := range v.Stream(context.Background(), []string{filepath.Join(dir, "workloads.yaml")}, rules) {
+		if !result.Valid {
+			failed = append(failed, result.RuleName+" "+result.ResourceName+": "+result.Err.Error())
+		}
+	}
+	sort.Strings(failed)
+	sort.Strings(lookups)
+
+	assert.Equal(t, []string{
+		"pinned-payments-images payments-api-canary: Payments images must not be release candidates",
+		"production-replicas payments-api: Production workloads must run at least 2 replicas",
+		"production-replicas search-index: looking up the labels of namespace search: " + assert.AnError.Error(),
+	}, failed)
+	assert.Equal(t, []string{"payments", "sandbox", "search"}, lookups, "each namespace should be looked up once")
+}
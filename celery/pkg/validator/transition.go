@@ -0,0 +1,119 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/input"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Changes lists how resources differ between two versions of a set of
+// manifests.
+type Changes struct {
+	// Updated resources are in both versions and are evaluated with oldObject.
+	Updated []*unstructured.Unstructured
+	// Added resources are only in the new version. Transition rules do not
+	// apply to them.
+	Added []*unstructured.Unstructured
+	// Removed resources are only in the old version. No rules are evaluated
+	// against them.
+	Removed []*unstructured.Unstructured
+}
+
+// ValidateChanges evaluates rules against the resources in newFiles with
+// oldObject bound to the matching resource in oldFiles. Resources are paired by
+// group, kind, namespace and name, so a resource moving to a new API version
+// is still an update. Rules with the all scope see every resource in newFiles
+// as allObjects.
+func (v *Validator) ValidateChanges(ctx context.Context, oldFiles []string, newFiles []string, ruless []apiv1.ValidationRules) ([]ValidationResult, Changes, error) {
+	parsedRules, err := v.CompileRules(ruless)
+	if err != nil {
+		return nil, Changes{}, err
+	}
+
+	var results []ValidationResult
+	var changes Changes
+
+	var oldOrder []string
+	oldResources := make(map[string]*unstructured.Unstructured)
+	for _, file := range oldFiles {
+		resources, err := input.ReadResources(file, v.Stdin)
+		if err != nil {
+			results = append(results, readErrorResult(file, err))
+			continue
+		}
+		for _, resource := range resources {
+			key := transitionKey(resource)
+			if key == "" || oldResources[key] != nil {
+				continue
+			}
+			oldOrder = append(oldOrder, key)
+			oldResources[key] = resource
+		}
+	}
+
+	var files []inputFile
+	var newResources []*unstructured.Unstructured
+	paired := make(map[string]bool)
+	for _, name := range newFiles {
+		docs, err := input.ReadDocuments(name, v.Stdin)
+		if err != nil {
+			results = append(results, readErrorResult(name, err))
+			continue
+		}
+
+		file := newInputFile(input.Name(name), docs)
+		file.oldObjects = make([]*unstructured.Unstructured, len(file.resources))
+		for i, resource := range file.resources {
+			key := transitionKey(resource)
+			if old := oldResources[key]; old != nil && !paired[key] {
+				paired[key] = true
+				file.oldObjects[i] = old
+				changes.Updated = append(changes.Updated, resource)
+			} else {
+				changes.Added = append(changes.Added, resource)
+			}
+		}
+		files = append(files, file)
+		newResources = append(newResources, file.resources...)
+	}
+
+	var global *globalInputs
+	if needsGlobal(parsedRules) {
+		global = newGlobalInputs(allObjectsOf(newResources))
+	}
+	results = append(results, collect(v.run(ctx, func(jobs chan<- evaluation, results chan<- ValidationResult) {
+		for _, file := range files {
+			v.enqueue(ctx, file, global, parsedRules, jobs, results)
+		}
+	}))...)
+
+	for _, key := range oldOrder {
+		if !paired[key] {
+			changes.Removed = append(changes.Removed, oldResources[key])
+		}
+	}
+
+	return results, changes, nil
+}
+
+// transitionKey identifies a resource across versions of a manifest. Unnamed
+// resources cannot be paired and have no key.
+func transitionKey(resource *unstructured.Unstructured) string {
+	if resource.GetName() == "" {
+		return ""
+	}
+	gvk := resource.GroupVersionKind()
+	return fmt.Sprintf("%s/%s/%s/%s", gvk.Group, gvk.Kind, resource.GetNamespace(), resource.GetName())
+}
+
+func readErrorResult(file string, err error) ValidationResult {
+	return ValidationResult{
+		InputFile: input.Name(file),
+		Severity:  apiv1.SeverityError,
+		Valid:     false,
+		Err:       fmt.Errorf("reading resources from file: %w", err),
+	}
+}
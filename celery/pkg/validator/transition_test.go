@@ -0,0 +1,115 @@
+package validator
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestValidateChanges(t *testing.T) {
+	ctx := context.Background()
+	v := &Validator{}
+	fixtures := filepath.Join("..", "..", "fixtures")
+
+	ruless, err := yaml.ParseYAMLFileToValidationRules(filepath.Join(fixtures, "rules", "transition-rules.yaml"))
+	require.NoError(t, err)
+	ruless = append(ruless, apiv1.ValidationRules{
+		Filename:   "labels.yaml",
+		ObjectMeta: metav1.ObjectMeta{Name: "labels"},
+		Spec: apiv1.ValidationRulesSpec{
+			Rules: []apiv1.ValidationRule{
+				{Name: "pvc-access-modes", Expression: "has(object.spec.accessModes)", Target: &apiv1.TargetSelector{Kind: "PersistentVolumeClaim"}},
+			},
+		},
+	})
+
+	results, changes, err := v.ValidateChanges(ctx,
+		[]string{filepath.Join(fixtures, "transitions", "old", "storage.yaml")},
+		[]string{filepath.Join(fixtures, "transitions", "new", "storage.yaml")},
+		ruless,
+	)
+	require.NoError(t, err)
+
+	got := make(map[string]bool)
+	for _, result := range results {
+		got[result.RuleName+" "+result.ResourceName] = result.Valid
+	}
+	assert.Equal(t, map[string]bool{
+		"pvc-no-shrink data":                    false,
+		"pvc-no-shrink logs":                    true,
+		"statefulset-service-name-immutable db": false,
+		"pvc-access-modes data":                 true,
+		"pvc-access-modes logs":                 true,
+		"pvc-access-modes cache":                true,
+	}, got, "transition rules should not run against the added cache PVC")
+
+	names := func(resources []*unstructured.Unstructured) []string {
+		var out []string
+		for _, r := range resources {
+			out = append(out, r.GetKind()+"/"+r.GetName())
+		}
+		return out
+	}
+	assert.Equal(t, []string{"PersistentVolumeClaim/data", "PersistentVolumeClaim/logs", "StatefulSet/db"}, names(changes.Updated))
+	assert.Equal(t, []string{"PersistentVolumeClaim/cache"}, names(changes.Added))
+	assert.Equal(t, []string{"ConfigMap/legacy-config"}, names(changes.Removed))
+}
+
+func TestValidateChangesPairing(t *testing.T) {
+	resource := func(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		return u
+	}
+
+	tests := []struct {
+		name     string
+		old      *unstructured.Unstructured
+		new      *unstructured.Unstructured
+		wantPair bool
+	}{
+		{name: "same resource", old: resource("apps/v1", "Deployment", "a", "web"), new: resource("apps/v1", "Deployment", "a", "web"), wantPair: true},
+		{name: "version change", old: resource("batch/v1beta1", "CronJob", "a", "job"), new: resource("batch/v1", "CronJob", "a", "job"), wantPair: true},
+		{name: "different namespace", old: resource("apps/v1", "Deployment", "a", "web"), new: resource("apps/v1", "Deployment", "b", "web"), wantPair: false},
+		{name: "different group", old: resource("example.com/v1", "Deployment", "a", "web"), new: resource("apps/v1", "Deployment", "a", "web"), wantPair: false},
+		{name: "unnamed", old: resource("v1", "ConfigMap", "a", ""), new: resource("v1", "ConfigMap", "a", ""), wantPair: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldKey, newKey := transitionKey(tt.old), transitionKey(tt.new)
+			assert.Equal(t, tt.wantPair, oldKey != "" && oldKey == newKey)
+		})
+	}
+}
+
+func TestValidateChangesScope(t *testing.T) {
+	dir := filepath.Join("..", "..", "fixtures", "cross-file")
+	files := []string{filepath.Join(dir, "deployments.yaml"), filepath.Join(dir, "services.yaml")}
+
+	v := &Validator{Scope: apiv1.ScopeAll}
+	results, changes, err := v.ValidateChanges(context.Background(), files, files, limitRules(nil,
+		apiv1.ValidationRule{Name: "all", Expression: "size(allObjects) == 5"},
+		apiv1.ValidationRule{Name: "file", Expression: "size(allObjects) < 5", Scope: apiv1.ScopeFile},
+		apiv1.ValidationRule{Name: "unchanged", Expression: "object == oldObject", Transition: true},
+	))
+	require.NoError(t, err)
+	assert.Len(t, changes.Updated, 5)
+
+	inputs := make(map[string]int)
+	for _, result := range results {
+		assert.True(t, result.Valid, "%s %s: %v", result.RuleName, result.ResourceName, result.Err)
+		inputs[filepath.Base(result.InputFile)]++
+	}
+	assert.Equal(t, map[string]int{"deployments.yaml": 6, "services.yaml": 9}, inputs)
+}
@@ -0,0 +1,163 @@
+package validator
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/schemas"
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/cel/common"
+	"k8s.io/apiserver/pkg/cel/openapi"
+)
+
+// typeChecker compiles rules against the schema of each kind they target so
+// that misspelt fields and type errors are reported when rules are loaded
+// rather than when, or if, a resource exercises them. Rules are still
+// evaluated with dyn objects.
+//
+// Rules without a target kind, or targeting a kind without a schema, are not
+// type checked.
+type typeChecker struct {
+	resolver *schemas.Resolver
+	envs     map[schema.GroupVersionKind]*cel.Env
+	// variables caches the typed variables of a ValidationRules per kind.
+	variables map[typedVariablesKey]*typedVariables
+}
+
+type typedVariablesKey struct {
+	rules *apiv1.ValidationRules
+	gvk   schema.GroupVersionKind
+}
+
+// typedVariables is an environment declaring the variables of a ValidationRules
+// with the types inferred for one kind. Variables that fail to type check are
+// declared dyn and their errors are reported for the rules that use them.
+type typedVariables struct {
+	env    *cel.Env
+	errors map[string]string
+}
+
+func newTypeChecker(resolver *schemas.Resolver) *typeChecker {
+	return &typeChecker{
+		resolver:  resolver,
+		envs:      make(map[schema.GroupVersionKind]*cel.Env),
+		variables: make(map[typedVariablesKey]*typedVariables),
+	}
+}
+
+// check type checks rule, which belongs to rules, against every kind it
+// targets. Selectors without a kind are not type checked.
+func (c *typeChecker) check(rules *apiv1.ValidationRules, rule apiv1.ValidationRule) []error {
+	var gvks []schema.GroupVersionKind
+	for _, target := range rule.Selectors() {
+		if target.Kind == "" {
+			continue
+		}
+		for _, gvk := range c.resolver.Kinds(target.Group, target.Version, target.Kind) {
+			if !slices.Contains(gvks, gvk) {
+				gvks = append(gvks, gvk)
+			}
+		}
+	}
+
+	var errs []error
+	for _, gvk := range gvks {
+		kind := gvk.GroupVersion().String() + " " + gvk.Kind
+
+		vars, err := c.typedVariables(rules, gvk)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("type checking rule '%s' (%s) against %s: %w", rule.Name, rules.Filename, kind, err))
+			continue
+		}
+
+		for _, name := range vars.referenced(rule) {
+			errs = append(errs, fmt.Errorf("type error in variable '%s' (%s) against %s, used by rule '%s': %s", name, rules.Filename, kind, rule.Name, vars.errors[name]))
+		}
+
+		if _, issues := vars.env.Compile(rule.Expression); issues != nil && issues.Err() != nil {
+			errs = append(errs, fmt.Errorf("type error in rule '%s' (%s) against %s: %s", rule.Name, rules.Filename, kind, issueMessage(issues)))
+		}
+		if rule.MessageExpression != "" {
+			if _, issues := vars.env.Compile(rule.MessageExpression); issues != nil && issues.Err() != nil {
+				errs = append(errs, fmt.Errorf("type error in messageExpression of rule '%s' (%s) against %s: %s", rule.Name, rules.Filename, kind, issueMessage(issues)))
+			}
+		}
+	}
+	return errs
+}
+
+func (c *typeChecker) env(gvk schema.GroupVersionKind) (*cel.Env, error) {
+	if env, ok := c.envs[gvk]; ok {
+		return env, nil
+	}
+
+	s, err := c.resolver.ResolveSchema(gvk)
+	if err != nil {
+		return nil, err
+	}
+	declType := common.SchemaDeclType(&openapi.Schema{Schema: s}, true)
+	if declType == nil {
+		return nil, fmt.Errorf("schema of %s cannot be represented in CEL", gvk)
+	}
+	env, err := newTypedEnv(declType.MaybeAssignTypeName(typeName(gvk)))
+	if err != nil {
+		return nil, err
+	}
+	c.envs[gvk] = env
+	return env, nil
+}
+
+func (c *typeChecker) typedVariables(rules *apiv1.ValidationRules, gvk schema.GroupVersionKind) (*typedVariables, error) {
+	key := typedVariablesKey{rules: rules, gvk: gvk}
+	if vars, ok := c.variables[key]; ok {
+		return vars, nil
+	}
+
+	env, err := c.env(gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := &typedVariables{errors: make(map[string]string)}
+	for _, variable := range rules.Spec.Variables {
+		outputType := cel.DynType
+		ast, issues := env.Compile(variable.Expression)
+		if issues != nil && issues.Err() != nil {
+			vars.errors[variable.Name] = issueMessage(issues)
+		} else {
+			outputType = ast.OutputType()
+		}
+		env, err = env.Extend(cel.Variable(variablesPrefix+variable.Name, outputType))
+		if err != nil {
+			return nil, fmt.Errorf("declaring variable '%s': %w", variable.Name, err)
+		}
+	}
+	vars.env = env
+
+	c.variables[key] = vars
+	return vars, nil
+}
+
+// referenced returns the variables with type errors that rule references.
+func (v *typedVariables) referenced(rule apiv1.ValidationRule) []string {
+	var names []string
+	for name := range v.errors {
+		ident := regexp.MustCompile(`\b` + regexp.QuoteMeta(variablesPrefix+name) + `\b`)
+		if ident.MatchString(rule.Expression) || ident.MatchString(rule.MessageExpression) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// typeName names the CEL object type of a kind. It only needs to be unique
+// within an environment, which declares a single kind.
+func typeName(gvk schema.GroupVersionKind) string {
+	return strings.NewReplacer(".", "_", "/", "_").Replace(gvk.GroupVersion().String()) + "_" + gvk.Kind
+}
@@ -0,0 +1,133 @@
+package validator
+
+import (
+	"path/filepath"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCompileRulesTypeCheck(t *testing.T) {
+	resolver, err := schemas.NewResolver()
+	require.NoError(t, err)
+	require.NoError(t, resolver.LoadCRDs([]string{filepath.Join("..", "..", "fixtures", "crds", "widgets.yaml")}))
+
+	deployment := &apiv1.TargetSelector{Kind: "Deployment"}
+
+	tests := []struct {
+		name      string
+		variables []apiv1.Variable
+		rule      apiv1.ValidationRule
+		wantErrs  []string
+	}{
+		{
+			name: "valid fields",
+			rule: apiv1.ValidationRule{Expression: "object.spec.replicas >= 3 && has(object.metadata.labels)", Target: deployment},
+		},
+		{
+			name:     "misspelt field",
+			rule:     apiv1.ValidationRule{Expression: "object.spec.replica >= 3", Target: deployment},
+			wantErrs: []string{"type error in rule 'r' (rules.yaml) against apps/v1 Deployment", "undefined field 'replica'"},
+		},
+		{
+			name:     "wrong type",
+			rule:     apiv1.ValidationRule{Expression: "object.spec.replicas == 'three'", Target: deployment},
+			wantErrs: []string{"found no matching overload for '_==_' applied to '(int, string)'"},
+		},
+		{
+			name:     "messageExpression",
+			rule:     apiv1.ValidationRule{Expression: "true", MessageExpression: "object.spec.replica", Target: deployment},
+			wantErrs: []string{"type error in messageExpression of rule 'r'", "undefined field 'replica'"},
+		},
+		{
+			name: "quantities are dyn",
+			rule: apiv1.ValidationRule{
+				Expression: "object.spec.template.spec.containers.all(c, quantity(c.resources.limits.memory).isLessThan(quantity('2Gi')))",
+				Target:     deployment,
+			},
+		},
+		{
+			name: "oldObject",
+			rule: apiv1.ValidationRule{Expression: "oldObject == null || object.spec.selector == oldObject.spec.selector", Target: deployment},
+		},
+		{
+			name: "no target kind",
+			rule: apiv1.ValidationRule{Expression: "object.spec.replica >= 3"},
+		},
+		{
+			name: "kind without a schema",
+			rule: apiv1.ValidationRule{Expression: "object.spec.replica >= 3", Target: &apiv1.TargetSelector{Kind: "Gadget"}},
+		},
+		{
+			name:     "every matching version",
+			rule:     apiv1.ValidationRule{Expression: "object.spec.metrics.size() > 0", Target: &apiv1.TargetSelector{Kind: "HorizontalPodAutoscaler"}},
+			wantErrs: []string{"against autoscaling/v1 HorizontalPodAutoscaler", "undefined field 'metrics'"},
+		},
+		{
+			name:      "typed variables",
+			variables: []apiv1.Variable{{Name: "replicas", Expression: "object.spec.replicas"}},
+			rule:      apiv1.ValidationRule{Expression: "variables.replicas == 'three'", Target: deployment},
+			wantErrs:  []string{"found no matching overload for '_==_' applied to '(int, string)'"},
+		},
+		{
+			name:      "variable error used by rule",
+			variables: []apiv1.Variable{{Name: "ports", Expression: "object.spec.ports"}},
+			rule:      apiv1.ValidationRule{Expression: "size(variables.ports) > 0", Target: deployment},
+			wantErrs:  []string{"type error in variable 'ports' (rules.yaml) against apps/v1 Deployment, used by rule 'r'", "undefined field 'ports'"},
+		},
+		{
+			name:      "variable error not used by rule",
+			variables: []apiv1.Variable{{Name: "ports", Expression: "object.spec.ports"}},
+			rule:      apiv1.ValidationRule{Expression: "object.spec.replicas >= 3", Target: deployment},
+		},
+		{
+			name:     "custom resource",
+			rule:     apiv1.ValidationRule{Expression: "object.spec.size > 0 && object.spec.colour == 'red'", Target: &apiv1.TargetSelector{Group: "example.com", Kind: "Widget"}},
+			wantErrs: []string{"against example.com/v1 Widget", "undefined field 'colour'"},
+		},
+		{
+			name: "custom resource preserving unknown fields",
+			rule: apiv1.ValidationRule{Expression: "object.spec.settings.anything == 1 && object.metadata.labels.app == 'x'", Target: &apiv1.TargetSelector{Kind: "Widget"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Name = "r"
+			v := &Validator{Schemas: resolver}
+			_, err := v.CompileRules([]apiv1.ValidationRules{
+				{
+					Filename:   "rules.yaml",
+					ObjectMeta: metav1.ObjectMeta{Name: "rules"},
+					Spec: apiv1.ValidationRulesSpec{
+						Variables: tt.variables,
+						Rules:     []apiv1.ValidationRule{tt.rule},
+					},
+				},
+			})
+			if len(tt.wantErrs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.wantErrs {
+				assert.Contains(t, err.Error(), want)
+			}
+
+			_, err = (&Validator{}).CompileRules([]apiv1.ValidationRules{
+				{
+					Filename: "rules.yaml",
+					Spec: apiv1.ValidationRulesSpec{
+						Variables: tt.variables,
+						Rules:     []apiv1.ValidationRule{tt.rule},
+					},
+				},
+			})
+			assert.NoError(t, err, "rules should compile without type checking")
+		})
+	}
+}
@@ -4,252 +4,368 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
-	"sync"
+	"sync/atomic"
+	"time"
 
 	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
-	"github.com/RRethy/kube-tools/celery/pkg/yaml"
+	"github.com/RRethy/kube-tools/celery/pkg/input"
+	"github.com/RRethy/kube-tools/celery/pkg/schemas"
 	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/labels"
 )
 
 type ValidationResult struct {
-	InputFile    string
-	RuleFile     string
-	RuleName     string
-	ResourceKind string
-	ResourceName string
-	Valid        bool
-	Err          error
+	InputFile          string
+	RuleFile           string
+	RuleName           string
+	ResourceAPIVersion string
+	ResourceKind       string
+	ResourceNamespace  string
+	ResourceName       string
+	Severity           apiv1.Severity
+	Valid              bool
+	// Skipped is set when the resource opted out of the rule with the skip
+	// annotation. The rule is not evaluated and SkipReason holds the justification.
+	Skipped    bool
+	SkipReason string
+	// Baselined is set on a failure that is recorded in a baseline. It is still
+	// a failure but does not fail the run.
+	Baselined bool
+	// LimitExceeded is set on a failure whose evaluation was stopped by a cost
+	// limit, the cost budget or the timeout. Err wraps ErrLimitExceeded.
+	LimitExceeded bool
+	Err           error
+	// Line and Column are where in InputFile the result points: the field a
+	// failing rule selects when it can be found, otherwise the resource. They
+	// are zero when unknown, as for cluster objects.
+	Line   int
+	Column int
+	// Explanation traces why the rule was false when the Validator explains
+	// failures. It is nil otherwise, and for failures that are errors.
+	Explanation *Explanation
 }
 
 type Rule struct {
 	Filename string
 	Name     string
 	Message  string
-	Program  cel.Program
-	Target   *apiv1.TargetSelector
+	// MessageProgram builds the failure message when the rule sets a messageExpression.
+	MessageProgram cel.Program
+	Severity       apiv1.Severity
+	Program        cel.Program
+	// Variables are the spec.variables of the ValidationRules the rule came from.
+	Variables *VariableSet
+	// Params are what the rule sees as params: those of the ValidationRules
+	// it came from, or the Validator's when it has no paramsRef.
+	Params any
+	// Target selects the resources the rule applies to. It is nil when the
+	// rule applies to every resource.
+	Target *Target
+	// Transition rules are only evaluated against resources with an oldObject.
+	Transition bool
+	// CostLimit is the cost an evaluation of Program or MessageProgram may use.
+	CostLimit uint64
+	// EstimatedCost is the range of costs Program may use, as estimated when
+	// it was compiled.
+	EstimatedCost checker.CostEstimate
+	// Scope is which resources the rule sees as allObjects.
+	Scope apiv1.Scope
+	// Fields are the paths of the fields of object Program selects, used to
+	// point a failure at the line of the field that failed.
+	Fields [][]string
+	// Fix changes a resource failing the rule so that it passes. It is nil
+	// when the rule has no fix.
+	Fix *Fix
+
+	// env and ast are what Program was compiled from, kept to explain failures.
+	env *cel.Env
+	ast *cel.Ast
 }
 
-type Validator struct{}
+type Validator struct {
+	// Stdin is read when an input file is "-".
+	Stdin io.Reader
+	// Schemas enables type checking rules against the schemas of the kinds
+	// they target when they are compiled. Rules are not type checked when nil.
+	Schemas *schemas.Resolver
+
+	// CostLimit is the cost a single evaluation of a rule or variable may use,
+	// unless the rule sets its own costLimit. Zero uses DefaultCostLimit.
+	CostLimit uint64
+	// CostBudget is the cost every evaluation by the Validator may use
+	// together. Rules are reported as exceeding it instead of being evaluated
+	// once it is spent. Zero means no budget.
+	CostBudget uint64
+	// Timeout limits how long a single evaluation of a rule may take. Zero
+	// means no timeout.
+	Timeout time.Duration
+	// MaxWorkers bounds how many rule evaluations run at once. Zero uses
+	// GOMAXPROCS.
+	MaxWorkers int
+	// Scope is which resources rules that do not set a scope see as
+	// allObjects. Zero uses apiv1.ScopeFile.
+	Scope apiv1.Scope
+	// Params are what rules whose ValidationRules has no paramsRef see as
+	// params. Nil binds params to null.
+	Params any
+	// NamespaceLabels looks up the labels of a Namespace that is not among
+	// the inputs for rules with a namespaceSelector, e.g. the Namespace of an
+	// admission request. Rules with a namespaceSelector fail with its error.
+	// Nil only matches Namespaces among the inputs. It may be called
+	// concurrently.
+	NamespaceLabels func(ctx context.Context, name string) (map[string]string, error)
+	// Explain sets the Explanation of every result of a rule that was false.
+	// Explaining evaluates the rule again, and its predicate again for each
+	// element of a comprehension it explains. Those evaluations are counted
+	// against CostBudget, and explaining stops once it is spent.
+	Explain bool
+
+	spent atomic.Uint64
+}
 
+// Validate compiles ruless and evaluates them against inputFiles, collecting
+// every result. Use Stream to handle results as they are produced instead.
 func (v *Validator) Validate(ctx context.Context, inputFiles []string, ruless []apiv1.ValidationRules) ([]ValidationResult, error) {
-	env, err := cel.NewEnv(
-		cel.Variable("object", cel.DynType),
-		cel.Variable("allObjects", cel.ListType(cel.DynType)),
-	)
+	parsedRules, err := v.CompileRules(ruless)
 	if err != nil {
-		return nil, fmt.Errorf("creating CEL environment: %w", err)
+		return nil, err
 	}
 
-	var parsedRules []Rule
-	var parseErrs []error
-	for _, rules := range ruless {
-		for _, rule := range rules.Spec.Rules {
-			ast, issues := env.Compile(rule.Expression)
-			if issues != nil && issues.Err() != nil {
-				errMsg := issues.Err().Error()
-				if idx := strings.Index(errMsg, "ERROR:"); idx != -1 {
-					errMsg = strings.TrimSpace(errMsg[idx+6:])
-					if nlIdx := strings.Index(errMsg, "\n"); nlIdx != -1 {
-						errMsg = strings.TrimSpace(errMsg[:nlIdx])
-					}
-				}
-				parseErrs = append(parseErrs, fmt.Errorf("invalid expression in rule '%s' (%s): %s", rule.Name, rules.Filename, errMsg))
-				continue
-			}
-
-			prg, err := env.Program(ast)
-			if err != nil {
-				parseErrs = append(parseErrs, fmt.Errorf("failed to compile rule '%s' (%s): %w", rule.Name, rules.Filename, err))
-				continue
-			}
-
-			parsedRules = append(parsedRules, Rule{
-				Filename: rules.Filename,
-				Name:     rule.Name,
-				Message:  rule.Message,
-				Program:  prg,
-				Target:   rule.Target,
-			})
-		}
-	}
-	if len(parseErrs) > 0 {
-		return nil, errors.Join(parseErrs...)
-	}
+	return collect(v.Stream(ctx, inputFiles, parsedRules)), nil
+}
 
-	results := make(chan []ValidationResult, len(inputFiles))
-	var wg sync.WaitGroup
-	for _, file := range inputFiles {
-		wg.Add(1)
-		go func(f string) {
-			defer wg.Done()
-			results <- v.ValidateFile(ctx, f, parsedRules)
-		}(file)
+// ValidateCluster evaluates rules against objects listed from the cluster
+// contextName refers to. Results are labelled context/namespace, or just context
+// for cluster-scoped objects, in place of an input file.
+func (v *Validator) ValidateCluster(ctx context.Context, contextName string, objects []*unstructured.Unstructured, ruless []apiv1.ValidationRules) ([]ValidationResult, error) {
+	parsedRules, err := v.CompileRules(ruless)
+	if err != nil {
+		return nil, err
 	}
 
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	var collected []ValidationResult
-	for fileResults := range results {
-		collected = append(collected, fileResults...)
+	results := v.ValidateResources(ctx, contextName, objects, parsedRules)
+	for i := range results {
+		if ns := results[i].ResourceNamespace; ns != "" {
+			results[i].InputFile = contextName + "/" + ns
+		}
 	}
-	return collected, nil
+	return results, nil
 }
 
-func (v *Validator) ValidateFile(ctx context.Context, file string, rules []Rule) []ValidationResult {
-	resources, err := yaml.ParseYAMLFileToUnstructured(file)
+// CompileRules compiles every rule in ruless, returning all compile errors joined together.
+func (v *Validator) CompileRules(ruless []apiv1.ValidationRules) ([]Rule, error) {
+	baseEnv, err := newBaseEnv()
 	if err != nil {
-		return []ValidationResult{{
-			InputFile: file,
-			RuleFile:  "",
-			Valid:     false,
-			Err:       fmt.Errorf("reading resources from file: %w", err),
-		}}
+		return nil, fmt.Errorf("creating CEL environment: %w", err)
 	}
 
-	allObjects := make([]map[string]any, 0, len(resources))
-	for _, resource := range resources {
-		allObjects = append(allObjects, resource.Object)
+	var checker *typeChecker
+	if v.Schemas != nil {
+		checker = newTypeChecker(v.Schemas)
 	}
 
-	type ruleEvaluation struct {
-		resource *unstructured.Unstructured
-		rule     Rule
-	}
+	var parsedRules []Rule
+	var parseErrs []error
+	for i, rules := range ruless {
+		env, variables, err := compileVariables(baseEnv, rules, programOptions(v.costLimit())...)
+		if err != nil {
+			parseErrs = append(parseErrs, err)
+			continue
+		}
+		params := v.Params
+		if rules.Spec.ParamsRef != "" {
+			params = rules.Params
+		}
 
-	var evaluations []ruleEvaluation
-	for _, resource := range resources {
-		for _, rule := range rules {
-			if !matchesTarget(resource, rule.Target) {
+		for _, rule := range rules.Spec.Rules {
+			severity, err := apiv1.ParseSeverity(string(rule.Severity))
+			if err != nil {
+				parseErrs = append(parseErrs, fmt.Errorf("invalid rule '%s' (%s): %w", rule.Name, rules.Filename, err))
 				continue
 			}
-			evaluations = append(evaluations, ruleEvaluation{
-				resource: resource,
-				rule:     rule,
-			})
-		}
-	}
 
-	results := make(chan ValidationResult, len(evaluations))
-	var wg sync.WaitGroup
+			scope := v.Scope
+			if rule.Scope != "" || scope == "" {
+				scope, err = apiv1.ParseScope(string(rule.Scope))
+				if err != nil {
+					parseErrs = append(parseErrs, fmt.Errorf("invalid rule '%s' (%s): %w", rule.Name, rules.Filename, err))
+					continue
+				}
+			}
 
-	for _, eval := range evaluations {
-		wg.Add(1)
-		go func(r *unstructured.Unstructured, rule Rule) {
-			defer wg.Done()
+			ast, issues := env.Compile(rule.Expression)
+			if issues != nil && issues.Err() != nil {
+				parseErrs = append(parseErrs, fmt.Errorf("invalid expression in rule '%s' (%s): %s", rule.Name, rules.Filename, issueMessage(issues)))
+				continue
+			}
 
-			resourceKind := r.GetKind()
-			resourceName := r.GetName()
-			if resourceName == "" {
-				resourceName = "<unnamed>"
+			costLimit := v.ruleCostLimit(rule.CostLimit)
+			estimatedCost, err := estimateCost(env, ast)
+			if err != nil {
+				parseErrs = append(parseErrs, fmt.Errorf("failed to estimate cost of rule '%s' (%s): %w", rule.Name, rules.Filename, err))
+				continue
+			}
+			if estimatedCost.Min > costLimit {
+				parseErrs = append(parseErrs, fmt.Errorf("rule '%s' (%s) costs at least %d, more than its cost limit of %d", rule.Name, rules.Filename, estimatedCost.Min, costLimit))
+				continue
 			}
 
-			validationResult := ValidationResult{
-				InputFile:    file,
-				RuleFile:     rule.Filename,
-				RuleName:     rule.Name,
-				ResourceKind: resourceKind,
-				ResourceName: resourceName,
+			prg, err := env.Program(ast, programOptions(costLimit)...)
+			if err != nil {
+				parseErrs = append(parseErrs, fmt.Errorf("failed to compile rule '%s' (%s): %w", rule.Name, rules.Filename, err))
+				continue
 			}
 
-			out, _, err := rule.Program.ContextEval(ctx, map[string]any{
-				"object":     r.Object,
-				"allObjects": allObjects,
-			})
+			var messagePrg cel.Program
+			if rule.MessageExpression != "" {
+				messageAst, issues := env.Compile(rule.MessageExpression)
+				if issues != nil && issues.Err() != nil {
+					parseErrs = append(parseErrs, fmt.Errorf("invalid messageExpression in rule '%s' (%s): %s", rule.Name, rules.Filename, issueMessage(issues)))
+					continue
+				}
+				if t := messageAst.OutputType(); !t.IsExactType(cel.StringType) && !t.IsExactType(cel.DynType) {
+					parseErrs = append(parseErrs, fmt.Errorf("invalid messageExpression in rule '%s' (%s): must return a string but returns %s", rule.Name, rules.Filename, t))
+					continue
+				}
+				messagePrg, err = env.Program(messageAst, programOptions(costLimit)...)
+				if err != nil {
+					parseErrs = append(parseErrs, fmt.Errorf("failed to compile messageExpression for rule '%s' (%s): %w", rule.Name, rules.Filename, err))
+					continue
+				}
+			}
+
+			target, err := compileTarget(rule)
 			if err != nil {
-				validationResult.Valid = false
-				validationResult.Err = fmt.Errorf("evaluating rule: %w", err)
-			} else if out == nil || out.Type() != cel.BoolType {
-				validationResult.Valid = false
-				validationResult.Err = errors.New("expression did not return a boolean")
-			} else if out.Value().(bool) {
-				validationResult.Valid = true
-				validationResult.Err = nil
-			} else {
-				validationResult.Valid = false
-				validationResult.Err = fmt.Errorf("%s", rule.Message)
+				parseErrs = append(parseErrs, fmt.Errorf("invalid target in rule '%s' (%s): %w", rule.Name, rules.Filename, err))
+				continue
 			}
 
-			results <- validationResult
-		}(eval.resource, eval.rule)
-	}
+			var fix *Fix
+			if rule.Fix != nil {
+				fix, err = compileFix(env, rule.Fix, costLimit)
+				if err != nil {
+					parseErrs = append(parseErrs, fmt.Errorf("invalid fix in rule '%s' (%s): %w", rule.Name, rules.Filename, err))
+					continue
+				}
+			}
 
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+			if checker != nil {
+				if errs := checker.check(&ruless[i], rule); len(errs) > 0 {
+					parseErrs = append(parseErrs, errs...)
+					continue
+				}
+			}
 
-	var collected []ValidationResult
-	for result := range results {
-		collected = append(collected, result)
+			parsedRules = append(parsedRules, Rule{
+				Filename:       rules.Filename,
+				Name:           rule.Name,
+				Message:        rule.Message,
+				MessageProgram: messagePrg,
+				Severity:       severity,
+				Program:        prg,
+				Variables:      variables,
+				Params:         params,
+				Target:         target,
+				Transition:     rule.Transition,
+				CostLimit:      costLimit,
+				EstimatedCost:  estimatedCost,
+				Scope:          scope,
+				Fields:         fieldPaths(ast, variables.fieldRoots()),
+				Fix:            fix,
+				env:            env,
+				ast:            ast,
+			})
+		}
+	}
+	if len(parseErrs) > 0 {
+		return nil, errors.Join(parseErrs...)
 	}
 
-	return collected
+	return parsedRules, nil
 }
 
-func matchesTarget(resource *unstructured.Unstructured, target *apiv1.TargetSelector) bool {
-	if target == nil {
-		return true
+func (v *Validator) ValidateFile(ctx context.Context, file string, rules []Rule) []ValidationResult {
+	docs, err := input.ReadDocuments(file, v.Stdin)
+	if err != nil {
+		return []ValidationResult{readErrorResult(file, err)}
 	}
 
-	if target.Group != "" {
-		gv := resource.GroupVersionKind()
-		if gv.Group != target.Group {
-			return false
-		}
-	}
+	return collect(v.run(ctx, func(jobs chan<- evaluation, results chan<- ValidationResult) {
+		v.enqueue(ctx, newInputFile(input.Name(file), docs), nil, rules, jobs, results)
+	}))
+}
 
-	if target.Version != "" {
-		gv := resource.GroupVersionKind()
-		if gv.Version != target.Version {
-			return false
-		}
-	}
+// ValidateResources evaluates rules against already parsed resources, labelling
+// results with inputName.
+func (v *Validator) ValidateResources(ctx context.Context, inputName string, resources []*unstructured.Unstructured, rules []Rule) []ValidationResult {
+	return v.ValidateTransitions(ctx, inputName, resources, nil, rules)
+}
 
-	if target.Kind != "" {
-		if resource.GetKind() != target.Kind {
-			return false
-		}
+// ValidateTransitions evaluates rules against resources with oldObject bound to
+// the previous version of each resource. oldObjects is either nil or parallel to
+// resources; a nil entry means the resource has no previous version and
+// oldObject is null, as it is for creates at admission time. Transition rules
+// are not evaluated against resources without a previous version. resources
+// are a single input, so rules of either scope see all of them as allObjects.
+func (v *Validator) ValidateTransitions(ctx context.Context, inputName string, resources []*unstructured.Unstructured, oldObjects []*unstructured.Unstructured, rules []Rule) []ValidationResult {
+	return collect(v.run(ctx, func(jobs chan<- evaluation, results chan<- ValidationResult) {
+		v.enqueue(ctx, inputFile{name: inputName, resources: resources, oldObjects: oldObjects}, nil, rules, jobs, results)
+	}))
+}
+
+func newResult(inputName string, resource *unstructured.Unstructured, rule Rule) ValidationResult {
+	resourceName := resource.GetName()
+	if resourceName == "" {
+		resourceName = "<unnamed>"
 	}
 
-	if target.Name != "" {
-		if resource.GetName() != target.Name {
-			return false
-		}
+	return ValidationResult{
+		InputFile:          inputName,
+		RuleFile:           rule.Filename,
+		RuleName:           rule.Name,
+		ResourceAPIVersion: resource.GetAPIVersion(),
+		ResourceKind:       resource.GetKind(),
+		ResourceNamespace:  resource.GetNamespace(),
+		ResourceName:       resourceName,
+		Severity:           rule.Severity,
 	}
+}
 
-	if target.Namespace != "" {
-		if resource.GetNamespace() != target.Namespace {
-			return false
-		}
+// failureMessage returns the message for a failing rule. When the rule has a
+// messageExpression its result is used, falling back to the static message if
+// the expression fails or does not produce a non-empty string. The cost of
+// evaluating it is added to spent.
+func (r Rule) failureMessage(ctx context.Context, activation map[string]any, spent *atomic.Uint64) string {
+	if r.MessageProgram == nil {
+		return r.Message
 	}
 
-	if target.LabelSelector != "" {
-		selector, err := labels.Parse(target.LabelSelector)
-		if err != nil {
-			return false
-		}
-		resourceLabels := labels.Set(resource.GetLabels())
-		if !selector.Matches(resourceLabels) {
-			return false
-		}
+	out, details, err := r.MessageProgram.ContextEval(ctx, activation)
+	spend(spent, details)
+	if err != nil {
+		return fmt.Sprintf("%s (messageExpression failed: %v)", r.Message, err)
+	}
+	msg, ok := out.Value().(string)
+	if !ok {
+		return fmt.Sprintf("%s (messageExpression failed: returned %s, not a string)", r.Message, out.Type().TypeName())
 	}
+	if strings.TrimSpace(msg) == "" {
+		return r.Message
+	}
+	return msg
+}
 
-	if target.AnnotationSelector != "" {
-		selector, err := labels.Parse(target.AnnotationSelector)
-		if err != nil {
-			return false
-		}
-		resourceAnnotations := labels.Set(resource.GetAnnotations())
-		if !selector.Matches(resourceAnnotations) {
-			return false
+// issueMessage extracts the first error from CEL compile issues.
+func issueMessage(issues *cel.Issues) string {
+	errMsg := issues.Err().Error()
+	if idx := strings.Index(errMsg, "ERROR:"); idx != -1 {
+		errMsg = strings.TrimSpace(errMsg[idx+6:])
+		if nlIdx := strings.Index(errMsg, "\n"); nlIdx != -1 {
+			errMsg = strings.TrimSpace(errMsg[:nlIdx])
 		}
 	}
-
-	return true
+	return errMsg
 }
@@ -106,6 +106,31 @@ func TestValidatorValidate(t *testing.T) {
 			wantErrors:      -1, // Expect error in validation itself
 			wantTotalChecks: 0,
 		},
+		{
+			name: "rule with unknown severity",
+			inputFiles: []string{
+				filepath.Join("..", "..", "fixtures", "resources", "valid-deployment.yaml"),
+			},
+			rules: []apiv1.ValidationRules{
+				{
+					Filename: "bad-severity.yaml",
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "bad-severity",
+					},
+					Spec: apiv1.ValidationRulesSpec{
+						Rules: []apiv1.ValidationRule{
+							{
+								Name:       "critical-rule",
+								Expression: "true",
+								Severity:   "critical",
+							},
+						},
+					},
+				},
+			},
+			wantErrors:      -1,
+			wantTotalChecks: 0,
+		},
 	}
 
 	for _, tt := range tests {
@@ -183,6 +208,361 @@ func TestValidatorWithCrossResourceValidation(t *testing.T) {
 	}
 }
 
+func TestValidatorSeverity(t *testing.T) {
+	ctx := context.Background()
+	v := &Validator{}
+
+	rules := []apiv1.ValidationRules{
+		{
+			Filename: "severity-rules.yaml",
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "severity-rules",
+			},
+			Spec: apiv1.ValidationRulesSpec{
+				Rules: []apiv1.ValidationRule{
+					{
+						Name:       "default-severity",
+						Expression: "false",
+					},
+					{
+						Name:       "warning-severity",
+						Expression: "false",
+						Severity:   apiv1.SeverityWarning,
+					},
+				},
+			},
+		},
+	}
+
+	results, err := v.Validate(ctx, []string{filepath.Join("..", "..", "fixtures", "resources", "valid-deployment.yaml")}, rules)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	severities := make(map[string]apiv1.Severity)
+	for _, r := range results {
+		severities[r.RuleName] = r.Severity
+	}
+	assert.Equal(t, apiv1.SeverityError, severities["default-severity"])
+	assert.Equal(t, apiv1.SeverityWarning, severities["warning-severity"])
+}
+
+func TestValidatorMessageExpression(t *testing.T) {
+	ctx := context.Background()
+	v := &Validator{}
+
+	tests := []struct {
+		name        string
+		rule        apiv1.ValidationRule
+		wantMessage string
+		wantErr     string
+	}{
+		{
+			name: "dynamic message",
+			rule: apiv1.ValidationRule{
+				Name:              "minimum-replicas",
+				Expression:        "object.spec.replicas >= 5",
+				Message:           "Must have at least 5 replicas",
+				MessageExpression: "'replicas is ' + string(object.spec.replicas) + ', need >= 5'",
+			},
+			wantMessage: "replicas is 3, need >= 5",
+		},
+		{
+			name: "failing message expression falls back to message",
+			rule: apiv1.ValidationRule{
+				Name:              "minimum-replicas",
+				Expression:        "object.spec.replicas >= 5",
+				Message:           "Must have at least 5 replicas",
+				MessageExpression: "'missing ' + object.spec.missing",
+			},
+			wantMessage: "Must have at least 5 replicas (messageExpression failed: no such key: missing)",
+		},
+		{
+			name: "empty message expression result falls back to message",
+			rule: apiv1.ValidationRule{
+				Name:              "minimum-replicas",
+				Expression:        "object.spec.replicas >= 5",
+				Message:           "Must have at least 5 replicas",
+				MessageExpression: "''",
+			},
+			wantMessage: "Must have at least 5 replicas",
+		},
+		{
+			name: "message expression must return a string",
+			rule: apiv1.ValidationRule{
+				Name:              "minimum-replicas",
+				Expression:        "object.spec.replicas >= 5",
+				MessageExpression: "object.spec.replicas > 1",
+			},
+			wantErr: "must return a string",
+		},
+		{
+			name: "invalid message expression",
+			rule: apiv1.ValidationRule{
+				Name:              "minimum-replicas",
+				Expression:        "object.spec.replicas >= 5",
+				MessageExpression: "'unterminated",
+			},
+			wantErr: "invalid messageExpression in rule 'minimum-replicas'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := []apiv1.ValidationRules{
+				{
+					Filename: "message-rules.yaml",
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "message-rules",
+					},
+					Spec: apiv1.ValidationRulesSpec{
+						Rules: []apiv1.ValidationRule{tt.rule},
+					},
+				},
+			}
+
+			results, err := v.Validate(ctx, []string{filepath.Join("..", "..", "fixtures", "resources", "valid-deployment.yaml")}, rules)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.False(t, results[0].Valid)
+			assert.EqualError(t, results[0].Err, tt.wantMessage)
+		})
+	}
+}
+
+func TestValidatorSkipAnnotation(t *testing.T) {
+	ctx := context.Background()
+	v := &Validator{}
+
+	rules, err := v.CompileRules([]apiv1.ValidationRules{
+		{
+			Filename: "skip-rules.yaml",
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "skip-rules",
+			},
+			Spec: apiv1.ValidationRulesSpec{
+				Rules: []apiv1.ValidationRule{
+					{Name: "minimum-replicas", Expression: "object.spec.replicas >= 3", Message: "too few replicas"},
+					{Name: "required-labels", Expression: "has(object.metadata.labels)", Message: "missing labels"},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	deployment := func(annotations map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]any{
+				"name":        "web",
+				"annotations": annotations,
+			},
+			"spec": map[string]any{"replicas": int64(1)},
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]any
+		want        map[string]ValidationResult
+	}{
+		{
+			name: "no annotations",
+			want: map[string]ValidationResult{
+				"minimum-replicas": {Valid: false},
+				"required-labels":  {Valid: false},
+			},
+		},
+		{
+			name: "skip one rule with reason",
+			annotations: map[string]any{
+				apiv1.SkipAnnotation:       "minimum-replicas",
+				apiv1.SkipReasonAnnotation: "singleton controller",
+			},
+			want: map[string]ValidationResult{
+				"minimum-replicas": {Valid: true, Skipped: true, SkipReason: "singleton controller"},
+				"required-labels":  {Valid: false},
+			},
+		},
+		{
+			name: "skip a list of rules",
+			annotations: map[string]any{
+				apiv1.SkipAnnotation:       "minimum-replicas, required-labels",
+				apiv1.SkipReasonAnnotation: "legacy",
+			},
+			want: map[string]ValidationResult{
+				"minimum-replicas": {Valid: true, Skipped: true, SkipReason: "legacy"},
+				"required-labels":  {Valid: true, Skipped: true, SkipReason: "legacy"},
+			},
+		},
+		{
+			name: "skip every rule",
+			annotations: map[string]any{
+				apiv1.SkipAnnotation:       "*",
+				apiv1.SkipReasonAnnotation: "generated",
+			},
+			want: map[string]ValidationResult{
+				"minimum-replicas": {Valid: true, Skipped: true, SkipReason: "generated"},
+				"required-labels":  {Valid: true, Skipped: true, SkipReason: "generated"},
+			},
+		},
+		{
+			name: "skip without reason is not honoured",
+			annotations: map[string]any{
+				apiv1.SkipAnnotation: "minimum-replicas",
+			},
+			want: map[string]ValidationResult{
+				"minimum-replicas": {Valid: false},
+				"required-labels":  {Valid: false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := v.ValidateResources(ctx, "input.yaml", []*unstructured.Unstructured{deployment(tt.annotations)}, rules)
+			require.Len(t, results, len(tt.want))
+
+			for _, r := range results {
+				want := tt.want[r.RuleName]
+				assert.Equal(t, want.Valid, r.Valid, r.RuleName)
+				assert.Equal(t, want.Skipped, r.Skipped, r.RuleName)
+				assert.Equal(t, want.SkipReason, r.SkipReason, r.RuleName)
+				assert.Equal(t, "Deployment", r.ResourceKind)
+				assert.Equal(t, "web", r.ResourceName)
+			}
+		})
+	}
+
+	results := v.ValidateResources(ctx, "input.yaml", []*unstructured.Unstructured{deployment(map[string]any{
+		apiv1.SkipAnnotation: "minimum-replicas",
+	})}, rules)
+	for _, r := range results {
+		if r.RuleName == "minimum-replicas" {
+			assert.ErrorContains(t, r.Err, "requires a celery.rrethy.io/skip-reason annotation")
+		}
+	}
+}
+
+func TestValidatorTransitions(t *testing.T) {
+	ctx := context.Background()
+	v := &Validator{}
+
+	rules, err := v.CompileRules([]apiv1.ValidationRules{
+		{
+			Filename: "transition-rules.yaml",
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "transition-rules",
+			},
+			Spec: apiv1.ValidationRulesSpec{
+				Rules: []apiv1.ValidationRule{
+					{
+						Name:       "immutable-selector",
+						Expression: "oldObject == null || object.spec.selector == oldObject.spec.selector",
+						Message:    "spec.selector is immutable",
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	deployment := func(app string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]any{"name": "web"},
+			"spec": map[string]any{
+				"selector": map[string]any{"matchLabels": map[string]any{"app": app}},
+			},
+		}}
+	}
+
+	tests := []struct {
+		name      string
+		oldObject *unstructured.Unstructured
+		wantValid bool
+	}{
+		{name: "no old object", oldObject: nil, wantValid: true},
+		{name: "unchanged", oldObject: deployment("web"), wantValid: true},
+		{name: "changed", oldObject: deployment("api"), wantValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := v.ValidateTransitions(ctx, "test", []*unstructured.Unstructured{deployment("web")}, []*unstructured.Unstructured{tt.oldObject}, rules)
+			require.Len(t, results, 1)
+			assert.Equal(t, tt.wantValid, results[0].Valid, "err: %v", results[0].Err)
+		})
+	}
+
+	t.Run("oldObject is null without transitions", func(t *testing.T) {
+		results := v.ValidateResources(ctx, "test", []*unstructured.Unstructured{deployment("web")}, rules)
+		require.Len(t, results, 1)
+		assert.True(t, results[0].Valid, "err: %v", results[0].Err)
+	})
+}
+
+func TestValidatorParams(t *testing.T) {
+	ctx := context.Background()
+	deployment := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "web", "namespace": "payments"},
+		"spec":       map[string]any{"replicas": int64(4)},
+	}}
+	ruless := []apiv1.ValidationRules{
+		{
+			Filename: "shared.yaml",
+			Spec: apiv1.ValidationRulesSpec{
+				Variables: []apiv1.Variable{{Name: "max", Expression: "params.maxReplicas"}},
+				Rules: []apiv1.ValidationRule{{
+					Name:              "max-replicas",
+					Expression:        "object.spec.replicas <= variables.max",
+					MessageExpression: "'at most ' + string(params.maxReplicas) + ' replicas'",
+				}},
+			},
+		},
+		{
+			Filename: "owners.yaml",
+			Params:   map[string]any{"payments": "team-payments"},
+			Spec: apiv1.ValidationRulesSpec{
+				ParamsRef: "owners.json",
+				Rules:     []apiv1.ValidationRule{{Name: "owned", Expression: "object.metadata.namespace in params"}},
+			},
+		},
+	}
+
+	v := &Validator{Params: map[string]any{"maxReplicas": 3}}
+	rules, err := v.CompileRules(ruless)
+	require.NoError(t, err)
+	results := v.ValidateResources(ctx, "test", []*unstructured.Unstructured{deployment}, rules)
+	require.Len(t, results, 2)
+	outcomes := make(map[string]ValidationResult)
+	for _, result := range results {
+		outcomes[result.RuleName] = result
+	}
+	assert.False(t, outcomes["max-replicas"].Valid)
+	assert.EqualError(t, outcomes["max-replicas"].Err, "at most 3 replicas", "variables and messageExpressions should see params")
+	assert.True(t, outcomes["owned"].Valid, "rules with a paramsRef should see their own params: %v", outcomes["owned"].Err)
+
+	v = &Validator{}
+	rules, err = v.CompileRules([]apiv1.ValidationRules{{
+		Filename: "null.yaml",
+		Spec:     apiv1.ValidationRulesSpec{Rules: []apiv1.ValidationRule{{Name: "null", Expression: "params == null"}}},
+	}})
+	require.NoError(t, err)
+	results = v.ValidateResources(ctx, "test", []*unstructured.Unstructured{deployment}, rules)
+	require.Len(t, results, 1)
+	assert.True(t, results[0].Valid, "params should be null without params: %v", results[0].Err)
+}
+
 func TestValidationResult(t *testing.T) {
 	// Test the ValidationResult struct fields
 	result := ValidationResult{
@@ -544,7 +924,9 @@ func TestMatchesTargetDirectly(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := matchesTarget(tt.resource, tt.target)
+			target, err := compileTarget(apiv1.ValidationRule{Target: tt.target})
+			require.NoError(t, err)
+			result := target.Matches(tt.resource, nil)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
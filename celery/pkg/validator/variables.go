@@ -0,0 +1,140 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// variablesPrefix is prepended to variable names to form the CEL identifier, e.g. variables.containers.
+const variablesPrefix = "variables."
+
+// VariableSet is the compiled spec.variables of a ValidationRules resource.
+type VariableSet struct {
+	names    []string
+	programs []cel.Program
+	// roots maps object and each variable that is a field of object to its
+	// path, for fieldPaths.
+	roots map[string][]string
+}
+
+// compileVariables compiles the variables of rules in order, each one able to
+// reference the variables declared before it. It returns the environment rules
+// should be compiled in, which declares every variable as variables.<name>.
+func compileVariables(env *cel.Env, rules apiv1.ValidationRules, opts ...cel.ProgramOption) (*cel.Env, *VariableSet, error) {
+	if len(rules.Spec.Variables) == 0 {
+		return env, nil, nil
+	}
+
+	set := &VariableSet{roots: maps.Clone(objectRoots)}
+	seen := make(map[string]bool)
+	for _, variable := range rules.Spec.Variables {
+		if variable.Name == "" {
+			return nil, nil, fmt.Errorf("invalid variable in %s: name is required", rules.Filename)
+		}
+		if seen[variable.Name] {
+			return nil, nil, fmt.Errorf("invalid variable '%s' (%s): duplicate name", variable.Name, rules.Filename)
+		}
+		seen[variable.Name] = true
+
+		ast, issues := env.Compile(variable.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, nil, fmt.Errorf("invalid expression in variable '%s' (%s): %s", variable.Name, rules.Filename, issueMessage(issues))
+		}
+
+		prg, err := env.Program(ast, opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compile variable '%s' (%s): %w", variable.Name, rules.Filename, err)
+		}
+
+		env, err = env.Extend(cel.Variable(variablesPrefix+variable.Name, ast.OutputType()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("declaring variable '%s' (%s): %w", variable.Name, rules.Filename, err)
+		}
+
+		set.names = append(set.names, variable.Name)
+		set.programs = append(set.programs, prg)
+		if path, ok := selectPath(ast.NativeRep().Expr(), set.roots); ok {
+			set.roots[variablesPrefix+variable.Name] = path
+		}
+	}
+
+	return env, set, nil
+}
+
+// fieldRoots returns the identifiers rules select fields of object through.
+func (s *VariableSet) fieldRoots() map[string][]string {
+	if s == nil {
+		return objectRoots
+	}
+	return s.roots
+}
+
+// newCache returns a cache for one resource. The cost of evaluating each
+// variable is added to spent.
+func (s *VariableSet) newCache(spent *atomic.Uint64) *variableCache {
+	return &variableCache{
+		set:    s,
+		values: make([]lazyValue, len(s.names)),
+		spent:  spent,
+	}
+}
+
+// variableCache holds the lazily evaluated variables for a single resource.
+// It is safe for concurrent use by the rules evaluating that resource.
+type variableCache struct {
+	set    *VariableSet
+	values []lazyValue
+	spent  *atomic.Uint64
+}
+
+type lazyValue struct {
+	once sync.Once
+	val  ref.Val
+}
+
+// bind adds a lazy binding for every variable to activation. A nil cache binds nothing.
+func (c *variableCache) bind(ctx context.Context, activation map[string]any) {
+	if c == nil {
+		return
+	}
+
+	// Variables see the same object and allObjects as the rule, plus the other variables.
+	base := make(map[string]any, len(activation))
+	for k, v := range activation {
+		base[k] = v
+	}
+
+	for i, name := range c.set.names {
+		activation[variablesPrefix+name] = func() ref.Val {
+			return c.get(ctx, base, i)
+		}
+	}
+}
+
+func (c *variableCache) get(ctx context.Context, base map[string]any, i int) ref.Val {
+	lv := &c.values[i]
+	lv.once.Do(func() {
+		activation := make(map[string]any, len(base)+len(c.set.names))
+		for k, v := range base {
+			activation[k] = v
+		}
+		c.bind(ctx, activation)
+
+		out, details, err := c.set.programs[i].ContextEval(ctx, activation)
+		spend(c.spent, details)
+		if err != nil {
+			lv.val = types.WrapErr(&variableError{name: c.set.names[i], err: err, cause: context.Cause(ctx)})
+			return
+		}
+		lv.val = out
+	})
+	return lv.val
+}
@@ -0,0 +1,153 @@
+package validator
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidatorVariables(t *testing.T) {
+	ctx := context.Background()
+	v := &Validator{}
+
+	tests := []struct {
+		name      string
+		variables []apiv1.Variable
+		rules     []apiv1.ValidationRule
+		wantValid map[string]bool
+		wantErr   string
+	}{
+		{
+			name: "rules reference variables",
+			variables: []apiv1.Variable{
+				{Name: "containers", Expression: "object.spec.template.spec.containers"},
+				{Name: "images", Expression: "variables.containers.map(c, c.image)"},
+			},
+			rules: []apiv1.ValidationRule{
+				{Name: "has-containers", Expression: "size(variables.containers) > 0"},
+				{Name: "no-latest", Expression: "variables.images.all(i, !i.endsWith(':latest'))"},
+				{Name: "pinned", Expression: "variables.images.all(i, i.contains('@sha256'))"},
+			},
+			wantValid: map[string]bool{
+				"has-containers": true,
+				"no-latest":      true,
+				"pinned":         false,
+			},
+		},
+		{
+			name: "variables are evaluated lazily",
+			variables: []apiv1.Variable{
+				{Name: "missing", Expression: "object.spec.doesNotExist"},
+				{Name: "replicas", Expression: "object.spec.replicas"},
+			},
+			rules: []apiv1.ValidationRule{
+				{Name: "replicas", Expression: "variables.replicas >= 3"},
+				{Name: "uses-missing", Expression: "variables.missing == 1"},
+			},
+			wantValid: map[string]bool{
+				"replicas":     true,
+				"uses-missing": false,
+			},
+		},
+		{
+			name: "message expressions can reference variables",
+			variables: []apiv1.Variable{
+				{Name: "replicas", Expression: "object.spec.replicas"},
+			},
+			rules: []apiv1.ValidationRule{
+				{Name: "replicas", Expression: "variables.replicas >= 5", MessageExpression: "'replicas is ' + string(variables.replicas)"},
+			},
+			wantValid: map[string]bool{
+				"replicas": false,
+			},
+		},
+		{
+			name: "variables cannot reference later variables",
+			variables: []apiv1.Variable{
+				{Name: "first", Expression: "variables.second"},
+				{Name: "second", Expression: "1"},
+			},
+			rules:   []apiv1.ValidationRule{{Name: "rule", Expression: "true"}},
+			wantErr: "invalid expression in variable 'first'",
+		},
+		{
+			name: "duplicate variable names",
+			variables: []apiv1.Variable{
+				{Name: "x", Expression: "1"},
+				{Name: "x", Expression: "2"},
+			},
+			rules:   []apiv1.ValidationRule{{Name: "rule", Expression: "true"}},
+			wantErr: "duplicate name",
+		},
+		{
+			name:    "undeclared variable",
+			rules:   []apiv1.ValidationRule{{Name: "rule", Expression: "variables.nope"}},
+			wantErr: "invalid expression in rule 'rule'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := []apiv1.ValidationRules{
+				{
+					Filename: "variable-rules.yaml",
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "variable-rules",
+					},
+					Spec: apiv1.ValidationRulesSpec{
+						Variables: tt.variables,
+						Rules:     tt.rules,
+					},
+				},
+			}
+
+			results, err := v.Validate(ctx, []string{filepath.Join("..", "..", "fixtures", "resources", "valid-deployment.yaml")}, rules)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			got := make(map[string]bool)
+			for _, r := range results {
+				got[r.RuleName] = r.Valid
+				if r.RuleName == "uses-missing" {
+					assert.Contains(t, r.Err.Error(), "variables.missing")
+				}
+				if tt.name == "message expressions can reference variables" {
+					assert.EqualError(t, r.Err, "replicas is 3")
+				}
+			}
+			assert.Equal(t, tt.wantValid, got)
+		})
+	}
+}
+
+func TestVariablesScopedToValidationRules(t *testing.T) {
+	v := &Validator{}
+
+	_, err := v.CompileRules([]apiv1.ValidationRules{
+		{
+			Filename: "a.yaml",
+			Spec: apiv1.ValidationRulesSpec{
+				Variables: []apiv1.Variable{{Name: "replicas", Expression: "object.spec.replicas"}},
+				Rules:     []apiv1.ValidationRule{{Name: "a", Expression: "variables.replicas > 0"}},
+			},
+		},
+		{
+			Filename: "b.yaml",
+			Spec: apiv1.ValidationRulesSpec{
+				Rules: []apiv1.ValidationRule{{Name: "b", Expression: "variables.replicas > 0"}},
+			},
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rule 'b' (b.yaml)")
+	assert.NotContains(t, err.Error(), "rule 'a'")
+}
@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RRethy/kube-tools/celery/pkg/rules"
+)
+
+// Reloader recompiles rule files when they change and swaps the result into a
+// Handler. Rule files are polled rather than watched so that files replaced
+// through ConfigMap symlink swaps are picked up too. Files the rule files
+// include or reference as params are polled as well.
+type Reloader struct {
+	Patterns []string
+	// Params is a file of params for rules without a paramsRef. It is polled
+	// like the rule files.
+	Params   string
+	Handler  *Handler
+	Interval time.Duration
+
+	loaded      bool
+	fingerprint string
+	// included are the files read by the last reload, including included ones.
+	included []string
+}
+
+// Reload recompiles the rules if any rule file was added, removed or modified
+// since the last reload. It reports whether the Handler's rules were replaced.
+// On error the Handler keeps its current rules.
+func (r *Reloader) Reload() (bool, error) {
+	fingerprint, err := fingerprintFiles(r.polled())
+	if err != nil {
+		return false, err
+	}
+	if r.loaded && fingerprint == r.fingerprint {
+		return false, nil
+	}
+
+	resolved, err := rules.Resolve(r.Patterns)
+	if err != nil {
+		return false, err
+	}
+	if r.Params != "" {
+		params, err := rules.LoadParams(r.Params)
+		if err != nil {
+			return false, err
+		}
+		r.Handler.Validator.Params = params
+	}
+	compiled, err := r.Handler.Validator.CompileRules(resolved.Rules)
+	if err != nil {
+		return false, fmt.Errorf("compiling rules: %w", err)
+	}
+
+	r.Handler.SetRules(compiled)
+	r.loaded = true
+	// The fingerprint covers the files included by the rules just loaded, so
+	// that changing one reloads the rules on the next poll.
+	r.included = resolved.Files
+	r.fingerprint, err = fingerprintFiles(r.polled())
+	if err != nil {
+		r.fingerprint = fingerprint
+	}
+	return true, nil
+}
+
+// polled returns the patterns of the files whose changes trigger a reload.
+func (r *Reloader) polled() []string {
+	patterns := append(slices.Clone(r.Patterns), r.included...)
+	if r.Params != "" {
+		patterns = append(patterns, r.Params)
+	}
+	return patterns
+}
+
+// Run reloads the rules every Interval until ctx is done.
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				r.Handler.Logger.Error("reloading rules, keeping previous rules", "error", err)
+			} else if reloaded {
+				r.Handler.Logger.Info("reloaded rules", "rules", len(r.Handler.Rules()))
+			}
+		}
+	}
+}
+
+// fingerprintFiles summarises the path, size and modification time of every
+// file matched by patterns.
+func fingerprintFiles(patterns []string) (string, error) {
+	var entries []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", fmt.Errorf("expanding glob pattern %s: %w", pattern, err)
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, fmt.Sprintf("%s:%d:%d", match, info.Size(), info.ModTime().UnixNano()))
+		}
+	}
+	sort.Strings(entries)
+	entries = slices.Compact(entries)
+	return strings.Join(entries, "\n"), nil
+}
@@ -0,0 +1,201 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/validator"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// ValidatePath is where AdmissionReviews are served.
+	ValidatePath = "/validate"
+	// HealthPath answers liveness and readiness probes.
+	HealthPath = "/healthz"
+
+	// maxRequestBytes is comfortably above the API server's own request limit.
+	maxRequestBytes = 10 << 20
+)
+
+// Handler serves a ValidatingAdmissionWebhook backed by compiled ValidationRules.
+//
+// Failing error severity rules deny the request with their messages, warning
+// severity failures are returned as admission warnings, and info severity
+// failures are only logged. In audit mode every request is allowed and
+// failures are only logged.
+type Handler struct {
+	Validator *validator.Validator
+	Logger    *slog.Logger
+	Audit     bool
+
+	rules atomic.Pointer[[]validator.Rule]
+}
+
+// NewHandler returns a Handler evaluating rules.
+func NewHandler(rules []validator.Rule, audit bool, logger *slog.Logger) *Handler {
+	h := &Handler{
+		Validator: &validator.Validator{},
+		Logger:    logger,
+		Audit:     audit,
+	}
+	h.SetRules(rules)
+	return h
+}
+
+// SetRules replaces the rules used for requests that arrive afterwards. It is
+// safe to call while requests are being served.
+func (h *Handler) SetRules(rules []validator.Rule) {
+	h.rules.Store(&rules)
+}
+
+// Rules returns the rules currently in use.
+func (h *Handler) Rules() []validator.Rule {
+	return *h.rules.Load()
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case HealthPath:
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "ok\n")
+	case ValidatePath:
+		h.serveValidate(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) serveValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if contentType := r.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+		http.Error(w, fmt.Sprintf("unsupported content type %q, expected application/json", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&review); err != nil {
+		http.Error(w, fmt.Sprintf("decoding AdmissionReview: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "AdmissionReview has no request", http.StatusBadRequest)
+		return
+	}
+
+	response := h.Review(r.Context(), review.Request)
+	response.UID = review.Request.UID
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionv1.SchemeGroupVersion.String(),
+			Kind:       "AdmissionReview",
+		},
+		Response: response,
+	}); err != nil {
+		h.Logger.Error("writing AdmissionReview response", "uid", review.Request.UID, "error", err)
+	}
+}
+
+// Review evaluates the rules against an admission request. object is bound to
+// the request's object and oldObject to its previous version, which is null
+// for creates. Requests without an object, such as deletes, are allowed.
+func (h *Handler) Review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	logger := h.Logger.With(
+		"uid", req.UID,
+		"operation", req.Operation,
+		"kind", req.Kind.Kind,
+		"namespace", req.Namespace,
+		"name", req.Name,
+	)
+
+	if len(req.Object.Raw) == 0 {
+		logger.Debug("allowed request without an object")
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	object, err := decodeObject(req.Object.Raw, req.Namespace)
+	if err != nil {
+		logger.Error("decoding object", "error", err)
+		return errorResponse(http.StatusBadRequest, fmt.Errorf("decoding object: %w", err))
+	}
+	var oldObject *unstructured.Unstructured
+	if len(req.OldObject.Raw) > 0 {
+		if oldObject, err = decodeObject(req.OldObject.Raw, req.Namespace); err != nil {
+			logger.Error("decoding oldObject", "error", err)
+			return errorResponse(http.StatusBadRequest, fmt.Errorf("decoding oldObject: %w", err))
+		}
+	}
+
+	results := h.Validator.ValidateTransitions(ctx, "admission", []*unstructured.Unstructured{object}, []*unstructured.Unstructured{oldObject}, h.Rules())
+
+	response := &admissionv1.AdmissionResponse{Allowed: true}
+	var denials []string
+	for _, result := range results {
+		if result.Valid {
+			continue
+		}
+		message := fmt.Sprintf("[%s] %v", result.RuleName, result.Err)
+		switch {
+		case h.Audit:
+			logger.Info("audit: rule failed", "rule", result.RuleName, "severity", result.Severity, "message", result.Err)
+		case result.Severity == apiv1.SeverityError:
+			denials = append(denials, message)
+		case result.Severity == apiv1.SeverityWarning:
+			response.Warnings = append(response.Warnings, message)
+		default:
+			logger.Info("rule failed", "rule", result.RuleName, "severity", result.Severity, "message", result.Err)
+		}
+	}
+
+	if len(denials) > 0 {
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusForbidden,
+			Reason:  metav1.StatusReasonForbidden,
+			Message: "denied by celery: " + strings.Join(denials, "; "),
+		}
+	}
+
+	logger.Info("reviewed", "allowed", response.Allowed, "rules", len(results), "denials", len(denials), "warnings", len(response.Warnings))
+	return response
+}
+
+// decodeObject decodes a raw admission object. Objects being created may not
+// carry their namespace yet, so it is filled in from the request so namespace
+// targets still select them.
+func decodeObject(raw []byte, namespace string) (*unstructured.Unstructured, error) {
+	object := &unstructured.Unstructured{}
+	if err := object.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	if object.GetNamespace() == "" && namespace != "" {
+		object.SetNamespace(namespace)
+	}
+	return object, nil
+}
+
+func errorResponse(code int32, err error) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    code,
+			Message: err.Error(),
+		},
+	}
+}
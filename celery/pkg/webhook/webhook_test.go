@@ -0,0 +1,448 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
+	"github.com/RRethy/kube-tools/celery/pkg/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func compileRules(t *testing.T, rules ...apiv1.ValidationRule) []validator.Rule {
+	t.Helper()
+	compiled, err := (&validator.Validator{}).CompileRules([]apiv1.ValidationRules{
+		{
+			Filename:   "webhook-rules.yaml",
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook-rules"},
+			Spec:       apiv1.ValidationRulesSpec{Rules: rules},
+		},
+	})
+	require.NoError(t, err)
+	return compiled
+}
+
+func deploymentJSON(replicas int, app string) runtime.RawExtension {
+	return runtime.RawExtension{Raw: fmt.Appendf(nil, `{
+		"apiVersion": "apps/v1",
+		"kind": "Deployment",
+		"metadata": {"name": "web"},
+		"spec": {"replicas": %d, "selector": {"matchLabels": {"app": %q}}}
+	}`, replicas, app)}
+}
+
+func review(t *testing.T, server *httptest.Server, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	t.Helper()
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request:  req,
+	})
+	require.NoError(t, err)
+
+	resp, err := server.Client().Post(server.URL+ValidatePath, "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var out admissionv1.AdmissionReview
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.Equal(t, "admission.k8s.io/v1", out.APIVersion)
+	assert.Equal(t, "AdmissionReview", out.Kind)
+	require.NotNil(t, out.Response)
+	assert.Equal(t, req.UID, out.Response.UID)
+	return out.Response
+}
+
+func TestHandlerReview(t *testing.T) {
+	rules := compileRules(t,
+		apiv1.ValidationRule{Name: "minimum-replicas", Expression: "object.spec.replicas >= 3", Message: "too few replicas"},
+		apiv1.ValidationRule{Name: "immutable-selector", Expression: "oldObject == null || object.spec.selector == oldObject.spec.selector", Message: "spec.selector is immutable"},
+		apiv1.ValidationRule{Name: "has-labels", Expression: "has(object.metadata.labels)", Message: "missing labels", Severity: apiv1.SeverityWarning},
+		apiv1.ValidationRule{Name: "has-owner", Expression: "has(object.metadata.annotations)", Message: "missing owner", Severity: apiv1.SeverityInfo},
+	)
+
+	tests := []struct {
+		name         string
+		audit        bool
+		req          *admissionv1.AdmissionRequest
+		wantAllowed  bool
+		wantMessage  string
+		wantWarnings []string
+	}{
+		{
+			name: "create passing",
+			req: &admissionv1.AdmissionRequest{
+				UID:       types.UID("1"),
+				Operation: admissionv1.Create,
+				Namespace: "default",
+				Object:    deploymentJSON(3, "web"),
+			},
+			wantAllowed:  true,
+			wantWarnings: []string{"[has-labels] missing labels"},
+		},
+		{
+			name: "create failing",
+			req: &admissionv1.AdmissionRequest{
+				UID:       types.UID("2"),
+				Operation: admissionv1.Create,
+				Namespace: "default",
+				Object:    deploymentJSON(1, "web"),
+			},
+			wantAllowed:  false,
+			wantMessage:  "denied by celery: [minimum-replicas] too few replicas",
+			wantWarnings: []string{"[has-labels] missing labels"},
+		},
+		{
+			name: "update changing immutable field",
+			req: &admissionv1.AdmissionRequest{
+				UID:       types.UID("3"),
+				Operation: admissionv1.Update,
+				Namespace: "default",
+				Object:    deploymentJSON(3, "web"),
+				OldObject: deploymentJSON(3, "api"),
+			},
+			wantAllowed:  false,
+			wantMessage:  "denied by celery: [immutable-selector] spec.selector is immutable",
+			wantWarnings: []string{"[has-labels] missing labels"},
+		},
+		{
+			name: "delete without object",
+			req: &admissionv1.AdmissionRequest{
+				UID:       types.UID("4"),
+				Operation: admissionv1.Delete,
+				Namespace: "default",
+				OldObject: deploymentJSON(1, "web"),
+			},
+			wantAllowed: true,
+		},
+		{
+			name:  "audit mode allows failures",
+			audit: true,
+			req: &admissionv1.AdmissionRequest{
+				UID:       types.UID("5"),
+				Operation: admissionv1.Create,
+				Namespace: "default",
+				Object:    deploymentJSON(1, "web"),
+			},
+			wantAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			handler := NewHandler(rules, tt.audit, slog.New(slog.NewTextHandler(&logs, nil)))
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			resp := review(t, server, tt.req)
+			assert.Equal(t, tt.wantAllowed, resp.Allowed)
+			assert.Equal(t, tt.wantWarnings, resp.Warnings)
+			if tt.wantMessage != "" {
+				require.NotNil(t, resp.Result)
+				assert.Equal(t, tt.wantMessage, resp.Result.Message)
+				assert.Equal(t, int32(http.StatusForbidden), resp.Result.Code)
+			}
+			if tt.audit {
+				assert.Contains(t, logs.String(), "audit: rule failed")
+				assert.Contains(t, logs.String(), "rule=minimum-replicas")
+			}
+		})
+	}
+}
+
+func TestHandlerNamespaceTarget(t *testing.T) {
+	rules := compileRules(t, apiv1.ValidationRule{
+		Name:       "production-replicas",
+		Expression: "object.spec.replicas >= 3",
+		Message:    "too few replicas",
+		Target:     &apiv1.TargetSelector{Namespace: "production"},
+	})
+	server := httptest.NewServer(NewHandler(rules, false, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer server.Close()
+
+	resp := review(t, server, &admissionv1.AdmissionRequest{
+		UID:       types.UID("1"),
+		Operation: admissionv1.Create,
+		Namespace: "production",
+		Object:    deploymentJSON(1, "web"),
+	})
+	assert.False(t, resp.Allowed, "namespace should be taken from the request")
+
+	resp = review(t, server, &admissionv1.AdmissionRequest{
+		UID:       types.UID("2"),
+		Operation: admissionv1.Create,
+		Namespace: "staging",
+		Object:    deploymentJSON(1, "web"),
+	})
+	assert.True(t, resp.Allowed)
+}
+
+func TestHandlerNamespaceSelector(t *testing.T) {
+	rules := compileRules(t, apiv1.ValidationRule{
+		Name:       "production-replicas",
+		Expression: "object.spec.replicas >= 3",
+		Message:    "too few replicas",
+		Target:     &apiv1.TargetSelector{NamespaceSelector: "environment=production"},
+	})
+	handler := NewHandler(rules, false, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	var lookups []string
+	handler.Validator.NamespaceLabels = func(_ context.Context, name string) (map[string]string, error) {
+		lookups = append(lookups, name)
+		switch name {
+		case "payments":
+			return map[string]string{"environment": "production"}, nil
+		case "sandbox":
+			return map[string]string{"environment": "development"}, nil
+		}
+		return nil, errors.New("not found")
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	tests := []struct {
+		namespace   string
+		allowed     bool
+		wantMessage string
+	}{
+		{namespace: "payments", wantMessage: "too few replicas"},
+		{namespace: "sandbox", allowed: true},
+		{namespace: "missing", wantMessage: "looking up the labels of namespace missing: not found"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			resp := review(t, server, &admissionv1.AdmissionRequest{
+				UID:       types.UID(fmt.Sprint(i)),
+				Operation: admissionv1.Create,
+				Namespace: tt.namespace,
+				Object:    deploymentJSON(1, "web"),
+			})
+			assert.Equal(t, tt.allowed, resp.Allowed)
+			if tt.wantMessage != "" {
+				require.NotNil(t, resp.Result)
+				assert.Contains(t, resp.Result.Message, tt.wantMessage)
+			}
+		})
+	}
+	assert.Equal(t, []string{"payments", "sandbox", "missing"}, lookups)
+}
+
+func TestHandlerHTTP(t *testing.T) {
+	server := httptest.NewServer(NewHandler(nil, false, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{name: "healthz", method: http.MethodGet, path: HealthPath, wantStatus: http.StatusOK},
+		{name: "unknown path", method: http.MethodGet, path: "/other", wantStatus: http.StatusNotFound},
+		{name: "get validate", method: http.MethodGet, path: ValidatePath, wantStatus: http.StatusMethodNotAllowed},
+		{name: "wrong content type", method: http.MethodPost, path: ValidatePath, contentType: "text/plain", body: "{}", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "malformed body", method: http.MethodPost, path: ValidatePath, contentType: "application/json", body: "{", wantStatus: http.StatusBadRequest},
+		{name: "missing request", method: http.MethodPost, path: ValidatePath, contentType: "application/json", body: "{}", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, bytes.NewBufferString(tt.body))
+			require.NoError(t, err)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			resp, err := server.Client().Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	ruleFile := filepath.Join(dir, "rules.yaml")
+	writeRules := func(expression string, modTime time.Time) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(ruleFile, []byte(`apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: reload
+spec:
+  rules:
+    - name: replicas
+      expression: "`+expression+`"
+      message: too few replicas
+`), 0o644))
+		require.NoError(t, os.Chtimes(ruleFile, modTime, modTime))
+	}
+
+	now := time.Now()
+	writeRules("object.spec.replicas >= 1", now)
+
+	handler := NewHandler(nil, false, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	reloader := &Reloader{Patterns: []string{filepath.Join(dir, "*.yaml")}, Handler: handler}
+
+	reloaded, err := reloader.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	require.Len(t, handler.Rules(), 1)
+
+	reloaded, err = reloader.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "unchanged files should not be recompiled")
+
+	writeRules("object.spec.replicas >=", now.Add(time.Second))
+	reloaded, err = reloader.Reload()
+	require.Error(t, err)
+	assert.False(t, reloaded)
+	assert.Len(t, handler.Rules(), 1, "invalid rules should keep the previous rules")
+
+	writeRules("object.spec.replicas >= 3", now.Add(2*time.Second))
+	reloaded, err = reloader.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	resp := review(t, server, &admissionv1.AdmissionRequest{
+		UID:       types.UID("1"),
+		Operation: admissionv1.Create,
+		Object:    deploymentJSON(2, "web"),
+	})
+	assert.False(t, resp.Allowed, "reloaded rule should be used")
+}
+
+func TestReloaderIncludes(t *testing.T) {
+	dir := t.TempDir()
+	baseFile := filepath.Join(dir, "base", "rules.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(baseFile), 0o755))
+	writeBase := func(expression string, modTime time.Time) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(baseFile, []byte(`apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: base
+spec:
+  rules:
+    - name: replicas
+      expression: "`+expression+`"
+`), 0o644))
+		require.NoError(t, os.Chtimes(baseFile, modTime, modTime))
+	}
+	ruleFile := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, os.WriteFile(ruleFile, []byte(`apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: team
+spec:
+  include:
+    - base/rules.yaml
+  rules: []
+`), 0o644))
+
+	now := time.Now()
+	writeBase("object.spec.replicas >= 1", now)
+
+	handler := NewHandler(nil, false, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	reloader := &Reloader{Patterns: []string{ruleFile}, Handler: handler}
+
+	reloaded, err := reloader.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	require.Len(t, handler.Rules(), 1)
+
+	reloaded, err = reloader.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "unchanged files should not be recompiled")
+
+	writeBase("object.spec.replicas >= 3", now.Add(time.Second))
+	reloaded, err = reloader.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded, "changing an included file should reload the rules")
+}
+
+func TestReloaderParams(t *testing.T) {
+	dir := t.TempDir()
+	writeParams := func(path string, minReplicas int, modTime time.Time) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf("minReplicas: %d\n", minReplicas)), 0o644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	writeRules := func(path, paramsRef string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path, []byte(`apiVersion: celery.rrethy.io/v1
+kind: ValidationRules
+metadata:
+  name: params
+spec:
+  paramsRef: `+paramsRef+`
+  rules:
+    - name: replicas
+      expression: "object.spec.replicas >= params.minReplicas"
+`), 0o644))
+	}
+
+	now := time.Now()
+	paramsRef := filepath.Join(dir, "params.yaml")
+	flagParams := filepath.Join(dir, "flag-params.yaml")
+	writeParams(paramsRef, 1, now)
+	writeParams(flagParams, 1, now)
+	withParamsRef := filepath.Join(dir, "with-params-ref.yaml")
+	writeRules(withParamsRef, "params.yaml")
+	withoutParamsRef := filepath.Join(dir, "without-params-ref.yaml")
+	writeRules(withoutParamsRef, `""`)
+
+	for _, tt := range []struct {
+		name     string
+		reloader *Reloader
+		params   string
+	}{
+		{name: "paramsRef", reloader: &Reloader{Patterns: []string{withParamsRef}}, params: paramsRef},
+		{name: "params", reloader: &Reloader{Patterns: []string{withoutParamsRef}, Params: flagParams}, params: flagParams},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(nil, false, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			tt.reloader.Handler = handler
+			server := httptest.NewServer(handler)
+			defer server.Close()
+			allowed := func() bool {
+				return review(t, server, &admissionv1.AdmissionRequest{
+					UID:       types.UID("1"),
+					Operation: admissionv1.Create,
+					Object:    deploymentJSON(2, "web"),
+				}).Allowed
+			}
+
+			reloaded, err := tt.reloader.Reload()
+			require.NoError(t, err)
+			assert.True(t, reloaded)
+			assert.True(t, allowed())
+
+			writeParams(tt.params, 3, now.Add(time.Second))
+			reloaded, err = tt.reloader.Reload()
+			require.NoError(t, err)
+			assert.True(t, reloaded, "changing the params should reload the rules")
+			assert.False(t, allowed(), "reloaded params should be used")
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+
+	goyaml "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// documentSeparator matches the --- line starting a YAML document.
+var documentSeparator = regexp.MustCompile(`(?m)^---(?:[ \t].*)?\r?$`)
+
+// File is a multi-document YAML source whose documents can be changed and
+// written back. Documents that are not changed are written back byte for byte;
+// changed documents are re-encoded keeping their comments and the order of
+// their keys.
+type File struct {
+	chunks []*chunk
+	// docs are the chunk and node of each document with a resource.
+	docs []docRef
+}
+
+// chunk is the source of one document, from its separator line to the next.
+type chunk struct {
+	raw     []byte
+	nodes   []*goyaml.Node
+	changed bool
+}
+
+type docRef struct {
+	chunk *chunk
+	node  *goyaml.Node
+	doc   Document
+}
+
+// ParseFile parses multi-document YAML for editing.
+func ParseFile(data []byte) (*File, error) {
+	f := &File{}
+	line := 0
+	for _, raw := range splitDocuments(data) {
+		c := &chunk{raw: raw}
+		decoder := goyaml.NewDecoder(bytes.NewReader(raw))
+		for {
+			var node goyaml.Node
+			err := decoder.Decode(&node)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, fmt.Errorf("decoding YAML: %w", err)
+			}
+
+			var obj map[string]any
+			if err := node.Decode(&obj); err != nil {
+				return nil, fmt.Errorf("decoding YAML: %w", err)
+			}
+			c.nodes = append(c.nodes, &node)
+			if obj == nil {
+				continue
+			}
+
+			positions := positionsOf(&node)
+			positions.shift(line, 0)
+			f.docs = append(f.docs, docRef{
+				chunk: c,
+				node:  &node,
+				doc:   Document{Resource: &unstructured.Unstructured{Object: obj}, Positions: positions},
+			})
+		}
+		f.chunks = append(f.chunks, c)
+		line += bytes.Count(raw, []byte("\n"))
+	}
+	return f, nil
+}
+
+// splitDocuments splits data before every document separator line.
+func splitDocuments(data []byte) [][]byte {
+	var chunks [][]byte
+	start := 0
+	for _, loc := range documentSeparator.FindAllIndex(data, -1) {
+		if loc[0] > start {
+			chunks = append(chunks, data[start:loc[0]])
+		}
+		start = loc[0]
+	}
+	if start < len(data) {
+		chunks = append(chunks, data[start:])
+	}
+	return chunks
+}
+
+// Documents returns the resources in the file, in order.
+func (f *File) Documents() []Document {
+	docs := make([]Document, 0, len(f.docs))
+	for _, ref := range f.docs {
+		docs = append(docs, ref.doc)
+	}
+	return docs
+}
+
+// Set replaces the object of document i, as numbered by Documents. Fields
+// that are unchanged keep their comments and formatting, fields that are
+// removed are dropped, and new fields are added after the existing ones.
+func (f *File) Set(i int, obj map[string]any) error {
+	if i < 0 || i >= len(f.docs) {
+		return fmt.Errorf("document %d does not exist", i)
+	}
+	ref := &f.docs[i]
+	if err := setNode(ref.node, obj); err != nil {
+		return err
+	}
+	ref.chunk.changed = true
+	ref.doc.Resource = &unstructured.Unstructured{Object: obj}
+	return nil
+}
+
+// Bytes encodes the file.
+func (f *File) Bytes() ([]byte, error) {
+	var out bytes.Buffer
+	for _, c := range f.chunks {
+		if !c.changed {
+			out.Write(c.raw)
+			continue
+		}
+
+		// The encoder writes separators between documents but not before the
+		// first, so the original separator line is kept.
+		if loc := documentSeparator.FindIndex(c.raw); loc != nil && loc[0] == 0 {
+			out.Write(c.raw[:loc[1]])
+			out.WriteString("\n")
+		}
+		encoder := goyaml.NewEncoder(&out)
+		encoder.SetIndent(indentOf(c.raw))
+		for _, node := range c.nodes {
+			if err := encoder.Encode(node); err != nil {
+				return nil, fmt.Errorf("encoding YAML: %w", err)
+			}
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, fmt.Errorf("encoding YAML: %w", err)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// indentPattern matches a mapping key indented below another.
+var indentPattern = regexp.MustCompile(`(?m)^[^\s#-][^\n]*:[ \t]*\r?\n( +)[^\s#]`)
+
+// indentOf returns the indentation a document uses, 2 if it is unknown.
+func indentOf(raw []byte) int {
+	if m := indentPattern.FindSubmatch(raw); m != nil {
+		return len(m[1])
+	}
+	return 2
+}
+
+// setNode changes node to encode value, keeping the parts of node that
+// already do.
+func setNode(node *goyaml.Node, value any) error {
+	if node.Kind == goyaml.DocumentNode && len(node.Content) > 0 {
+		return setNode(node.Content[0], value)
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		if node.Kind != goyaml.MappingNode {
+			return replaceNode(node, value)
+		}
+		seen := make(map[string]bool, len(value))
+		content := make([]*goyaml.Node, 0, len(node.Content))
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, field := node.Content[i], node.Content[i+1]
+			fieldValue, ok := value[key.Value]
+			if !ok || seen[key.Value] {
+				continue
+			}
+			seen[key.Value] = true
+			if err := setNode(field, fieldValue); err != nil {
+				return err
+			}
+			content = append(content, key, field)
+		}
+
+		var added []string
+		for key := range value {
+			if !seen[key] {
+				added = append(added, key)
+			}
+		}
+		sort.Strings(added)
+		for _, key := range added {
+			keyNode, fieldNode := &goyaml.Node{}, &goyaml.Node{}
+			if err := keyNode.Encode(key); err != nil {
+				return fmt.Errorf("encoding %s: %w", key, err)
+			}
+			if err := fieldNode.Encode(value[key]); err != nil {
+				return fmt.Errorf("encoding %s: %w", key, err)
+			}
+			content = append(content, keyNode, fieldNode)
+		}
+		node.Content = content
+		return nil
+	case []any:
+		if node.Kind != goyaml.SequenceNode {
+			return replaceNode(node, value)
+		}
+		content := node.Content[:min(len(node.Content), len(value))]
+		for i, item := range content {
+			if err := setNode(item, value[i]); err != nil {
+				return err
+			}
+		}
+		for _, item := range value[len(content):] {
+			itemNode := &goyaml.Node{}
+			if err := itemNode.Encode(item); err != nil {
+				return fmt.Errorf("encoding list item: %w", err)
+			}
+			content = append(content, itemNode)
+		}
+		node.Content = content
+		return nil
+	default:
+		if node.Kind == goyaml.ScalarNode {
+			var current any
+			if err := node.Decode(&current); err == nil && scalarEqual(current, value) {
+				return nil
+			}
+		}
+		return replaceNode(node, value)
+	}
+}
+
+// replaceNode re-encodes node as value, keeping its comments.
+func replaceNode(node *goyaml.Node, value any) error {
+	var replacement goyaml.Node
+	if err := replacement.Encode(value); err != nil {
+		return fmt.Errorf("encoding value: %w", err)
+	}
+	replacement.HeadComment = node.HeadComment
+	replacement.LineComment = node.LineComment
+	replacement.FootComment = node.FootComment
+	*node = replacement
+	return nil
+}
+
+// scalarEqual reports whether two decoded scalars are equal, treating numbers
+// of different Go types as equal when their values are.
+func scalarEqual(a, b any) bool {
+	x, y := reflect.ValueOf(a), reflect.ValueOf(b)
+	if x.CanInt() && y.CanInt() {
+		return x.Int() == y.Int()
+	}
+	if x.CanFloat() && y.CanFloat() {
+		return x.Float() == y.Float()
+	}
+	return reflect.DeepEqual(a, b)
+}
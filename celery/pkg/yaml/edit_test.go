@@ -0,0 +1,107 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSet(t *testing.T) {
+	input := `# services
+apiVersion: v1
+kind: Service
+metadata:
+    name: web
+---
+# the web deployment
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web # keep this name
+  labels:
+    tier: frontend
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: web
+          image: web:v1
+          securityContext:
+            privileged: false
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+	f, err := ParseFile([]byte(input))
+	require.NoError(t, err)
+	docs := f.Documents()
+	require.Len(t, docs, 3)
+	assert.Equal(t, Position{Line: 8, Column: 1}, docs[1].Positions.Position)
+
+	obj := docs[1].Resource.Object
+	obj["metadata"].(map[string]any)["labels"].(map[string]any)["app"] = "web"
+	container := obj["spec"].(map[string]any)["template"].(map[string]any)["spec"].(map[string]any)["containers"].([]any)[0].(map[string]any)
+	container["securityContext"].(map[string]any)["runAsNonRoot"] = true
+	delete(container["securityContext"].(map[string]any), "privileged")
+	require.NoError(t, f.Set(1, obj))
+
+	out, err := f.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, `# services
+apiVersion: v1
+kind: Service
+metadata:
+    name: web
+---
+# the web deployment
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web # keep this name
+  labels:
+    tier: frontend
+    app: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: web
+          image: web:v1
+          securityContext:
+            runAsNonRoot: true
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`, string(out))
+	assert.Equal(t, "web", f.Documents()[1].Resource.GetLabels()["app"])
+}
+
+func TestFileUnchanged(t *testing.T) {
+	input := "apiVersion: v1\nkind: Service\nmetadata: {name: web}   # flow style\n---\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: api\n"
+	f, err := ParseFile([]byte(input))
+	require.NoError(t, err)
+	require.Len(t, f.Documents(), 2)
+	assert.Equal(t, 6, f.Documents()[1].Positions.Line)
+
+	out, err := f.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, input, string(out))
+}
+
+func TestFileSetMissingDocument(t *testing.T) {
+	f, err := ParseFile([]byte("apiVersion: v1\nkind: Service\n"))
+	require.NoError(t, err)
+	assert.EqualError(t, f.Set(1, map[string]any{}), "document 1 does not exist")
+}
+
+func TestParseFileInvalid(t *testing.T) {
+	_, err := ParseFile([]byte("apiVersion: v1\nkind: [Service\n"))
+	assert.ErrorContains(t, err, "decoding YAML")
+}
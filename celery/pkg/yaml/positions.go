@@ -0,0 +1,118 @@
+package yaml
+
+import (
+	"bytes"
+	"strconv"
+
+	goyaml "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Document is a resource parsed from a YAML or JSON source along with where
+// it and its fields are in the source.
+type Document struct {
+	Resource *unstructured.Unstructured
+	// Positions is nil when the positions of the document are unknown.
+	Positions *Positions
+}
+
+// Position is a line and column in a source, both starting at 1. The zero
+// Position is unknown.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Positions records where a value and the values nested in it are in a
+// source. The fields of a mapping are at their key, so a failing field points
+// at the line it is named on.
+type Positions struct {
+	Position
+	// fields are keyed by mapping key, or by index for list items.
+	fields map[string]*Positions
+}
+
+// Field returns the position of the deepest value along path that is in the
+// source, and how many elements of path it followed to get there. List items
+// are addressed by their index, e.g. spec, containers, 0, image. A nil
+// Positions returns the zero Position.
+func (p *Positions) Field(path []string) (Position, int) {
+	if p == nil {
+		return Position{}, 0
+	}
+
+	depth := 0
+	for _, key := range path {
+		field := p.fields[key]
+		if field == nil {
+			break
+		}
+		p = field
+		depth++
+	}
+	return p.Position, depth
+}
+
+// positionsOf records the positions of node and every value nested in it.
+func positionsOf(node *goyaml.Node) *Positions {
+	if node.Kind == goyaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind == goyaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	p := &Positions{Position: Position{Line: node.Line, Column: node.Column}}
+	switch node.Kind {
+	case goyaml.MappingNode:
+		p.fields = make(map[string]*Positions, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field := positionsOf(value)
+			field.Position = Position{Line: key.Line, Column: key.Column}
+			p.fields[key.Value] = field
+		}
+	case goyaml.SequenceNode:
+		p.fields = make(map[string]*Positions, len(node.Content))
+		for i, item := range node.Content {
+			p.fields[strconv.Itoa(i)] = positionsOf(item)
+		}
+	}
+	return p
+}
+
+// jsonPositions records the positions of the JSON value at data[start:end].
+// JSON is parsed as YAML to find them, so values YAML cannot parse have no
+// positions.
+func jsonPositions(data []byte, start, end int64) *Positions {
+	var node goyaml.Node
+	if err := goyaml.Unmarshal(data[start:end], &node); err != nil || len(node.Content) == 0 {
+		return nil
+	}
+
+	p := positionsOf(&node)
+	line := bytes.Count(data[:start], []byte("\n"))
+	column := int(start) - bytes.LastIndexByte(data[:start], '\n') - 1
+	p.shift(line, column)
+	return p
+}
+
+// shift moves positions parsed from the middle of a source by the line and
+// column that source started at. Only the first line is shifted by column.
+func (p *Positions) shift(line, column int) {
+	if p.Line == 1 {
+		p.Column += column
+	}
+	p.Line += line
+	for _, field := range p.fields {
+		field.shift(line, column)
+	}
+}
+
+// child returns the positions of list item i, or nil if they are unknown.
+func (p *Positions) child(i int) *Positions {
+	if p == nil {
+		return nil
+	}
+	return p.fields[strconv.Itoa(i)]
+}
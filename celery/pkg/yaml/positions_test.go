@@ -0,0 +1,101 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseYAMLToDocumentsPositions(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+# comment
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+        - name: web
+          image: web:latest
+        - name: sidecar
+          image: proxy:v1
+`
+	docs, err := ParseYAMLToDocuments([]byte(input))
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, "Service", docs[0].Resource.GetKind())
+
+	tests := []struct {
+		name      string
+		doc       int
+		path      []string
+		want      Position
+		wantDepth int
+	}{
+		{name: "document", doc: 1, want: Position{Line: 7, Column: 1}},
+		{name: "field", doc: 1, path: []string{"spec", "replicas"}, want: Position{Line: 12, Column: 3}, wantDepth: 2},
+		{name: "list item", doc: 1, path: []string{"spec", "template", "spec", "containers", "1"}, want: Position{Line: 18, Column: 11}, wantDepth: 5},
+		{name: "field of list item", doc: 1, path: []string{"spec", "template", "spec", "containers", "1", "image"}, want: Position{Line: 19, Column: 11}, wantDepth: 6},
+		{name: "missing field is its deepest parent", doc: 1, path: []string{"metadata", "labels", "app"}, want: Position{Line: 9, Column: 1}, wantDepth: 1},
+		{name: "first document", doc: 0, path: []string{"metadata", "name"}, want: Position{Line: 4, Column: 3}, wantDepth: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, depth := docs[tt.doc].Positions.Field(tt.path)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantDepth, depth)
+		})
+	}
+}
+
+func TestParseJSONToDocumentsPositions(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Position
+	}{
+		{
+			name:  "object",
+			input: "{\n  \"kind\": \"Service\",\n  \"metadata\": {\"name\": \"web\"}\n}",
+			want:  []Position{{Line: 3, Column: 16}},
+		},
+		{
+			name:  "stream of objects",
+			input: "{\"kind\": \"Service\", \"metadata\": {\"name\": \"a\"}}\n  {\"kind\": \"Service\", \"metadata\": {\"name\": \"b\"}}",
+			want:  []Position{{Line: 1, Column: 34}, {Line: 2, Column: 36}},
+		},
+		{
+			name:  "array of objects",
+			input: "[\n  {\"kind\": \"Service\", \"metadata\": {\"name\": \"a\"}},\n  {\"kind\": \"Service\", \"metadata\": {\"name\": \"b\"}}\n]",
+			want:  []Position{{Line: 2, Column: 36}, {Line: 3, Column: 36}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := ParseJSONToDocuments([]byte(tt.input))
+			require.NoError(t, err)
+			require.Len(t, docs, len(tt.want))
+			for i, want := range tt.want {
+				got, depth := docs[i].Positions.Field([]string{"metadata", "name"})
+				assert.Equal(t, want, got, "document %d", i)
+				assert.Equal(t, 2, depth, "document %d", i)
+			}
+		})
+	}
+}
+
+func TestPositionsFieldNil(t *testing.T) {
+	var positions *Positions
+	got, depth := positions.Field([]string{"spec"})
+	assert.Equal(t, Position{}, got)
+	assert.Zero(t, depth)
+}
@@ -1,6 +1,8 @@
 package yaml
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -15,12 +17,22 @@ import (
 // ParseYAMLToUnstructured parses multi-document YAML into unstructured Kubernetes objects.
 // It handles both single and multi-document YAML files.
 func ParseYAMLToUnstructured(data []byte) ([]*unstructured.Unstructured, error) {
-	var resources []*unstructured.Unstructured
+	docs, err := ParseYAMLToDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+	return Resources(docs), nil
+}
 
-	decoder := goyaml.NewDecoder(strings.NewReader(string(data)))
+// ParseYAMLToDocuments parses multi-document YAML into unstructured Kubernetes
+// objects along with the positions of their fields.
+func ParseYAMLToDocuments(data []byte) ([]Document, error) {
+	var docs []Document
+
+	decoder := goyaml.NewDecoder(bytes.NewReader(data))
 	for {
-		var obj map[string]any
-		err := decoder.Decode(&obj)
+		var node goyaml.Node
+		err := decoder.Decode(&node)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
@@ -28,15 +40,114 @@ func ParseYAMLToUnstructured(data []byte) ([]*unstructured.Unstructured, error)
 			return nil, fmt.Errorf("decoding YAML: %w", err)
 		}
 
+		var obj map[string]any
+		if err := node.Decode(&obj); err != nil {
+			return nil, fmt.Errorf("decoding YAML: %w", err)
+		}
 		if obj == nil {
 			continue
 		}
 
-		u := &unstructured.Unstructured{Object: obj}
-		resources = append(resources, u)
+		docs = append(docs, Document{
+			Resource:  &unstructured.Unstructured{Object: obj},
+			Positions: positionsOf(&node),
+		})
 	}
 
-	return resources, nil
+	return docs, nil
+}
+
+// Resources returns the resources of docs.
+func Resources(docs []Document) []*unstructured.Unstructured {
+	resources := make([]*unstructured.Unstructured, 0, len(docs))
+	for _, doc := range docs {
+		resources = append(resources, doc.Resource)
+	}
+	return resources
+}
+
+// ParseJSONToUnstructured parses JSON into unstructured Kubernetes objects.
+// The input may be a single object, a stream of concatenated objects, or an array of objects.
+func ParseJSONToUnstructured(data []byte) ([]*unstructured.Unstructured, error) {
+	docs, err := ParseJSONToDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+	return Resources(docs), nil
+}
+
+// ParseJSONToDocuments parses JSON into unstructured Kubernetes objects along
+// with the positions of their fields where they can be found.
+func ParseJSONToDocuments(data []byte) ([]Document, error) {
+	var docs []Document
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	for {
+		start := decoder.InputOffset()
+		var doc any
+		err := decoder.Decode(&doc)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("decoding JSON: %w", err)
+		}
+		positions := jsonPositions(data, start, decoder.InputOffset())
+
+		values := []any{doc}
+		valuePositions := []*Positions{positions}
+		if list, ok := doc.([]any); ok {
+			values = list
+			valuePositions = make([]*Positions, len(list))
+			for i := range list {
+				valuePositions[i] = positions.child(i)
+			}
+		}
+
+		for i, d := range values {
+			if d == nil {
+				continue
+			}
+			obj, ok := d.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("decoding JSON: expected an object but got %T", d)
+			}
+			docs = append(docs, Document{
+				Resource:  &unstructured.Unstructured{Object: normalizeJSONNumbers(obj).(map[string]any)},
+				Positions: valuePositions[i],
+			})
+		}
+	}
+
+	return docs, nil
+}
+
+// normalizeJSONNumbers converts json.Number values into int64 or float64 so
+// JSON manifests evaluate the same way as their YAML equivalents.
+func normalizeJSONNumbers(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			t[k] = normalizeJSONNumbers(val)
+		}
+		return t
+	case []any:
+		for i, val := range t {
+			t[i] = normalizeJSONNumbers(val)
+		}
+		return t
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return t.String()
+	default:
+		return v
+	}
 }
 
 // ParseYAMLToValidationRules parses multi-document YAML into ValidationRules.
@@ -68,6 +179,41 @@ func ParseYAMLToValidationRules(data []byte, filename string) ([]apiv1.Validatio
 	return ruless, nil
 }
 
+// ParseYAMLToValidationTests parses multi-document YAML into ValidationTests.
+// Documents of other kinds are skipped, so a directory of mixed fixtures can be
+// searched for tests.
+func ParseYAMLToValidationTests(data []byte, filename string) ([]apiv1.ValidationTest, error) {
+	var tests []apiv1.ValidationTest
+
+	decoder := goyaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node goyaml.Node
+		err := decoder.Decode(&node)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("decoding YAML: %w", err)
+		}
+
+		var meta struct {
+			Kind string `yaml:"kind"`
+		}
+		if err := node.Decode(&meta); err != nil || meta.Kind != apiv1.ValidationTestKind {
+			continue
+		}
+
+		var test apiv1.ValidationTest
+		if err := node.Decode(&test); err != nil {
+			return nil, fmt.Errorf("decoding ValidationTest: %w", err)
+		}
+		test.Filename = filename
+		tests = append(tests, test)
+	}
+
+	return tests, nil
+}
+
 // ParseYAMLBytes is a generic YAML parser that can decode multi-document YAML
 // into a slice of the specified type.
 func ParseYAMLBytes[T any](data []byte) ([]T, error) {
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	apiv1 "github.com/RRethy/kube-tools/celery/api/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -84,6 +85,67 @@ metadata:
 	}
 }
 
+func TestParseJSONToUnstructured(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name:      "single object",
+			input:     `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "svc"}}`,
+			wantCount: 1,
+		},
+		{
+			name: "array of objects",
+			input: `[
+	{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "svc"}},
+	{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "deploy"}}
+]`,
+			wantCount: 2,
+		},
+		{
+			name: "stream of objects",
+			input: `{"apiVersion": "v1", "kind": "Service"}
+{"apiVersion": "v1", "kind": "ConfigMap"}`,
+			wantCount: 2,
+		},
+		{
+			name:      "empty input",
+			input:     "",
+			wantCount: 0,
+		},
+		{
+			name:    "array of scalars",
+			input:   `[1, 2]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			input:   `{"kind": `,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources, err := ParseJSONToUnstructured([]byte(tt.input))
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Len(t, resources, tt.wantCount)
+			for _, r := range resources {
+				assert.NotEmpty(t, r.GetKind())
+			}
+		})
+	}
+}
+
 func TestParseYAMLToValidationRules(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -187,6 +249,52 @@ metadata:
 	}
 }
 
+func TestParseYAMLToValidationTests(t *testing.T) {
+	input := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: fixture
+data:
+  expect: not-a-list
+---
+apiVersion: celery.rrethy.io/v1
+kind: ValidationTest
+metadata:
+  name: replicas
+spec:
+  ruleFiles: [rules.yaml]
+  resources: [resources/]
+  expect:
+    - rule: minimum-replicas
+      resource: Deployment/web
+      outcome: fail
+      message: at least 3`
+
+	tests, err := ParseYAMLToValidationTests([]byte(input), "suite.yaml")
+	require.NoError(t, err)
+	require.Len(t, tests, 1)
+
+	test := tests[0]
+	assert.Equal(t, "replicas", test.Name)
+	assert.Equal(t, "suite.yaml", test.Filename)
+	assert.Equal(t, []string{"rules.yaml"}, test.Spec.RuleFiles)
+	assert.Equal(t, []string{"resources/"}, test.Spec.Resources)
+	assert.Equal(t, []apiv1.Expectation{{
+		Rule:     "minimum-replicas",
+		Resource: "Deployment/web",
+		Outcome:  apiv1.OutcomeFail,
+		Message:  "at least 3",
+	}}, test.Spec.Expect)
+
+	tests, err = ParseYAMLToValidationTests([]byte("kind: Service\n"), "none.yaml")
+	require.NoError(t, err)
+	assert.Empty(t, tests)
+
+	_, err = ParseYAMLToValidationTests([]byte("kind: ValidationTest\nspec: [\n"), "bad.yaml")
+	assert.Error(t, err)
+}
+
 func TestParseYAMLBytes(t *testing.T) {
 	type TestStruct struct {
 		APIVersion string `yaml:"apiVersion"`